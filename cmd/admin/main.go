@@ -0,0 +1,307 @@
+// Command admin manages accounts directly in the database. It bootstraps the
+// first admin and recovers access when no admin can log in any more.
+//
+//	go run ./cmd/admin create-admin -name "Jane Doe" -email jane@example.com
+//	go run ./cmd/admin promote -email jane@example.com
+//	go run ./cmd/admin reset-password -email jane@example.com
+//	go run ./cmd/admin disable-mfa -email jane@example.com
+//	go run ./cmd/admin list-admins
+//	go run ./cmd/admin hash-passwords
+//	go run ./cmd/admin rotate-encryption-key -keyfile keys/encryption.json
+//
+// The database and keyfile are configured as for the server, see package
+// config; its flags go before the command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/logging"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+)
+
+const usage = `usage: admin [-config file] [config flags] <command> [flags]
+
+commands:
+  create-admin    create an admin account (-name, -email, optional -password, -force)
+  promote         make an existing user an active admin (-email)
+  reset-password  set a new password for a user (-email, optional -password)
+  disable-mfa     turn off two-factor authentication for a user who lost their device (-email)
+  list-admins     list the active admins
+  hash-passwords  hash the passwords still stored in plaintext, which otherwise
+                  happens at the next login of each account
+  rotate-encryption-key
+                  add a new current key to the encryption keyfile, creating it if
+                  needed (-keyfile, defaults to encryption.keyfile)
+`
+
+func main() {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	cfg, err := config.Load(flags, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	// the output is for the operator, the log goes to stderr
+	slog.SetDefault(logging.New(os.Stderr, cfg.Logging))
+	args := flags.Args()
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	// works on the keyfile only, which may not exist yet
+	if args[0] == "rotate-encryption-key" {
+		if err := rotateEncryptionKey(cfg.Encryption.Keyfile, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	var cipher *encryption.Cipher
+	if cfg.Encryption.Keyfile != "" {
+		cipher, err = encryption.FromKeyfile(cfg.Encryption.Keyfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+	}
+	db := database.New(cfg.Database, cipher)
+	defer db.Close()
+
+	switch args[0] {
+	case "create-admin":
+		err = createAdmin(db, args[1:])
+	case "promote":
+		err = promote(db, args[1:])
+	case "reset-password":
+		err = resetPassword(db, args[1:])
+	case "disable-mfa":
+		err = disableMFA(db, args[1:])
+	case "list-admins":
+		err = listAdmins(db)
+	case "hash-passwords":
+		err = hashPasswords(db)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// passwordOrGenerated returns password, or a generated one that is printed
+// since nobody else will ever see it.
+func passwordOrGenerated(password string) (string, error) {
+	if password != "" {
+		return password, server.ValidatePassword(password)
+	}
+	password, err := server.GeneratePassword()
+	if err != nil {
+		return "", err
+	}
+	fmt.Println("temporary password:", password)
+	return password, nil
+}
+
+func createAdmin(db database.Service, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	name := flags.String("name", "", "display name")
+	email := flags.String("email", "", "login email")
+	password := flags.String("password", "", "password, generated when empty")
+	force := flags.Bool("force", false, "create the admin even if active admins exist")
+	flags.Parse(args)
+
+	validName, validEmail, err := server.ValidateNewUser(*name, *email)
+	if err != nil {
+		return err
+	}
+	admins, err := db.ActiveAdmins()
+	if err != nil {
+		return err
+	}
+	if len(admins) > 0 && !*force {
+		return errors.New("active admins already exist, invite new admins through the API or pass -force")
+	}
+	exists, err := db.UserExists(validEmail)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s already has an account, use promote instead", validEmail)
+	}
+
+	pass, err := passwordOrGenerated(*password)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := server.PassToHash(pass)
+	if err != nil {
+		return err
+	}
+	id, err := db.CreateAdmin(validName, validEmail, passwordHash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created admin %d <%s>\n", id, validEmail)
+	return nil
+}
+
+func userIdByEmail(db database.Service, email string) (int, error) {
+	exists, err := db.UserExists(email)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("no account for %q", email)
+	}
+	return db.GetUserId(email)
+}
+
+func promote(db database.Service, args []string) error {
+	flags := flag.NewFlagSet("promote", flag.ExitOnError)
+	email := flags.String("email", "", "login email")
+	flags.Parse(args)
+
+	id, err := userIdByEmail(db, *email)
+	if err != nil {
+		return err
+	}
+	if err = db.SetUserActive(id, true); err != nil {
+		return err
+	}
+	if err = db.SetUserType(id, models.Admin); err != nil {
+		return err
+	}
+	fmt.Printf("user %d <%s> is now an admin\n", id, *email)
+	return nil
+}
+
+func resetPassword(db database.Service, args []string) error {
+	flags := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := flags.String("email", "", "login email")
+	password := flags.String("password", "", "new password, generated when empty")
+	flags.Parse(args)
+
+	id, err := userIdByEmail(db, *email)
+	if err != nil {
+		return err
+	}
+	pass, err := passwordOrGenerated(*password)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := server.PassToHash(pass)
+	if err != nil {
+		return err
+	}
+	if err = db.SetUserPassword(id, passwordHash); err != nil {
+		return err
+	}
+	fmt.Printf("password of user %d <%s> was reset\n", id, *email)
+	return nil
+}
+
+func disableMFA(db database.Service, args []string) error {
+	flags := flag.NewFlagSet("disable-mfa", flag.ExitOnError)
+	email := flags.String("email", "", "login email")
+	flags.Parse(args)
+
+	id, err := userIdByEmail(db, *email)
+	if err != nil {
+		return err
+	}
+	if err = db.DisableMFA(id); err != nil {
+		return err
+	}
+	fmt.Printf("two-factor authentication of user %d <%s> was disabled\n", id, *email)
+	return nil
+}
+
+// hashPasswords hashes the passwords stored before passwords were hashed,
+// see server.LegacyPassword. A password too long to hash is left for its
+// user to reset.
+func hashPasswords(db database.Service) error {
+	passwords, err := db.GetLegacyPasswords()
+	if err != nil {
+		return err
+	}
+	hashed := 0
+	for id, password := range passwords {
+		passwordHash, err := server.PassToHash(password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "user %d: %v, reset their password instead\n", id, err)
+			continue
+		}
+		if err = db.ReplacePasswordHash(id, password, passwordHash); err != nil {
+			return err
+		}
+		hashed++
+	}
+	fmt.Printf("hashed %d of %d plaintext passwords\n", hashed, len(passwords))
+	return nil
+}
+
+func listAdmins(db database.Service) error {
+	ids, err := db.ActiveAdmins()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		user, err := db.GetUserById(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d\t%s\t%s\n", user.Id, user.Email, user.Name)
+	}
+	return nil
+}
+
+// rotateEncryptionKey makes a new key current. Data encrypted under the older
+// keys is re-encrypted by the server in the background, which needs them to
+// stay in the keyfile until then.
+func rotateEncryptionKey(keyfilePath string, args []string) error {
+	flags := flag.NewFlagSet("rotate-encryption-key", flag.ExitOnError)
+	path := flags.String("keyfile", keyfilePath, "path of the keyfile")
+	flags.Parse(args)
+	if *path == "" {
+		return errors.New("-keyfile or encryption.keyfile is required")
+	}
+
+	keyfile, err := encryption.LoadKeyfile(*path)
+	if errors.Is(err, os.ErrNotExist) {
+		if keyfile, err = encryption.NewKeyfile(); err != nil {
+			return err
+		}
+		if err = keyfile.Save(*path); err != nil {
+			return err
+		}
+		fmt.Printf("created keyfile %s with key %s\n", *path, keyfile.CurrentKeyID())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	id, err := keyfile.Rotate()
+	if err != nil {
+		return err
+	}
+	if err = keyfile.Save(*path); err != nil {
+		return err
+	}
+	fmt.Printf("key %s is now current; restart the server to re-encrypt with it\n", id)
+	return nil
+}
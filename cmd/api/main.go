@@ -1,15 +1,76 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/logging"
 	"resume-backend-parser/internal/server"
+	"resume-backend-parser/internal/tracing"
 )
 
 func main() {
-	server := server.NewServer()
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the effective configuration and exit")
+	cfg, err := config.Load(flags, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	slog.SetDefault(logging.New(os.Stdout, cfg.Logging))
+	slog.Info("configuration", "config", cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the stdout exporter shares stdout with the log
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, os.Stdout)
+	if err != nil {
+		slog.Error("cannot set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	server := server.NewServer(cfg)
 
-	err := server.ListenAndServe()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err = <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("cannot start server", "error", err)
+			os.Exit(1)
+		}
+		return
+	case <-ctx.Done():
+	}
+	// a second signal exits right away
+	stop()
+
+	slog.Info("shutting down", "timeout", cfg.Shutdown.Delay+cfg.Shutdown.Timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Delay+cfg.Shutdown.Timeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	// the spans of the last requests
+	if tracingErr := shutdownTracing(shutdownCtx); tracingErr != nil {
+		slog.Error("exporting the last spans", "error", tracingErr)
+	}
 	if err != nil {
-		panic(fmt.Sprintf("cannot start server: %s", err))
+		slog.Error("shutdown", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("shut down")
 }
@@ -0,0 +1,47 @@
+// Command mockoidc runs a mock OpenID Connect provider for trying single
+// sign-on locally. Everyone who signs in there is the user given by the flags.
+//
+//	go run ./cmd/mockoidc -email jane@example.com -groups recruiters
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"resume-backend-parser/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "listen address")
+	clientID := flag.String("client-id", "resume-parser", "expected client id")
+	clientSecret := flag.String("client-secret", "secret", "expected client secret")
+	subject := flag.String("sub", "mock-user-1", "subject of the signed in user")
+	email := flag.String("email", "recruiter@example.com", "email of the signed in user")
+	name := flag.String("name", "Mock Recruiter", "name of the signed in user")
+	groups := flag.String("groups", "", "comma separated groups of the signed in user")
+	flag.Parse()
+
+	var userGroups []string
+	for _, g := range strings.Split(*groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			userGroups = append(userGroups, g)
+		}
+	}
+	provider, err := oidctest.New(*clientID, *clientSecret, oidctest.User{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: true,
+		Name:          *name,
+		Groups:        userGroups,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider.Issuer = "http://" + *addr
+
+	fmt.Println("mock OIDC provider at", provider.Issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
@@ -0,0 +1,74 @@
+// Package apikey generates and parses the API keys that integrations use
+// instead of a user login, and defines the scopes a key can be limited to.
+//
+// A key looks like rpk_<id>_<secret>. The id is stored in the clear to find
+// the key, the secret only as a SHA-256 hash.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const prefix = "rpk_"
+
+type Scope string
+
+const (
+	JobsRead          Scope = "jobs:read"
+	JobsWrite         Scope = "jobs:write"
+	ApplicationsRead  Scope = "applications:read"
+	ApplicationsWrite Scope = "applications:write"
+)
+
+var Scopes = []Scope{JobsRead, JobsWrite, ApplicationsRead, ApplicationsWrite}
+
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a new key, its id and the hash to store.
+func Generate() (string, string, string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	id := hex.EncodeToString(b)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	return prefix + id + "_" + secret, id, Hash(secret), nil
+}
+
+// Parse splits a key into its id and secret.
+func Parse(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, prefix), "_")
+	if !ok || len(id) != 12 || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches compares a secret with a stored hash in constant time.
+func Matches(secret string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(hash)) == 1
+}
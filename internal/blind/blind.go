@@ -0,0 +1,78 @@
+// Package blind implements blind hiring: admins see candidates without the
+// fields that tell who they are until their application gets far enough.
+package blind
+
+import (
+	"strings"
+
+	"resume-backend-parser/internal/models"
+)
+
+// PseudonymPrefix starts every pseudonym, which tells them apart from user
+// ids.
+const PseudonymPrefix = "cand-"
+
+// progress orders the stages an application moves forward through. Rejected
+// and withdrawn applications don't move forward and never reveal anyone.
+var progress = map[models.ApplicationStage]int{
+	models.StageApplied:   1,
+	models.StageScreening: 2,
+	models.StageInterview: 3,
+	models.StageOffer:     4,
+	models.StageHired:     5,
+}
+
+// ValidRevealStage reports whether candidates can be revealed at stage.
+// Revealing at applied would reveal everyone right away.
+func ValidRevealStage(stage models.ApplicationStage) bool {
+	return progress[stage] > progress[models.StageApplied]
+}
+
+// Reached reports whether an application that went through stages ever got
+// to the reveal stage or further.
+func Reached(stages []models.ApplicationStage, reveal models.ApplicationStage) bool {
+	for _, stage := range stages {
+		if progress[stage] > 0 && progress[stage] >= progress[reveal] {
+			return true
+		}
+	}
+	return false
+}
+
+// Stages is every stage an application went through, the current one
+// included.
+func Stages(application models.Application) []models.ApplicationStage {
+	stages := []models.ApplicationStage{application.Stage}
+	for _, event := range application.History {
+		stages = append(stages, event.ToStage)
+	}
+	return stages
+}
+
+// IsPseudonym reports whether an applicant id in a request is a pseudonym.
+func IsPseudonym(id string) bool {
+	return strings.HasPrefix(id, PseudonymPrefix)
+}
+
+// Redact removes the name, email, phone, institutions and resume file name
+// from a profile and puts the pseudonym in place of the applicant id.
+// Skills and experience stay, they are what the candidate is judged on.
+func Redact(profile models.Profile, pseudonym string) models.Profile {
+	profile.Applicant = pseudonym
+	profile.Pseudonym = pseudonym
+	profile.Redacted = true
+	profile.Name = ""
+	profile.Email = ""
+	profile.Phone = ""
+	// education holds the institutions the parser found
+	profile.Education = ""
+	profile.ResumeFileAddress = ""
+	return profile
+}
+
+// RedactSubmission removes the cover letter, free text that may well say who
+// the candidate is. The screening answers stay.
+func RedactSubmission(submission models.ApplicationSubmission) models.ApplicationSubmission {
+	submission.CoverLetter = ""
+	return submission
+}
@@ -0,0 +1,234 @@
+// Package config loads the configuration of the server and the admin command
+// from, in increasing precedence, defaults, an optional YAML or TOML file,
+// the environment (including a .env file) and command line flags.
+//
+// Every setting has a dotted key such as database.host, which is its path in
+// the file and its flag name (-database.host), and usually an environment
+// variable. Settings tagged secret are redacted when the configuration is
+// printed.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"resume-backend-parser/internal/blind"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+)
+
+type Config struct {
+	Port   int    `key:"port" env:"PORT" default:"8080" help:"port the API listens on"`
+	AppEnv string `key:"app_env" env:"APP_ENV" default:"local" help:"name of the environment"`
+	// defaults to http://localhost:<port>
+	AppURL string `key:"app_url" env:"APP_URL" help:"where links in emails point to, e.g. the frontend"`
+	// the key of the third-party resume parser
+	ParserAPIKey         string `key:"parser_api_key" env:"API_KEY" secret:"true" help:"API key of the resume parser"`
+	ParserURL            string `key:"parser_url" env:"PARSER_URL" default:"https://api.apilayer.com/resume_parser/upload" help:"upload endpoint of the resume parser"`
+	MFARequiredForAdmins bool   `key:"mfa_required_for_admins" env:"MFA_REQUIRED_FOR_ADMINS" help:"admins have to log in with a second factor"`
+	// the client IP is taken from X-Forwarded-For only behind these
+	TrustedProxies []string `key:"trusted_proxies" env:"TRUSTED_PROXIES" help:"comma separated IPs or CIDR ranges of the proxies in front of the server"`
+
+	Database    Database    `key:"database"`
+	Keys        Keys        `key:"keys"`
+	Storage     Storage     `key:"storage"`
+	SMTP        SMTP        `key:"smtp"`
+	OIDC        OIDC        `key:"oidc"`
+	Retention   Retention   `key:"retention"`
+	BlindHiring BlindHiring `key:"blind_hiring"`
+	Encryption  Encryption  `key:"encryption"`
+	Shutdown    Shutdown    `key:"shutdown"`
+	Health      Health      `key:"health"`
+	Logging     Logging     `key:"logging"`
+	Metrics     Metrics     `key:"metrics"`
+	Tracing     Tracing     `key:"tracing"`
+
+	// where every setting came from, see Print
+	sources map[string]string
+}
+
+type Database struct {
+	Host     string `key:"host" env:"DB_HOST" default:"localhost" help:"database host"`
+	Port     int    `key:"port" env:"DB_PORT" default:"5432" help:"database port"`
+	Name     string `key:"name" env:"DB_DATABASE" help:"database name"`
+	Username string `key:"username" env:"DB_USERNAME" help:"database user"`
+	Password string `key:"password" env:"DB_PASSWORD" secret:"true" help:"database password"`
+	Schema   string `key:"schema" env:"DB_SCHEMA" default:"public" help:"database schema"`
+	SSLMode  string `key:"sslmode" env:"DB_SSLMODE" help:"sslmode of the connection, the driver's default when empty"`
+}
+
+// ConnString is the connection string for lib/pq.
+func (d Database) ConnString() string {
+	quote := func(v string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.Username), quote(d.Password), quote(d.Name), quote(d.SSLMode))
+}
+
+// Keys are the RSA keys tokens are signed with, see the README.
+type Keys struct {
+	JWTPrivateKey string `key:"jwt_private_key" env:"JWT_PRIVATE_KEY" default:"keys/app.rsa" help:"PEM file of the RSA key tokens are signed with"`
+	JWTPublicKey  string `key:"jwt_public_key" env:"JWT_PUBLIC_KEY" default:"keys/app.rsa.pub" help:"PEM file of the RSA public key tokens are verified with"`
+}
+
+type Storage struct {
+	ResumesDir string `key:"resumes_dir" env:"RESUMES_DIR" default:"resumes" help:"directory the uploaded resumes are stored in"`
+}
+
+// SMTP is off without a host; emails aren't sent then.
+type SMTP struct {
+	Host     string        `key:"host" env:"SMTP_HOST" help:"SMTP server, emails are not sent when empty"`
+	Port     int           `key:"port" env:"SMTP_PORT" default:"25" help:"SMTP port"`
+	From     string        `key:"from" env:"SMTP_FROM" help:"sender address of emails"`
+	Username string        `key:"username" env:"SMTP_USERNAME" help:"SMTP user, no authentication when empty"`
+	Password string        `key:"password" env:"SMTP_PASSWORD" secret:"true" help:"SMTP password"`
+	Timeout  time.Duration `key:"timeout" env:"SMTP_TIMEOUT" default:"10s" help:"how long sending an email may take"`
+}
+
+// OIDC is single sign-on, off without an issuer.
+type OIDC struct {
+	Issuer       string `key:"issuer" env:"OIDC_ISSUER" help:"issuer URL of the identity provider, SSO is off when empty"`
+	ClientID     string `key:"client_id" env:"OIDC_CLIENT_ID" help:"client id at the identity provider"`
+	ClientSecret string `key:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true" help:"client secret at the identity provider"`
+	// defaults to http://localhost:<port>/auth/oidc/callback
+	RedirectURL string          `key:"redirect_url" env:"OIDC_REDIRECT_URL" help:"callback URL registered at the identity provider"`
+	Scopes      []string        `key:"scopes" env:"OIDC_SCOPES" sep:" " help:"space separated scopes to request"`
+	GroupsClaim string          `key:"groups_claim" env:"OIDC_GROUPS_CLAIM" help:"claim holding the groups of the user"`
+	AdminGroups []string        `key:"admin_groups" env:"OIDC_ADMIN_GROUPS" help:"comma separated groups whose members are admins"`
+	DefaultRole models.UserType `key:"default_role" env:"OIDC_DEFAULT_ROLE" default:"Applicant" help:"role of users in none of the admin groups, Applicant or Admin"`
+}
+
+type Retention struct {
+	Rules    retention.Rules `key:"rules" env:"RETENTION_RULES" help:"retention rules as action:subject:months, comma separated"`
+	Interval time.Duration   `key:"interval" env:"RETENTION_INTERVAL" default:"24h" help:"how often the retention rules are applied"`
+}
+
+type BlindHiring struct {
+	RevealStage models.ApplicationStage `key:"reveal_stage" env:"BLIND_HIRING_REVEAL_STAGE" default:"interview" help:"stage at which blind hiring reveals candidates"`
+}
+
+// Encryption of personal data is off without a keyfile.
+type Encryption struct {
+	Keyfile           string        `key:"keyfile" env:"ENCRYPTION_KEYFILE" help:"keyfile for encrypting personal data and resumes, plaintext when empty"`
+	ReencryptInterval time.Duration `key:"reencrypt_interval" env:"ENCRYPTION_REENCRYPT_INTERVAL" default:"1h" help:"how often stale data is re-encrypted"`
+}
+
+// Shutdown is what happens on SIGINT or SIGTERM: /readyz reports the server as
+// down for Delay, so load balancers stop sending requests, then the requests
+// in flight get up to Timeout to finish.
+type Shutdown struct {
+	Delay   time.Duration `key:"delay" env:"SHUTDOWN_DELAY" default:"0s" help:"how long /readyz reports shutting down before the listener closes"`
+	Timeout time.Duration `key:"timeout" env:"SHUTDOWN_TIMEOUT" default:"30s" help:"how long requests in flight and background jobs get to finish"`
+}
+
+// Health is about the checks of /readyz.
+type Health struct {
+	Timeout  time.Duration `key:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s" help:"how long a component may take to answer its readiness check"`
+	CacheFor time.Duration `key:"cache_for" env:"HEALTH_CHECK_CACHE" default:"5s" help:"how long the result of a readiness check is reused"`
+}
+
+type LogFormat string
+
+const (
+	TextLogs LogFormat = "text"
+	JSONLogs LogFormat = "json"
+)
+
+type Logging struct {
+	Format LogFormat  `key:"format" env:"LOG_FORMAT" default:"text" help:"format of the log, text or json"`
+	Level  slog.Level `key:"level" env:"LOG_LEVEL" default:"info" help:"lowest level logged, debug, info, warn or error"`
+	Source bool       `key:"source" env:"LOG_SOURCE" help:"add the source file and line to log records"`
+}
+
+// Metrics is about /metrics, which is open to anyone without a token.
+type Metrics struct {
+	Token string `key:"token" env:"METRICS_TOKEN" secret:"true" help:"bearer token /metrics requires, open when empty"`
+}
+
+type TraceExporter string
+
+const (
+	NoTraces     TraceExporter = "none"
+	StdoutTraces TraceExporter = "stdout"
+	OTLPTraces   TraceExporter = "otlp"
+)
+
+// Tracing is about the OpenTelemetry spans of requests, queries, resume
+// writes and parser calls.
+type Tracing struct {
+	Exporter    TraceExporter `key:"exporter" env:"TRACING_EXPORTER" default:"none" help:"where spans go: none, stdout or otlp"`
+	Endpoint    string        `key:"endpoint" env:"TRACING_ENDPOINT" default:"localhost:4318" help:"host:port of the OTLP/HTTP collector"`
+	Insecure    bool          `key:"insecure" env:"TRACING_INSECURE" help:"send spans to the collector over plain HTTP"`
+	SampleRatio float64       `key:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" help:"share of traces started here that are recorded, from 0 to 1"`
+	ServiceName string        `key:"service_name" env:"TRACING_SERVICE_NAME" default:"resume-backend-parser" help:"service name spans are reported under"`
+}
+
+// Validate checks the settings and fills in the ones derived from others.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535")
+	check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port must be between 1 and 65535")
+	check(c.SMTP.Port > 0 && c.SMTP.Port < 65536, "smtp.port must be between 1 and 65535")
+	check(c.Retention.Interval >= time.Minute, "retention.interval must be at least 1m")
+	check(c.Encryption.ReencryptInterval >= time.Minute, "encryption.reencrypt_interval must be at least 1m")
+	check(c.Shutdown.Delay >= 0, "shutdown.delay must not be negative")
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout must be positive")
+	c.Logging.Format = LogFormat(strings.ToLower(string(c.Logging.Format)))
+	check(c.Logging.Format == TextLogs || c.Logging.Format == JSONLogs, "logging.format must be text or json")
+	c.Tracing.Exporter = TraceExporter(strings.ToLower(string(c.Tracing.Exporter)))
+	check(c.Tracing.Exporter == NoTraces || c.Tracing.Exporter == StdoutTraces || c.Tracing.Exporter == OTLPTraces,
+		"tracing.exporter must be none, stdout or otlp")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	check(c.Tracing.Exporter != OTLPTraces || c.Tracing.Endpoint != "", "tracing.endpoint is required to export over OTLP")
+	for _, proxy := range c.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "trusted_proxies: %q is neither an IP nor a CIDR range", proxy)
+	}
+	check(c.SMTP.Timeout > 0, "smtp.timeout must be positive")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+	check(c.Health.CacheFor >= 0, "health.cache_for must not be negative")
+	check(blind.ValidRevealStage(c.BlindHiring.RevealStage),
+		"blind_hiring.reveal_stage must be one of screening, interview, offer, hired")
+	check(c.Keys.JWTPrivateKey != "" && c.Keys.JWTPublicKey != "", "keys.jwt_private_key and keys.jwt_public_key are required")
+	check(c.Storage.ResumesDir != "", "storage.resumes_dir is required")
+
+	if c.AppURL == "" {
+		c.AppURL = fmt.Sprintf("http://localhost:%d", c.Port)
+	}
+	c.AppURL = strings.TrimSuffix(c.AppURL, "/")
+	check(absoluteURL(c.AppURL), "app_url must be an absolute http(s) URL")
+
+	if c.OIDC.Issuer != "" {
+		check(absoluteURL(c.OIDC.Issuer), "oidc.issuer must be an absolute http(s) URL")
+		check(c.OIDC.ClientID != "", "oidc.client_id is required with oidc.issuer")
+		if c.OIDC.RedirectURL == "" {
+			c.OIDC.RedirectURL = fmt.Sprintf("http://localhost:%d/auth/oidc/callback", c.Port)
+		}
+		check(absoluteURL(c.OIDC.RedirectURL), "oidc.redirect_url must be an absolute http(s) URL")
+	}
+	switch {
+	case strings.EqualFold(string(c.OIDC.DefaultRole), string(models.Admin)):
+		c.OIDC.DefaultRole = models.Admin
+	case strings.EqualFold(string(c.OIDC.DefaultRole), string(models.Applicant)):
+		c.OIDC.DefaultRole = models.Applicant
+	default:
+		check(false, "oidc.default_role must be Applicant or Admin")
+	}
+	return errors.Join(errs...)
+}
+
+func absoluteURL(v string) bool {
+	u, err := url.Parse(v)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
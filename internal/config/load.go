@@ -0,0 +1,294 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	_ "github.com/joho/godotenv/autoload"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a single value of Config, as described by its tags.
+type setting struct {
+	key    string
+	env    string
+	def    string
+	help   string
+	sep    string
+	secret bool
+	value  reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// settings lists every setting of c in the order of the struct fields.
+func (c *Config) settings() []setting {
+	var all []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key, ok := field.Tag.Lookup("key")
+			if !ok {
+				continue
+			}
+			key = prefix + key
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			sep := field.Tag.Get("sep")
+			if sep == "" {
+				sep = ","
+			}
+			all = append(all, setting{key: key, env: field.Tag.Get("env"), def: field.Tag.Get("default"),
+				help: field.Tag.Get("help"), sep: sep, secret: field.Tag.Get("secret") == "true", value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return all
+}
+
+func (s setting) set(v string) error {
+	if u, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(v))
+	}
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 90s or 1h", v)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(strings.TrimSpace(v))
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		s.value.SetFloat(f)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		items := reflect.MakeSlice(s.value.Type(), 0, 0)
+		for _, item := range strings.Split(v, s.sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(s.value.Type().Elem()))
+			}
+		}
+		s.value.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+	return nil
+}
+
+// String formats the value the way set reads it.
+func (s setting) String() string {
+	if stringer, ok := s.value.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	if s.value.Kind() == reflect.Slice {
+		items := make([]string, s.value.Len())
+		for i := range items {
+			items[i] = s.value.Index(i).String()
+		}
+		return strings.Join(items, s.sep)
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// Load reads the configuration. It registers a flag for every setting, plus
+// -config for the file (CONFIG_FILE by default), on flags and parses args
+// with them. Callers can add flags of their own beforehand.
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	c := &Config{sources: map[string]string{}}
+	all := c.settings()
+	for _, s := range all {
+		c.sources[s.key] = "default"
+		if s.def == "" {
+			continue
+		}
+		if err := s.set(s.def); err != nil {
+			return nil, fmt.Errorf("default of %s: %w", s.key, err)
+		}
+	}
+
+	// flags take precedence over everything else, so they are only applied
+	// once the file and environment are
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var fromFlags []flagValue
+	for _, s := range all {
+		s := s
+		help := s.help
+		if s.env != "" {
+			help += " ($" + s.env + ")"
+		}
+		flags.Var(flagFunc{isBool: s.value.Kind() == reflect.Bool, set: func(v string) error {
+			fromFlags = append(fromFlags, flagValue{s, v})
+			return nil
+		}}, s.key, help)
+	}
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file ($CONFIG_FILE)")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		values, err := readFile(*file)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range all {
+			v, ok := values[s.key]
+			if !ok {
+				continue
+			}
+			delete(values, s.key)
+			if err = s.set(fileValue(v, s.sep)); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", *file, s.key, err)
+			}
+			c.sources[s.key] = "file"
+		}
+		for key := range values {
+			return nil, fmt.Errorf("%s: unknown setting %s", *file, key)
+		}
+	}
+	for _, s := range all {
+		// empty variables count as unset, as in most .env files
+		if v := os.Getenv(s.env); s.env != "" && v != "" {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+			c.sources[s.key] = "env " + s.env
+		}
+	}
+	for _, f := range fromFlags {
+		if err := f.setting.set(f.value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", f.setting.key, err)
+		}
+		c.sources[f.setting.key] = "flag"
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// flagFunc is flag.Func that also lets booleans be given as -name alone.
+type flagFunc struct {
+	isBool bool
+	set    func(string) error
+}
+
+func (f flagFunc) String() string     { return "" }
+func (f flagFunc) Set(v string) error { return f.set(v) }
+func (f flagFunc) IsBoolFlag() bool   { return f.isBool }
+
+// readFile reads a YAML or TOML file, told apart by the extension, into a map
+// from the dotted keys to the values.
+func readFile(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &tree)
+	case ".toml":
+		err = toml.Unmarshal(b, &tree)
+	default:
+		return nil, fmt.Errorf("%s: configuration files have to be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := map[string]interface{}{}
+	var flatten func(tree map[string]interface{}, prefix string)
+	flatten = func(tree map[string]interface{}, prefix string) {
+		for key, v := range tree {
+			if sub, ok := v.(map[string]interface{}); ok {
+				flatten(sub, prefix+key+".")
+				continue
+			}
+			values[prefix+key] = v
+		}
+	}
+	flatten(tree, "")
+	return values, nil
+}
+
+// fileValue formats a value from a file for setting.set. Lists may be written
+// as lists or as separated strings.
+func fileValue(v interface{}, sep string) string {
+	if list, ok := v.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, sep)
+	}
+	return fmt.Sprint(v)
+}
+
+// shown is the value of a setting as Print and LogValue show it.
+func (s setting) shown() string {
+	v := s.String()
+	if s.secret && v != "" {
+		return "[redacted]"
+	}
+	return v
+}
+
+// Print writes the effective configuration, one setting per line with where
+// it came from. Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	for _, s := range c.settings() {
+		v := s.shown()
+		source := c.sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+		if _, err := fmt.Fprintf(w, "%-36s = %-40q # %s\n", s.key, v, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogValue is the effective configuration as a log attribute, a group of the
+// settings by key. Secrets are redacted as by Print.
+func (c *Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, len(settings))
+	for i, s := range settings {
+		attrs[i] = slog.String(s.key, s.shown())
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+const apiKeyColumns = "id, name, scopes, created_by, created_at, expires_at, last_used_at, coalesce(last_used_ip, ''), revoked_at"
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.Id, &key.Name, pq.Array(&key.Scopes), &key.CreatedBy, &key.CreatedAt, &key.ExpiresAt,
+		&lastUsedAt, &key.LastUsedIP, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+func (s *service) CreateAPIKey(key models.APIKey, hash string) (models.APIKey, error) {
+	query := `INSERT INTO api_keys (id, name, key_hash, scopes, created_by, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + apiKeyColumns
+	return scanAPIKey(s.db.QueryRow(query, key.Id, key.Name, hash, pq.Array(key.Scopes), key.CreatedBy, key.ExpiresAt))
+}
+
+func (s *service) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := s.db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyAuth returns the key with the given id together with the email
+// of the admin it acts for. Keys of deactivated users are not found.
+func (s *service) GetAPIKeyAuth(id string) (models.APIKeyAuth, error) {
+	query := `SELECT k.id, k.key_hash, k.scopes, k.expires_at, k.revoked_at IS NOT NULL, u.email
+        FROM api_keys k JOIN users u ON u.id = k.created_by
+        WHERE k.id = $1 AND u.active`
+	var key models.APIKeyAuth
+	err := s.db.QueryRow(query, id).Scan(&key.Id, &key.Hash, pq.Array(&key.Scopes), &key.ExpiresAt, &key.Revoked,
+		&key.OwnerEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKeyAuth{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (s *service) TouchAPIKey(id string, at time.Time, ip string) error {
+	_, err := s.db.Exec("UPDATE api_keys SET last_used_at = $1, last_used_ip = $2 WHERE id = $3", at, ip, id)
+	return err
+}
+
+// RevokeAPIKey stops a key from working. Revoking twice keeps the first
+// revocation time.
+func (s *service) RevokeAPIKey(id string) (models.APIKey, error) {
+	query := "UPDATE api_keys SET revoked_at = coalesce(revoked_at, now()) WHERE id = $1 RETURNING " + apiKeyColumns
+	key, err := scanAPIKey(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+var (
+	ErrAlreadyApplied      = errors.New("Already applied to this job")
+	ErrApplicationNotFound = errors.New("Application not found")
+	ErrApplicationClosed   = errors.New("Application is closed")
+)
+
+// recordStageChange appends a stage transition to the application's history.
+// from is empty for the very first event and changedBy is 0 for changes the
+// system made on its own, such as screening knockouts.
+func recordStageChange(tx txConn, applicationId int, from models.ApplicationStage, to models.ApplicationStage, changedBy int, at time.Time) error {
+	query := "INSERT INTO application_events (application_id, from_stage, to_stage, changed_by, changed_at) VALUES ($1, $2, $3, $4, $5)"
+	by := sql.NullInt64{Int64: int64(changedBy), Valid: changedBy != 0}
+	_, err := tx.Exec(query, applicationId, nullIfEmpty(string(from)), to, by, at)
+	return err
+}
+
+// ApplyJob records an application with its cover letter and screening answers
+// and adds the user to the job's active applicants. Applying again after
+// withdrawing reopens the old application. Knocked out applications are
+// rejected straight away.
+func (s *service) ApplyJob(jobId int, userId int, submission models.ApplicationSubmission) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// locking the job serializes concurrent applications to it
+	var id int
+	err = tx.QueryRow("SELECT id FROM jobs WHERE id = $1 FOR UPDATE", jobId).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var applicationId int
+	var stage models.ApplicationStage
+	query := "SELECT id, stage FROM applications WHERE job_id = $1 AND applicant = $2"
+	err = tx.QueryRow(query, jobId, userId).Scan(&applicationId, &stage)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		stage = ""
+		query = "INSERT INTO applications (job_id, applicant, stage, applied_at, updated_at) VALUES ($1, $2, $3, $4, $4) RETURNING id"
+		err = tx.QueryRow(query, jobId, userId, models.StageApplied, now).Scan(&applicationId)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case stage != models.StageWithdrawn:
+		return ErrAlreadyApplied
+	default:
+		query = "UPDATE applications SET stage = $1, applied_at = $2, updated_at = $2, withdrawn_at = NULL WHERE id = $3"
+		_, err = tx.Exec(query, models.StageApplied, now, applicationId)
+		if err != nil {
+			return err
+		}
+	}
+	err = recordStageChange(tx, applicationId, stage, models.StageApplied, userId, now)
+	if err != nil {
+		return err
+	}
+	err = saveSubmission(tx, applicationId, submission)
+	if err != nil {
+		return err
+	}
+	if submission.KnockedOut {
+		_, err = tx.Exec("UPDATE applications SET stage = $1 WHERE id = $2", models.StageRejected, applicationId)
+		if err != nil {
+			return err
+		}
+		err = recordStageChange(tx, applicationId, models.StageApplied, models.StageRejected, 0, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = "UPDATE jobs SET applicants = array_append(applicants, $1) WHERE id = $2"
+	_, err = tx.Exec(query, userId, jobId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockApplication loads an application for update within tx.
+func lockApplication(tx txConn, jobId int, userId int) (int, models.ApplicationStage, error) {
+	var applicationId int
+	var stage models.ApplicationStage
+	query := "SELECT id, stage FROM applications WHERE job_id = $1 AND applicant = $2 FOR UPDATE"
+	err := tx.QueryRow(query, jobId, userId).Scan(&applicationId, &stage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrApplicationNotFound
+	}
+	return applicationId, stage, err
+}
+
+// WithdrawApplication marks the application as withdrawn and takes the user
+// off the job's active applicants. The application and its history are kept.
+func (s *service) WithdrawApplication(jobId int, userId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	applicationId, stage, err := lockApplication(tx, jobId, userId)
+	if err != nil {
+		return err
+	}
+	switch stage {
+	case models.StageWithdrawn:
+		return ErrApplicationNotFound
+	case models.StageHired, models.StageRejected:
+		return ErrApplicationClosed
+	}
+
+	now := time.Now().UTC()
+	query := "UPDATE applications SET stage = $1, updated_at = $2, withdrawn_at = $2 WHERE id = $3"
+	_, err = tx.Exec(query, models.StageWithdrawn, now, applicationId)
+	if err != nil {
+		return err
+	}
+	err = recordStageChange(tx, applicationId, stage, models.StageWithdrawn, userId, now)
+	if err != nil {
+		return err
+	}
+	query = "UPDATE jobs SET applicants = array_remove(applicants, $1) WHERE id = $2"
+	_, err = tx.Exec(query, userId, jobId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateApplicationStage moves an application along the hiring pipeline on
+// behalf of an admin. Withdrawn applications can only be reopened by the
+// applicant.
+func (s *service) UpdateApplicationStage(jobId int, userId int, stage models.ApplicationStage, changedBy int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	applicationId, current, err := lockApplication(tx, jobId, userId)
+	if err != nil {
+		return err
+	}
+	if current == models.StageWithdrawn {
+		return ErrApplicationClosed
+	}
+	if current == stage {
+		return nil
+	}
+	now := time.Now().UTC()
+	_, err = tx.Exec("UPDATE applications SET stage = $1, updated_at = $2 WHERE id = $3", stage, now, applicationId)
+	if err != nil {
+		return err
+	}
+	err = recordStageChange(tx, applicationId, current, stage, changedBy, now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetApplications returns all applications of a user, withdrawn ones
+// included, newest first and with their stage history.
+func (s *service) GetApplications(userId int) ([]models.Application, error) {
+	query := `SELECT id, job_id, applicant, stage, applied_at, updated_at, withdrawn_at
+        FROM applications WHERE applicant = $1 ORDER BY applied_at DESC`
+	rows, err := s.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applications := []models.Application{}
+	var jobIds, applicationIds []int64
+	for rows.Next() {
+		var application models.Application
+		var withdrawnAt sql.NullTime
+		err = rows.Scan(&application.Id, &application.Job.Id, &application.Applicant, &application.Stage,
+			&application.AppliedAt, &application.UpdatedAt, &withdrawnAt)
+		if err != nil {
+			return nil, err
+		}
+		if withdrawnAt.Valid {
+			application.WithdrawnAt = &withdrawnAt.Time
+		}
+		application.History = []models.ApplicationEvent{}
+		applications = append(applications, application)
+		jobIds = append(jobIds, int64(application.Job.Id))
+		applicationIds = append(applicationIds, int64(application.Id))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(applications) == 0 {
+		return applications, nil
+	}
+
+	jobs := map[int]models.Job{}
+	jobRows, err := s.db.Query("SELECT "+jobColumns+" FROM jobs WHERE id = ANY($1)", pq.Array(jobIds))
+	if err != nil {
+		return nil, err
+	}
+	defer jobRows.Close()
+	for jobRows.Next() {
+		job, err := scanJob(jobRows)
+		if err != nil {
+			return nil, err
+		}
+		jobs[job.Id] = job
+	}
+	if err = jobRows.Err(); err != nil {
+		return nil, err
+	}
+
+	history := map[int][]models.ApplicationEvent{}
+	query = `SELECT application_id, coalesce(from_stage::text, ''), to_stage, changed_by, changed_at
+        FROM application_events WHERE application_id = ANY($1) ORDER BY changed_at, id`
+	eventRows, err := s.db.Query(query, pq.Array(applicationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer eventRows.Close()
+	for eventRows.Next() {
+		var applicationId int
+		var event models.ApplicationEvent
+		var changedBy sql.NullInt64
+		err = eventRows.Scan(&applicationId, &event.FromStage, &event.ToStage, &changedBy, &event.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		if changedBy.Valid {
+			by := int(changedBy.Int64)
+			event.ChangedBy = &by
+		}
+		history[applicationId] = append(history[applicationId], event)
+	}
+	if err = eventRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range applications {
+		applications[i].Job = jobs[applications[i].Job.Id]
+		if events, ok := history[applications[i].Id]; ok {
+			applications[i].History = events
+		}
+	}
+	return applications, nil
+}
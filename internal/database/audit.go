@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"resume-backend-parser/internal/models"
+)
+
+const auditColumns = `id, occurred_at, action, actor_id, coalesce(actor_email, ''), coalesce(api_key_id, ''),
+    coalesce(target_type, ''), coalesce(target_id, ''), ip, request_id, details`
+
+func scanAuditEntry(row rowScanner) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var actorId sql.NullInt64
+	var details []byte
+	err := row.Scan(&entry.Id, &entry.OccurredAt, &entry.Action, &actorId, &entry.ActorEmail, &entry.APIKeyId,
+		&entry.TargetType, &entry.TargetId, &entry.IP, &entry.RequestId, &details)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	entry.ActorId = int(actorId.Int64)
+	if len(details) > 0 {
+		if err = json.Unmarshal(details, &entry.Details); err != nil {
+			return models.AuditEntry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// AppendAuditEntry adds an entry to the audit log. Without an actor id the
+// actor is looked up by email, if there is an account for it.
+func (s *service) AppendAuditEntry(entry models.AuditEntry) error {
+	var details []byte
+	if len(entry.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(entry.Details); err != nil {
+			return err
+		}
+	}
+	actorId := sql.NullInt64{Int64: int64(entry.ActorId), Valid: entry.ActorId != 0}
+	query := `INSERT INTO audit_log (occurred_at, action, actor_id, actor_email, api_key_id, target_type, target_id,
+            ip, request_id, details)
+        VALUES ($1, $2, coalesce($3, (SELECT id FROM users WHERE email = $4 ORDER BY id LIMIT 1)), $4, $5, $6, $7,
+            $8, $9, $10)`
+	_, err := s.db.Exec(query, entry.OccurredAt, entry.Action, actorId, nullIfEmpty(entry.ActorEmail),
+		nullIfEmpty(entry.APIKeyId), nullIfEmpty(entry.TargetType), nullIfEmpty(entry.TargetId), entry.IP,
+		entry.RequestId, details)
+	return err
+}
+
+func auditFilterClause(filters models.AuditFilters) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if filters.Action != "" {
+		where = append(where, "action = "+arg(filters.Action))
+	}
+	if filters.UserId != 0 {
+		userId := arg(filters.UserId)
+		where = append(where, "(actor_id = "+userId+" OR (target_type = 'user' AND target_id = "+userId+"::text))")
+	}
+	if filters.ActorId != 0 {
+		where = append(where, "actor_id = "+arg(filters.ActorId))
+	}
+	if filters.ActorEmail != "" {
+		where = append(where, "lower(actor_email) = lower("+arg(filters.ActorEmail)+")")
+	}
+	if filters.TargetType != "" {
+		where = append(where, "target_type = "+arg(filters.TargetType))
+	}
+	if filters.TargetId != "" {
+		where = append(where, "target_id = "+arg(filters.TargetId))
+	}
+	if filters.IP != "" {
+		where = append(where, "ip = "+arg(filters.IP))
+	}
+	if filters.RequestId != "" {
+		where = append(where, "request_id = "+arg(filters.RequestId))
+	}
+	if filters.From != nil {
+		where = append(where, "occurred_at >= "+arg(*filters.From))
+	}
+	if filters.To != nil {
+		where = append(where, "occurred_at < "+arg(*filters.To))
+	}
+	if len(where) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// ListAuditEntries returns the newest entries first.
+func (s *service) ListAuditEntries(filters models.AuditFilters, limit int, offset int) ([]models.AuditEntry, error) {
+	where, args := auditFilterClause(filters)
+	args = append(args, limit, offset)
+	query := "SELECT " + auditColumns + " FROM audit_log" + where +
+		" ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+	entries := []models.AuditEntry{}
+	err := s.queryAuditEntries(query, args, func(entry models.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *service) CountAuditEntries(filters models.AuditFilters) (int, error) {
+	where, args := auditFilterClause(filters)
+	var total int
+	err := s.db.QueryRow("SELECT count(*) FROM audit_log"+where, args...).Scan(&total)
+	return total, err
+}
+
+// ExportAuditEntries streams every matching entry, oldest first, to fn.
+func (s *service) ExportAuditEntries(filters models.AuditFilters, fn func(models.AuditEntry) error) error {
+	where, args := auditFilterClause(filters)
+	return s.queryAuditEntries("SELECT "+auditColumns+" FROM audit_log"+where+" ORDER BY id", args, fn)
+}
+
+func (s *service) queryAuditEntries(query string, args []interface{}, fn func(models.AuditEntry) error) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
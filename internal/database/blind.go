@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+// SetJobBlindHiring changes the blind hiring settings of a job, sql.ErrNoRows
+// if there is no such job.
+func (s *service) SetJobBlindHiring(jobId int, settings models.BlindHiring) error {
+	result, err := s.db.Exec("UPDATE jobs SET blind_hiring = $1, blind_reveal_stage = $2 WHERE id = $3",
+		settings.Enabled, nullIfEmpty(string(settings.RevealStage)), jobId)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPseudonyms returns the pseudonyms of the users, keyed by id.
+func (s *service) GetPseudonyms(ids []int) (map[int]string, error) {
+	userIds := make([]int64, len(ids))
+	for i, id := range ids {
+		userIds[i] = int64(id)
+	}
+	rows, err := s.db.Query("SELECT id, pseudonym FROM users WHERE id = ANY($1)", pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pseudonyms := map[int]string{}
+	for rows.Next() {
+		var id int
+		var pseudonym string
+		if err = rows.Scan(&id, &pseudonym); err != nil {
+			return nil, err
+		}
+		pseudonyms[id] = pseudonym
+	}
+	return pseudonyms, rows.Err()
+}
+
+func (s *service) GetUserIdByPseudonym(pseudonym string) (int, error) {
+	var id int
+	err := s.db.QueryRow("SELECT id FROM users WHERE pseudonym = $1", pseudonym).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	return id, err
+}
+
+// GetJobApplicationStages returns every stage the applications to a job went
+// through, the current one included, keyed by applicant id.
+func (s *service) GetJobApplicationStages(jobId int) (map[int][]models.ApplicationStage, error) {
+	query := `SELECT a.applicant, a.stage FROM applications a WHERE a.job_id = $1
+        UNION ALL
+        SELECT a.applicant, e.to_stage FROM application_events e JOIN applications a ON a.id = e.application_id
+        WHERE a.job_id = $1`
+	rows, err := s.db.Query(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stages := map[int][]models.ApplicationStage{}
+	for rows.Next() {
+		var applicant int
+		var stage models.ApplicationStage
+		if err = rows.Scan(&applicant, &stage); err != nil {
+			return nil, err
+		}
+		stages[applicant] = append(stages[applicant], stage)
+	}
+	return stages, rows.Err()
+}
+
+func (s *service) IsBlindReviewer(email string) (bool, error) {
+	var reviewer bool
+	err := s.db.QueryRow("SELECT blind_reviewer FROM users WHERE email = $1", email).Scan(&reviewer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return reviewer, err
+}
+
+func (s *service) SetBlindReviewer(id int, reviewer bool) error {
+	result, err := s.db.Exec("UPDATE users SET blind_reviewer = $1 WHERE id = $2", reviewer, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"resume-backend-parser/internal/models"
+)
+
+// candidateQuery accumulates the WHERE clause and positional arguments of a
+// candidate search.
+type candidateQuery struct {
+	from   string
+	where  []string
+	args   []interface{}
+	ranked bool
+}
+
+func (q *candidateQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *candidateQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// exactMatch matches a column that may be encrypted through its blind index.
+// Rows written before encryption was turned on have no index yet and are
+// compared in plaintext until they are re-encrypted.
+func (q *candidateQuery) exactMatch(column string, index string, plain string) string {
+	if index == "" {
+		return plain
+	}
+	return "(" + column + "_bidx = " + q.arg(index) + " OR (" + column + "_bidx IS NULL AND " + plain + "))"
+}
+
+func (s *service) newCandidateQuery(filters models.CandidateSearchFilters) (*candidateQuery, error) {
+	q := &candidateQuery{from: "profile"}
+	if strings.TrimSpace(filters.Query) != "" {
+		tsQuery, err := ToTSQuery(filters.Query)
+		if err != nil {
+			return nil, err
+		}
+		q.from = "profile, to_tsquery('english', " + q.arg(tsQuery) + ") query"
+		q.where = append(q.where, "search_vector @@ query")
+		q.ranked = true
+	}
+	for _, skill := range filters.Skills {
+		skill = strings.TrimSpace(skill)
+		if skill == "" {
+			continue
+		}
+		q.where = append(q.where, "EXISTS (SELECT 1 FROM unnest(skills) skill WHERE lower(skill) = lower("+q.arg(skill)+"))")
+	}
+	if filters.Education != "" {
+		q.where = append(q.where, "array_to_string(education, ' ') ILIKE '%' || "+q.arg(escapeLike(filters.Education))+" || '%'")
+	}
+	if filters.Experience != "" {
+		q.where = append(q.where, "array_to_string(experience, ' ') ILIKE '%' || "+q.arg(escapeLike(filters.Experience))+" || '%'")
+	}
+	if filters.Name != "" {
+		q.where = append(q.where, "name ILIKE '%' || "+q.arg(escapeLike(filters.Name))+" || '%'")
+	}
+	if email := normalizeEmail(filters.Email); email != "" {
+		q.where = append(q.where, q.exactMatch("email", s.cipher.BlindIndex(profileEmail, email),
+			"lower(trim(email)) = "+q.arg(email)))
+	}
+	if phone := normalizePhone(filters.Phone); phone != "" {
+		q.where = append(q.where, q.exactMatch("phone", s.cipher.BlindIndex(profilePhone, phone),
+			"regexp_replace(phone, '[^0-9]', '', 'g') = "+q.arg(phone)))
+	}
+	return q, nil
+}
+
+var candidateHighlightFields = []string{"skills", "education", "experience", "resumeText"}
+
+func (s *service) SearchCandidates(filters models.CandidateSearchFilters, limit int, offset int) ([]models.CandidateSearchResult, error) {
+	q, err := s.newCandidateQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+	extra := "0::float8 AS rank, '', '', '', ''"
+	if q.ranked {
+		options := q.arg(headlineOptions + ", MaxFragments=2, MaxWords=15, MinWords=3")
+		extra = "ts_rank_cd(search_vector, query) AS rank, " +
+			"ts_headline('english', coalesce(array_to_string(skills, ', '), ''), query, " + options + "), " +
+			"ts_headline('english', coalesce(array_to_string(education, ', '), ''), query, " + options + "), " +
+			"ts_headline('english', coalesce(array_to_string(experience, ', '), ''), query, " + options + "), " +
+			"ts_headline('english', coalesce(resume_text, ''), query, " + options + ")"
+	}
+	query := "SELECT " + profileColumns + ", " + extra + " FROM " + q.from + q.whereClause() +
+		" ORDER BY rank DESC, updated_at DESC NULLS LAST, applicant LIMIT " + q.arg(limit) + " OFFSET " + q.arg(offset)
+	rows, err := s.db.Query(query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []models.CandidateSearchResult{}
+	for rows.Next() {
+		var result models.CandidateSearchResult
+		highlights := make([]string, len(candidateHighlightFields))
+		result.Profile, err = s.scanProfile(rows, &result.Rank, &highlights[0], &highlights[1], &highlights[2], &highlights[3])
+		if err != nil {
+			return nil, err
+		}
+		for i, field := range candidateHighlightFields {
+			// ts_headline returns the start of the text even when nothing matched
+			if strings.Contains(highlights[i], "<mark>") {
+				if result.Highlights == nil {
+					result.Highlights = map[string]string{}
+				}
+				result.Highlights[field] = highlights[i]
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *service) CountCandidateSearchResults(filters models.CandidateSearchFilters) (int, error) {
+	q, err := s.newCandidateQuery(filters)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	err = s.db.QueryRow("SELECT count(*) FROM "+q.from+q.whereClause(), q.args...).Scan(&total)
+	return total, err
+}
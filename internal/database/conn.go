@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"resume-backend-parser/internal/metrics"
+	"resume-backend-parser/internal/tracing"
+)
+
+// conn runs queries with the context of its service, so that they are logged
+// with the request id and traced in the request they are for. Queries are
+// logged at the debug level, and measured and traced per Service method.
+type conn struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (c conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := c.DB.QueryContext(c.ctx, query, args...)
+	observe(c.ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRow doesn't count errors, as they only come with Scan.
+func (c conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := c.DB.QueryRowContext(c.ctx, query, args...)
+	observe(c.ctx, query, start, nil)
+	return row
+}
+
+func (c conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := c.DB.ExecContext(c.ctx, query, args...)
+	observe(c.ctx, query, start, err)
+	return result, err
+}
+
+func (c conn) Begin() (txConn, error) {
+	tx, err := c.DB.BeginTx(c.ctx, nil)
+	return txConn{Tx: tx, ctx: c.ctx}, err
+}
+
+// txConn is conn for a transaction.
+type txConn struct {
+	*sql.Tx
+	ctx context.Context
+}
+
+func (t txConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.Tx.QueryContext(t.ctx, query, args...)
+	observe(t.ctx, query, start, err)
+	return rows, err
+}
+
+func (t txConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.Tx.QueryRowContext(t.ctx, query, args...)
+	observe(t.ctx, query, start, nil)
+	return row
+}
+
+func (t txConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.Tx.ExecContext(t.ctx, query, args...)
+	observe(t.ctx, query, start, err)
+	return result, err
+}
+
+// observe measures, traces and logs a query, without its arguments, which
+// may be personal data.
+func observe(ctx context.Context, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	method := serviceMethod()
+	metrics.DBQueryDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+	if err != nil {
+		metrics.DBQueryErrors.WithLabelValues(method).Inc()
+	}
+	tracing.Record(ctx, "database."+method, start, err, trace.SpanKindClient, func() []attribute.KeyValue {
+		return []attribute.KeyValue{semconv.DBSystemPostgreSQL, semconv.DBOperation(method),
+			semconv.DBStatement(compact(query))}
+	})
+
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []interface{}{"method", method, "sql", compact(query), "duration", elapsed}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.DebugContext(ctx, "query", attrs...)
+}
+
+// compact puts a query on one line and cuts it short.
+func compact(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > 200 {
+		query = query[:200] + "..."
+	}
+	return query
+}
+
+// serviceMethod is the Service method up the stack, which queries are
+// measured by.
+func serviceMethod() string {
+	const receiver = "internal/database.(*service)."
+	var pcs [16]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if i := strings.Index(frame.Function, receiver); i >= 0 {
+			name := frame.Function[i+len(receiver):]
+			// unexported methods are helpers of the Service methods
+			if name != "" && unicode.IsUpper(rune(name[0])) {
+				return strings.SplitN(name, ".", 2)[0]
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// WithContext returns the service running its queries with ctx. Only the
+// values of ctx are used: a query isn't cancelled with the request, so that
+// a client going away can't leave work half done.
+func (s *service) WithContext(ctx context.Context) Service {
+	scoped := *s
+	scoped.db = conn{DB: s.db.DB, ctx: context.WithoutCancel(ctx)}
+	return &scoped
+}
+
+// Stats are the statistics of the connection pool.
+func (s *service) Stats() sql.DBStats {
+	return s.db.Stats()
+}
@@ -4,15 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"time"
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/encryption"
 	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
     "errors"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
     pq "github.com/lib/pq" // Import PostgreSQL driver
 
 )
@@ -22,54 +24,139 @@ type Service interface {
 	Health() map[string]string
     CreateUser(name string, email string, password_hash string, address string, profile_headline string) error
     UserExists(email string) (bool, error)
-    CheckUserLogin(email string, password string) (bool, error)
+    GetPasswordHash(email string) (int, string, error)
     IsUserAdmin(email string) (bool, error)
     GetUserId(email string) (int, error)
     UpdateProfile(userId int, resumeFileAddress string) error
-    UpdateProfileWithFields(userId int, profile models.ProfileThirdParty) error
+    UpdateProfileWithFields(userId int, profile models.ProfileThirdParty, resumeText string) error
 
-    CreateJob(title string, description string, companyName string, TotalApplications int, userId int) error
+    CreateJob(job models.Job, userId int) (int, error)
     GetJob(id int) (models.Job, error)
+    UpdateJob(id int, job models.Job) error
+    GetAppliedJobs(userId int) ([]models.Job, error)
+    GetOpenJobs(userId int) ([]models.Job, error)
     GetJobs() ([]models.Job, error)
     GetApplicants(jobId int) ([]models.Profile, error)
+    SearchJobs(query string, limit int, offset int) ([]models.JobSearchResult, error)
+    CountJobSearchResults(query string) (int, error)
 
-    ApplyJob(jobId int, userId int) error
+    ApplyJob(jobId int, userId int, submission models.ApplicationSubmission) error
+    WithdrawApplication(jobId int, userId int) error
+    UpdateApplicationStage(jobId int, userId int, stage models.ApplicationStage, changedBy int) error
+    GetApplications(userId int) ([]models.Application, error)
+    SetScreeningQuestions(jobId int, questions []models.ScreeningQuestion) error
+    GetScreeningQuestions(jobId int) ([]models.ScreeningQuestion, error)
+    GetJobSubmissions(jobId int) (map[int]models.ApplicationSubmission, error)
 
     GetApplicantProfile(userId int) (models.Profile, error)
+    GetUser(email string) (models.User, error)
+    UpdateProfileManual(userId int, update models.UpdateProfileRequest) error
     GetAllApplicants() ([]models.Profile, error)
-
-
+    SearchCandidates(filters models.CandidateSearchFilters, limit int, offset int) ([]models.CandidateSearchResult, error)
+    CountCandidateSearchResults(filters models.CandidateSearchFilters) (int, error)
+
+    GetMatchProfile(userId int) (models.MatchProfile, error)
+    SaveMatchScore(score models.MatchScore) error
+    GetMatchScores(jobId int) ([]models.MatchScore, error)
+
+    ListUsers(filters models.UserFilters, limit int, offset int) ([]models.UserSummary, error)
+    CountUsers(filters models.UserFilters) (int, error)
+    GetUserById(id int) (models.UserSummary, error)
+    CreateAdmin(name string, email string, passwordHash string) (int, error)
+    IsUserActive(email string) (bool, error)
+    SetUserType(id int, userType models.UserType) error
+    SetUserActive(id int, active bool) error
+    SetUserPassword(id int, passwordHash string) error
+    GetLegacyPasswords() (map[int]string, error)
+    ReplacePasswordHash(id int, old string, passwordHash string) error
+    ActiveAdmins() ([]int, error)
+
+    CreateUserToken(id string, userId int, purpose models.TokenPurpose, expiresAt time.Time) error
+    VerifyEmail(tokenId string) (int, error)
+    ResetPassword(tokenId string, passwordHash string) (int, error)
+    IsEmailVerified(userId int) (bool, error)
+
+    GetLoginState(email string) (models.LoginState, error)
+    GetIPLoginFailures(ip string, since time.Time) (int, time.Time, error)
+    RecordLoginAttempt(attempt models.LoginAttempt) error
+    RecordFailedLogin(userId int, at time.Time, threshold int, lockUntil time.Time) error
+    UnlockUser(id int) error
+
+    GetMFA(userId int) (models.MFAState, error)
+    SetMFASecret(userId int, secret string) error
+    EnableMFA(userId int, counter int64, codeHashes []string) error
+    DisableMFA(userId int) error
+    ReplaceRecoveryCodes(userId int, codeHashes []string) error
+    UseTOTPCounter(userId int, counter int64) (bool, error)
+    UseRecoveryCode(userId int, codeHash string) (bool, error)
+    CountRecoveryCodes(userId int) (int, error)
+
+    ProvisionOIDCUser(identity models.OIDCIdentity, userType models.UserType, passwordHash string) (int, bool, error)
+
+    CreateAPIKey(key models.APIKey, hash string) (models.APIKey, error)
+    ListAPIKeys() ([]models.APIKey, error)
+    GetAPIKeyAuth(id string) (models.APIKeyAuth, error)
+    TouchAPIKey(id string, at time.Time, ip string) error
+    RevokeAPIKey(id string) (models.APIKey, error)
+
+    AppendAuditEntry(entry models.AuditEntry) error
+    ListAuditEntries(filters models.AuditFilters, limit int, offset int) ([]models.AuditEntry, error)
+    CountAuditEntries(filters models.AuditFilters) (int, error)
+    ExportAuditEntries(filters models.AuditFilters, fn func(models.AuditEntry) error) error
+
+    GetApplicantSubmissions(applicantId int) (map[int]models.ApplicationSubmission, error)
+    EraseApplicant(id int, erasure models.Erasure) (models.Erasure, error)
+    EraseApplicantByRule(id int, erasure models.Erasure, rule retention.Rule, now time.Time, removeFiles func() error) (models.Erasure, error)
+    ListErasures(limit int, offset int) ([]models.Erasure, error)
+    CountErasures() (int, error)
+
+    FindRetentionMatches(rule retention.Rule, now time.Time) ([]models.RetentionMatch, error)
+    DeleteApplicantResume(applicantId int, rule retention.Rule, now time.Time, removeFiles func() error) (map[string]int, error)
+    RecordRetentionPurge(purge models.RetentionPurge) (int, error)
+    ListRetentionPurges(limit int, offset int) ([]models.RetentionPurge, error)
+    CountRetentionPurges() (int, error)
+
+    SetJobBlindHiring(jobId int, settings models.BlindHiring) error
+    GetPseudonyms(ids []int) (map[int]string, error)
+    GetUserIdByPseudonym(pseudonym string) (int, error)
+    GetJobApplicationStages(jobId int) (map[int][]models.ApplicationStage, error)
+    IsBlindReviewer(email string) (bool, error)
+    SetBlindReviewer(id int, reviewer bool) error
+
+    ReencryptPII(limit int) (int, error)
+
+    Ping(ctx context.Context) error
+    Stats() sql.DBStats
+    // WithContext returns the service running its queries with the values,
+    // such as the request id, of ctx
+    WithContext(ctx context.Context) Service
 	Close() error
 }
 
-type service struct {
-	db *sql.DB
-}
-
 var (
-	database   = os.Getenv("DB_DATABASE")
-	password   = os.Getenv("DB_PASSWORD")
-	username   = os.Getenv("DB_USERNAME")
-	port       = os.Getenv("DB_PORT")
-	host       = os.Getenv("DB_HOST")
-	schema     = os.Getenv("DB_SCHEMA")
-	dbInstance *service
+	ErrJobNotFound     = errors.New("Job not found")
+	ErrProfileNotFound = errors.New("Profile not found")
 )
 
-func New() Service {
-    connStr := fmt.Sprintf(
-        "host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-        host, port, username, password, database, "",
-    )
+type service struct {
+	db conn
+	// encrypts personal data, nil stores it in plaintext
+	cipher *encryption.Cipher
+	// name of the database, for logging
+	name string
+}
 
-    db, err := sql.Open("postgres", connStr)
+func New(cfg config.Database, cipher *encryption.Cipher) Service {
+    db, err := sql.Open("postgres", cfg.ConnString())
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("cannot open the database", "error", err)
+		os.Exit(1)
 	}
-	dbInstance = &service{
-		db: db,
+	return &service{
+		db:     conn{DB: db, ctx: context.Background()},
+		cipher: cipher,
+		name:   cfg.Name,
 	}
-	return dbInstance
 }
 
 func (s *service) Health() map[string]string {
@@ -81,7 +168,7 @@ func (s *service) Health() map[string]string {
 	if err != nil {
 		stats["status"] = "down"
 		stats["error"] = fmt.Sprintf("db down: %v", err)
-		log.Fatalf(fmt.Sprintf("db down: %v", err)) // Log the error and terminate the program
+		slog.ErrorContext(s.db.ctx, "database down", "error", err)
 		return stats
 	}
 
@@ -116,8 +203,13 @@ func (s *service) Health() map[string]string {
 	return stats
 }
 
+// Ping checks that the database can be reached, for the readiness check.
+func (s *service) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", database)
+	slog.Info("disconnected from the database", "database", s.name)
 	return s.db.Close()
 }
 
@@ -127,8 +219,18 @@ func (s *service) Close() error {
 
 
 func (s *service) CreateUser(name string, email string, password_hash string, address string, profile_headline string) error {
-    query := "INSERT INTO users (name, email, password_hash, address, profile_headline, type) VALUES ($1, $2, $3, $4, $5, $6)"
-    _, err := s.db.Exec(query, name, email, password_hash, address, profile_headline, "user")
+    // the encrypted address is bound to the id, so the id comes first
+    var id int
+    err := s.db.QueryRow("SELECT nextval(pg_get_serial_sequence('users', 'id'))").Scan(&id)
+    if err != nil {
+        return err
+    }
+    address, err = s.encrypt(address, usersAddress, id)
+    if err != nil {
+        return err
+    }
+    query := "INSERT INTO users (id, name, email, password_hash, address, profile_headline, type) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+    _, err = s.db.Exec(query, id, name, email, password_hash, address, profile_headline, "user")
     return err
 }
 
@@ -145,23 +247,20 @@ func (s *service) UserExists(email string) (bool, error) {
     return true, nil
 }
 
-func (s *service) CheckUserLogin(email string, password string) (bool, error) {
-    query := "SELECT id FROM users WHERE email = $1 AND password_hash = $2"
-    row := s.db.QueryRow(query, email, password)
-    var emailExists string
-    err := row.Scan(&emailExists)
-    if err != nil {
-        return false, err
-    }
-    return true, nil
-}
-
-func (s *service) CreateJob(title string, description string, companyName string, TotalApplications int, userId int) error {
+func (s *service) CreateJob(job models.Job, userId int) (int, error) {
     now := time.Now()
     emtpyArray := sql.NullInt64{}
-    query := "INSERT INTO jobs (title, description, company_name, total_applications, applicants, posted_by, posted_on) VALUES ($1, $2, $3, $4, $5, $6, $7)"
-    _, err := s.db.Exec(query, title, description, companyName, TotalApplications, emtpyArray, userId, now)
-    return err
+    salaryMin, salaryMax, currency := salaryArgs(job.Salary)
+    query := `INSERT INTO jobs (title, description, company_name, total_applications, applicants, posted_by, posted_on,
+        required_skills, preferred_skills, min_experience_years, education_level, location, remote_policy, employment_type,
+        salary_min, salary_max, salary_currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`
+    var id int
+    err := s.db.QueryRow(query, job.Title, job.Description, job.CompanyName, job.TotalApplications, emtpyArray, userId, now,
+        pq.Array(job.RequiredSkills), pq.Array(job.PreferredSkills), job.MinExperienceYears, nullIfEmpty(string(job.EducationLevel)),
+        nullIfEmpty(job.Location), nullIfEmpty(string(job.RemotePolicy)), nullIfEmpty(string(job.EmploymentType)),
+        salaryMin, salaryMax, currency).Scan(&id)
+    return id, err
 }
 
 func (s *service) GetJobs() ([]models.Job, error) {
@@ -178,24 +277,11 @@ func (s *service) GetJobs() ([]models.Job, error) {
         if err != nil {
             return jobs, err
         }
-        fmt.Println(jobId)
-        var job models.Job
-        var applicants []sql.NullInt64
-        query := "SELECT id, title, description, posted_on, total_applications, posted_by, company_name, applicants FROM jobs WHERE id = $1"
-        row := s.db.QueryRow(query, jobId)
-        err := row.Scan(&job.Id, &job.Title, &job.Description,
-            &job.PostedOn, &job.TotalApplications, &job.PostedBy,
-            &job.CompanyName, pq.Array(&applicants))
+        query := "SELECT " + jobColumns + " FROM jobs WHERE id = $1"
+        job, err := scanJob(s.db.QueryRow(query, jobId))
         if err != nil {
             return nil, err
         }
-        intApplicants := make([]int, len(applicants))
-        for i, a := range applicants {
-            if a.Valid {
-                intApplicants[i] = int(a.Int64) // Ensure it's safe to convert
-            }
-        }
-        job.Applicants = intApplicants
         jobs = append(jobs, job)
     }
     return jobs, nil
@@ -226,37 +312,24 @@ func (s *service) GetUserId(email string) (int, error) {
 }
 
 func (s *service) GetJob(id int) (models.Job, error) {
-    query := "SELECT id, title, description, posted_on, total_applications, posted_by, company_name, applicants FROM jobs WHERE id = $1"
-    row := s.db.QueryRow(query, id)
-    var job models.Job
-    var applicants []sql.NullInt64
-    err := row.Scan(&job.Id, &job.Title, &job.Description,
-        &job.PostedOn, &job.TotalApplications, &job.PostedBy,
-        &job.CompanyName, pq.Array(&applicants))
+    query := "SELECT " + jobColumns + " FROM jobs WHERE id = $1"
+    job, err := scanJob(s.db.QueryRow(query, id))
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) {
-            return models.Job{}, errors.New("Job not found")
+            return models.Job{}, ErrJobNotFound
         }
         return models.Job{}, err
     }
-    intApplicants := make([]int, len(applicants))
-    for i, a := range applicants {
-        if a.Valid {
-            intApplicants[i] = int(a.Int64) // Ensure it's safe to convert
-        }
-    }
-    job.Applicants = intApplicants
     return job, nil
 }
 
 func (s *service) GetApplicantProfile(userId int) (models.Profile, error) {
-    query := "SELECT applicant, skills, education, experience, name, email, phone FROM profile WHERE applicant = $1"
+    query := "SELECT " + profileColumns + " FROM profile WHERE applicant = $1"
     row := s.db.QueryRow(query, userId)
-    var profile models.Profile
-    err := row.Scan(&profile.Applicant, &profile.ResumeFileAddress, &profile.Skills, &profile.Education, &profile.Experience, &profile.Name, &profile.Email, &profile.Phone)
+    profile, err := s.scanProfile(row)
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) {
-            return models.Profile{}, errors.New("Profile not found")
+            return models.Profile{}, ErrProfileNotFound
         }
         return models.Profile{}, err
     }
@@ -274,10 +347,9 @@ func (s *service) GetApplicants(jobId int) ([]models.Profile, error) {
     var profiles []models.Profile
     for _, a := range applicants {
         if a.Valid {
-            query := "SELECT applicant, skills, education, experience, name, email, phone  FROM profile WHERE applicant = $1"
+            query := "SELECT " + profileColumns + " FROM profile WHERE applicant = $1"
             row := s.db.QueryRow(query, a.Int64)
-            var profile models.Profile
-            err := row.Scan(&profile.Applicant, &profile.ResumeFileAddress, &profile.Skills, &profile.Education, &profile.Experience, &profile.Name, &profile.Email, &profile.Phone)
+            profile, err := s.scanProfile(row)
             if err != nil {
                 return nil, err
             }
@@ -288,7 +360,7 @@ func (s *service) GetApplicants(jobId int) ([]models.Profile, error) {
 }
 
 func (s *service) GetAllApplicants() ([]models.Profile, error) {
-    query := "SELECT applicant, skills, education, experience, name, email, phone FROM profile"
+    query := "SELECT " + profileColumns + " FROM profile"
     rows, err := s.db.Query(query)
     if err != nil {
         return nil, err
@@ -296,8 +368,7 @@ func (s *service) GetAllApplicants() ([]models.Profile, error) {
     defer rows.Close()
     var profiles []models.Profile
     for rows.Next() {
-        var profile models.Profile
-        err = rows.Scan(&profile.Applicant, &profile.ResumeFileAddress, &profile.Skills, &profile.Education, &profile.Experience, &profile.Name, &profile.Email, &profile.Phone)
+        profile, err := s.scanProfile(rows)
         if err != nil {
             return profiles, err
         }
@@ -324,33 +395,44 @@ func (s *service) UpdateProfile(userId int, resumeFileAddress string) error {
 
 }
 
-func (s *service) ApplyJob(jobId int, userId int) error {
-    //check job exists
-    query := "SELECT id FROM jobs WHERE id = $1"
-    row := s.db.QueryRow(query, jobId)
+func (s *service) UpdateProfileWithFields(userId int, profile models.ProfileThirdParty, resumeText string) error {
+    slog.DebugContext(s.db.ctx, "updating the profile from the parser", "user_id", userId)
+    query := "SELECT applicant FROM profile WHERE applicant = $1"
+    row := s.db.QueryRow(query, userId)
     var id int
     err := row.Scan(&id)
     if err != nil {
         return err
     }
-
-    query = "UPDATE jobs SET applicants = array_append(applicants, $1) WHERE id = $2"
-    _, err = s.db.Exec(query, userId, jobId)
+    education := make([]string, len(profile.Education))
+    for i, institute := range profile.Education {
+        education[i] = institute.Name
+    }
+    experience := make([]string, len(profile.Experience))
+    for i, e := range profile.Experience {
+        experience[i] = e.Role
+    }
+    email, err := s.encrypt(profile.Email, profileEmail, userId)
     if err != nil {
         return err
     }
-    return err
-}
-func (s *service) UpdateProfileWithFields(userId int, profile models.ProfileThirdParty) error {
-    fmt.Println("update profile with fields", userId)
-    query := "SELECT applicant FROM profile WHERE applicant = $1"
-    row := s.db.QueryRow(query, userId)
-    var id int
-    err := row.Scan(&id)
+    phone, err := s.encrypt(profile.Phone, profilePhone, userId)
     if err != nil {
         return err
     }
-    query = "UPDATE profile set name, email, phone, education, experience, skills = $1, $2, $3, $4, $5, $6 WHERE applicant = $7"
-    _, err = s.db.Exec(query, profile.Name, profile.Email, profile.Phone, profile.Education, profile.Experience, pq.Array(profile.Skills), userId)
+    // fields the applicant corrected by hand keep their manual value
+    query = `UPDATE profile SET
+        name = CASE WHEN 'name' = ANY(manual_fields) THEN name ELSE $1 END,
+        email = $2,
+        phone = CASE WHEN 'phone' = ANY(manual_fields) THEN phone ELSE $3 END,
+        education = CASE WHEN 'education' = ANY(manual_fields) THEN education ELSE $4 END,
+        experience = CASE WHEN 'experience' = ANY(manual_fields) THEN experience ELSE $5 END,
+        skills = CASE WHEN 'skills' = ANY(manual_fields) THEN skills ELSE $6 END,
+        resume_text = $7,
+        email_bidx = $8,
+        phone_bidx = CASE WHEN 'phone' = ANY(manual_fields) THEN phone_bidx ELSE $9 END
+        WHERE applicant = $10`
+    _, err = s.db.Exec(query, profile.Name, email, phone, pq.Array(education), pq.Array(experience), pq.Array(profile.Skills), resumeText,
+        s.emailIndex(profile.Email), s.phoneIndex(profile.Phone), userId)
     return err
 }
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"resume-backend-parser/internal/encryption"
+)
+
+// Columns holding personal data, encrypted when the service has a cipher.
+// The names and the id of the row make up the associated data, so a value
+// copied into another column or row doesn't decrypt.
+const (
+	profileEmail = "profile.email"
+	profilePhone = "profile.phone"
+	usersAddress = "users.address"
+)
+
+// normalizeEmail and normalizePhone are applied before computing blind
+// indexes, so lookups match regardless of case and formatting.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+func (s *service) encrypt(value string, column string, id int) (string, error) {
+	return s.cipher.EncryptString(value, column+":"+strconv.Itoa(id))
+}
+
+// decrypt reads legacy values with the column alone, as they were written,
+// until ReencryptPII has rewritten them.
+func (s *service) decrypt(value string, column string, id int) (string, error) {
+	if encryption.Legacy(value) {
+		return s.cipher.DecryptString(value, column)
+	}
+	return s.cipher.DecryptString(value, column+":"+strconv.Itoa(id))
+}
+
+func (s *service) emailIndex(email string) sql.NullString {
+	return nullIfEmpty(s.cipher.BlindIndex(profileEmail, normalizeEmail(email)))
+}
+
+func (s *service) phoneIndex(phone string) sql.NullString {
+	return nullIfEmpty(s.cipher.BlindIndex(profilePhone, normalizePhone(phone)))
+}
+
+// ReencryptPII rewrites up to limit rows whose personal data is in plaintext,
+// encrypted under a key that is no longer current, or missing its blind
+// indexes. It returns how many rows it rewrote; zero means it is done.
+// Rewriting doesn't count as activity for updated_at.
+func (s *service) ReencryptPII(limit int) (int, error) {
+	if s.cipher == nil {
+		return 0, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("SET LOCAL app.reencrypting = 'on'"); err != nil {
+		return 0, err
+	}
+	current := escapeLike(s.cipher.CurrentPrefix()) + "%"
+
+	type profileRow struct {
+		applicant    int
+		email, phone string
+	}
+	var profiles []profileRow
+	rows, err := tx.Query(`SELECT applicant, coalesce(email, ''), coalesce(phone, '') FROM profile
+        WHERE (coalesce(email, '') <> '' AND (email NOT LIKE $1 OR email_bidx IS NULL))
+            OR (coalesce(phone, '') <> '' AND (phone NOT LIKE $1 OR phone_bidx IS NULL))
+        LIMIT $2 FOR UPDATE SKIP LOCKED`, current, limit)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var p profileRow
+		if err = rows.Scan(&p.applicant, &p.email, &p.phone); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		profiles = append(profiles, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, p := range profiles {
+		if p.email, err = s.decrypt(p.email, profileEmail, p.applicant); err != nil {
+			return 0, err
+		}
+		if p.phone, err = s.decrypt(p.phone, profilePhone, p.applicant); err != nil {
+			return 0, err
+		}
+		email, err := s.encrypt(p.email, profileEmail, p.applicant)
+		if err != nil {
+			return 0, err
+		}
+		phone, err := s.encrypt(p.phone, profilePhone, p.applicant)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`UPDATE profile SET email = $1, phone = $2, email_bidx = $3, phone_bidx = $4
+            WHERE applicant = $5`, nullIfEmpty(email), nullIfEmpty(phone), s.emailIndex(p.email), s.phoneIndex(p.phone), p.applicant)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	type userRow struct {
+		id      int
+		address string
+	}
+	var users []userRow
+	rows, err = tx.Query(`SELECT id, address FROM users WHERE address <> '' AND address NOT LIKE $1
+        LIMIT $2 FOR UPDATE SKIP LOCKED`, current, limit-len(profiles))
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var u userRow
+		if err = rows.Scan(&u.id, &u.address); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, u := range users {
+		address, err := s.decrypt(u.address, usersAddress, u.id)
+		if err != nil {
+			return 0, err
+		}
+		if address, err = s.encrypt(address, usersAddress, u.id); err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec("UPDATE users SET address = $1 WHERE id = $2", address, u.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(profiles) + len(users), tx.Commit()
+}
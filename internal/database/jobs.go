@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+const jobColumns = `id, title, description, posted_on, total_applications, posted_by, company_name, applicants,
+    required_skills, preferred_skills, min_experience_years, coalesce(education_level::text, ''),
+    coalesce(location, ''), coalesce(remote_policy::text, ''), coalesce(employment_type::text, ''),
+    salary_min, salary_max, coalesce(salary_currency, ''), blind_hiring, coalesce(blind_reveal_stage::text, '')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a row selected with jobColumns, followed by any extra columns
+// the caller asked for.
+func scanJob(row rowScanner, extra ...interface{}) (models.Job, error) {
+	var job models.Job
+	var applicants []sql.NullInt64
+	var educationLevel, remotePolicy, employmentType, currency, revealStage string
+	var salaryMin, salaryMax sql.NullInt64
+	dest := []interface{}{&job.Id, &job.Title, &job.Description,
+		&job.PostedOn, &job.TotalApplications, &job.PostedBy,
+		&job.CompanyName, pq.Array(&applicants),
+		pq.Array(&job.RequiredSkills), pq.Array(&job.PreferredSkills), &job.MinExperienceYears, &educationLevel,
+		&job.Location, &remotePolicy, &employmentType,
+		&salaryMin, &salaryMax, &currency, &job.BlindHiring.Enabled, &revealStage}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job.Applicants = nullIntsToInts(applicants)
+	job.EducationLevel = models.EducationLevel(educationLevel)
+	job.RemotePolicy = models.RemotePolicy(remotePolicy)
+	job.EmploymentType = models.EmploymentType(employmentType)
+	job.BlindHiring.RevealStage = models.ApplicationStage(revealStage)
+	if salaryMin.Valid || salaryMax.Valid {
+		job.Salary = &models.SalaryRange{Min: int(salaryMin.Int64), Max: int(salaryMax.Int64), Currency: currency}
+	}
+	return job, nil
+}
+
+// nullIfEmpty stores empty optional enum and text values as NULL.
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func salaryArgs(salary *models.SalaryRange) (sql.NullInt64, sql.NullInt64, sql.NullString) {
+	if salary == nil {
+		return sql.NullInt64{}, sql.NullInt64{}, sql.NullString{}
+	}
+	return sql.NullInt64{Int64: int64(salary.Min), Valid: true},
+		sql.NullInt64{Int64: int64(salary.Max), Valid: true},
+		nullIfEmpty(salary.Currency)
+}
+
+func (s *service) UpdateJob(id int, job models.Job) error {
+	salaryMin, salaryMax, currency := salaryArgs(job.Salary)
+	query := `UPDATE jobs SET title = $1, description = $2, company_name = $3, total_applications = $4,
+        required_skills = $5, preferred_skills = $6, min_experience_years = $7, education_level = $8, location = $9,
+        remote_policy = $10, employment_type = $11, salary_min = $12, salary_max = $13, salary_currency = $14
+        WHERE id = $15`
+	result, err := s.db.Exec(query, job.Title, job.Description, job.CompanyName, job.TotalApplications,
+		pq.Array(job.RequiredSkills), pq.Array(job.PreferredSkills), job.MinExperienceYears, nullIfEmpty(string(job.EducationLevel)),
+		nullIfEmpty(job.Location), nullIfEmpty(string(job.RemotePolicy)), nullIfEmpty(string(job.EmploymentType)),
+		salaryMin, salaryMax, currency, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAppliedJobs returns every job the user appears in the applicants of.
+func (s *service) GetAppliedJobs(userId int) ([]models.Job, error) {
+	query := "SELECT " + jobColumns + " FROM jobs WHERE $1 = ANY(applicants) ORDER BY posted_on DESC"
+	rows, err := s.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetOpenJobs returns jobs that still accept applications and that the user
+// has not applied to yet. Jobs the user withdrew from count as applied to:
+// withdrawing takes them off the job's applicants, but not its applications.
+func (s *service) GetOpenJobs(userId int) ([]models.Job, error) {
+	query := "SELECT " + jobColumns + ` FROM jobs
+        WHERE NOT EXISTS (SELECT 1 FROM applications WHERE job_id = jobs.id AND applicant = $1)
+        AND coalesce(cardinality(applicants), 0) < total_applications
+        ORDER BY posted_on DESC`
+	rows, err := s.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
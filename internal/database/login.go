@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+// GetLoginState counts the failed logins of the submitted email since the
+// failures of its account were last cleared. They are counted by the
+// normalized email whether or not an account has it, so that the throttle
+// doesn't tell which accounts exist; UserId is 0 when none does.
+func (s *service) GetLoginState(email string) (models.LoginState, error) {
+	var state models.LoginState
+	err := s.db.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&state.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.LoginState{}, err
+	}
+	query := `SELECT count(*), max(attempted_at) FROM login_attempts
+        WHERE lower(btrim(email)) = lower(btrim($1)) AND outcome = 'failed'
+        AND attempted_at > coalesce((SELECT max(failures_reset_at) FROM users
+            WHERE lower(btrim(email)) = lower(btrim($1))), '-infinity')`
+	var lastFailedAt sql.NullTime
+	if err = s.db.QueryRow(query, email).Scan(&state.FailedLogins, &lastFailedAt); err != nil {
+		return models.LoginState{}, err
+	}
+	state.LastFailedAt = lastFailedAt.Time
+	return state, nil
+}
+
+// GetIPLoginFailures counts the failed and blocked attempts from ip since the
+// given time and returns the time of the latest one.
+func (s *service) GetIPLoginFailures(ip string, since time.Time) (int, time.Time, error) {
+	query := `SELECT count(*), max(attempted_at) FROM login_attempts
+        WHERE ip = $1 AND outcome <> 'succeeded' AND attempted_at > $2`
+	var failures int
+	var last sql.NullTime
+	err := s.db.QueryRow(query, ip, since).Scan(&failures, &last)
+	return failures, last.Time, err
+}
+
+func (s *service) RecordLoginAttempt(attempt models.LoginAttempt) error {
+	query := "INSERT INTO login_attempts (email, user_id, ip, outcome, attempted_at) VALUES ($1, $2, $3, $4, $5)"
+	userId := sql.NullInt64{Int64: int64(attempt.UserId), Valid: attempt.UserId != 0}
+	_, err := s.db.Exec(query, attempt.Email, userId, attempt.IP, attempt.Outcome, attempt.AttemptedAt)
+	return err
+}
+
+// RecordFailedLogin counts a failed password for the user and marks the
+// account locked until lockUntil once threshold consecutive failures are
+// reached, for the admin views. The throttle itself counts the login attempts,
+// see GetLoginState.
+func (s *service) RecordFailedLogin(userId int, at time.Time, threshold int, lockUntil time.Time) error {
+	query := `UPDATE users SET failed_logins = failed_logins + 1, last_failed_login_at = $1,
+        locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE locked_until END
+        WHERE id = $4`
+	_, err := s.db.Exec(query, at, threshold, lockUntil, userId)
+	return err
+}
+
+// UnlockUser clears the failed logins and any lock of an account. It runs
+// after every successful login as well.
+func (s *service) UnlockUser(id int) error {
+	query := `UPDATE users SET failed_logins = 0, last_failed_login_at = NULL, locked_until = NULL,
+        failures_reset_at = $1 WHERE id = $2`
+	result, err := s.db.Exec(query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"encoding/json"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+func (s *service) GetMatchProfile(userId int) (models.MatchProfile, error) {
+	query := `SELECT applicant, coalesce(skills, '{}'), coalesce(education, '{}'), coalesce(experience, '{}'), coalesce(resume_text, '')
+        FROM profile WHERE applicant = $1`
+	var profile models.MatchProfile
+	err := s.db.QueryRow(query, userId).Scan(&profile.Applicant, pq.Array(&profile.Skills),
+		pq.Array(&profile.Education), pq.Array(&profile.Experience), &profile.ResumeText)
+	return profile, err
+}
+
+func (s *service) SaveMatchScore(score models.MatchScore) error {
+	details, err := json.Marshal(score)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO match_scores (job_id, applicant, score, details, computed_at) VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (job_id, applicant) DO UPDATE SET score = EXCLUDED.score, details = EXCLUDED.details, computed_at = EXCLUDED.computed_at`
+	_, err = s.db.Exec(query, score.JobId, score.Applicant, score.Score, details, score.ComputedAt)
+	return err
+}
+
+func (s *service) GetMatchScores(jobId int) ([]models.MatchScore, error) {
+	query := "SELECT details FROM match_scores WHERE job_id = $1 ORDER BY score DESC, applicant"
+	rows, err := s.db.Query(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scores := []models.MatchScore{}
+	for rows.Next() {
+		var details []byte
+		if err := rows.Scan(&details); err != nil {
+			return nil, err
+		}
+		var score models.MatchScore
+		if err := json.Unmarshal(details, &score); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
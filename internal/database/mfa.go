@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+var ErrMFAAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+
+func (s *service) GetMFA(userId int) (models.MFAState, error) {
+	query := "SELECT coalesce(mfa_secret, ''), mfa_enabled, coalesce(mfa_last_counter, 0) FROM users WHERE id = $1"
+	var state models.MFAState
+	err := s.db.QueryRow(query, userId).Scan(&state.Secret, &state.Enabled, &state.LastCounter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MFAState{}, ErrUserNotFound
+	}
+	return state, err
+}
+
+// SetMFASecret starts an enrollment. A new secret replaces any earlier
+// unfinished enrollment but never an enabled one.
+func (s *service) SetMFASecret(userId int, secret string) error {
+	result, err := s.db.Exec("UPDATE users SET mfa_secret = $1 WHERE id = $2 AND NOT mfa_enabled", secret, userId)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx txConn, userId int, codeHashes []string) error {
+	_, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		_, err = tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userId, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnableMFA finishes an enrollment whose first code was valid for counter.
+func (s *service) EnableMFA(userId int, counter int64, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET mfa_enabled = true, mfa_last_counter = $1
+        WHERE id = $2 AND NOT mfa_enabled AND mfa_secret IS NOT NULL`
+	result, err := tx.Exec(query, counter, userId)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	if err = replaceRecoveryCodes(tx, userId, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *service) DisableMFA(userId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET mfa_enabled = false, mfa_secret = NULL, mfa_last_counter = NULL WHERE id = $1"
+	if _, err = tx.Exec(query, userId); err != nil {
+		return err
+	}
+	if err = replaceRecoveryCodes(tx, userId, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *service) ReplaceRecoveryCodes(userId int, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = replaceRecoveryCodes(tx, userId, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPCounter records that the code of counter was used. It reports false
+// if that code, or a later one, was used before.
+func (s *service) UseTOTPCounter(userId int, counter int64) (bool, error) {
+	query := `UPDATE users SET mfa_last_counter = $1
+        WHERE id = $2 AND (mfa_last_counter IS NULL OR mfa_last_counter < $1)`
+	result, err := s.db.Exec(query, counter, userId)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode consumes a recovery code and reports whether it was valid.
+func (s *service) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	query := "UPDATE mfa_recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL"
+	result, err := s.db.Exec(query, time.Now().UTC(), userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *service) CountRecoveryCodes(userId int) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT count(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userId).Scan(&n)
+	return n, err
+}
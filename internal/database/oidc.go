@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+// ErrOIDCAccountConflict means an account with the email of the SSO user
+// exists but can't be linked to them.
+var ErrOIDCAccountConflict = errors.New("An account with this email already exists")
+
+// ProvisionOIDCUser returns the id of the account of a user signed in
+// through the SSO provider. An account with the same email is linked on the
+// first SSO login if the provider verified the email, otherwise an account of
+// userType is created. created reports whether the account is new.
+func (s *service) ProvisionOIDCUser(identity models.OIDCIdentity, userType models.UserType, passwordHash string) (int, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM users WHERE oidc_issuer = $1 AND oidc_subject = $2",
+		identity.Issuer, identity.Subject).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	var linked bool
+	err = tx.QueryRow("SELECT id, oidc_subject IS NOT NULL FROM users WHERE lower(email) = lower($1) ORDER BY id LIMIT 1 FOR UPDATE",
+		identity.Email).Scan(&id, &linked)
+	if err == nil {
+		// an unverified email could be anyone's, and an account belongs to
+		// one SSO identity only
+		if !identity.EmailVerified || linked {
+			return 0, false, ErrOIDCAccountConflict
+		}
+		query := `UPDATE users SET oidc_issuer = $1, oidc_subject = $2,
+            email_verified_at = coalesce(email_verified_at, $3) WHERE id = $4`
+		if _, err = tx.Exec(query, identity.Issuer, identity.Subject, time.Now().UTC(), id); err != nil {
+			return 0, false, err
+		}
+		return id, false, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	var verifiedAt sql.NullTime
+	if identity.EmailVerified {
+		verifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	query := `INSERT INTO users (name, email, password_hash, address, profile_headline, type,
+            email_verified_at, oidc_issuer, oidc_subject)
+        VALUES ($1, $2, $3, '', '', $4, $5, $6, $7) RETURNING id`
+	err = tx.QueryRow(query, identity.Name, identity.Email, passwordHash, dbUserType(userType),
+		verifiedAt, identity.Issuer, identity.Subject).Scan(&id)
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, tx.Commit()
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+)
+
+var ErrNotApplicant = errors.New("Only applicant accounts can be erased")
+
+// EmailHash is how an erased email is kept in its tombstone.
+func EmailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErasedActor is what the audit log shows instead of the email of an erased
+// applicant, so their entries can still be told apart and matched with the
+// tombstone.
+func ErasedActor(email string) string {
+	return "erased:" + EmailHash(email)
+}
+
+// GetApplicantSubmissions returns the cover letters and screening answers of
+// all applications of an applicant, withdrawn ones included, keyed by
+// application id.
+func (s *service) GetApplicantSubmissions(applicantId int) (map[int]models.ApplicationSubmission, error) {
+	query := `SELECT id, stage, coalesce(cover_letter, '') FROM applications WHERE applicant = $1`
+	rows, err := s.db.Query(query, applicantId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	submissions := map[int]models.ApplicationSubmission{}
+	for rows.Next() {
+		var applicationId int
+		var submission models.ApplicationSubmission
+		if err = rows.Scan(&applicationId, &submission.Stage, &submission.CoverLetter); err != nil {
+			return nil, err
+		}
+		submission.Answers = []models.ScreeningAnswer{}
+		submissions[applicationId] = submission
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT aa.application_id, coalesce(aa.question_id, 0), aa.prompt, aa.answer, aa.knocked_out
+        FROM application_answers aa JOIN applications a ON a.id = aa.application_id
+        WHERE a.applicant = $1
+        ORDER BY aa.application_id, aa.question_id`
+	answerRows, err := s.db.Query(query, applicantId)
+	if err != nil {
+		return nil, err
+	}
+	defer answerRows.Close()
+	for answerRows.Next() {
+		var applicationId int
+		var answer models.ScreeningAnswer
+		err = answerRows.Scan(&applicationId, &answer.QuestionId, &answer.Prompt, &answer.Answer, &answer.KnockedOut)
+		if err != nil {
+			return nil, err
+		}
+		submission := submissions[applicationId]
+		submission.Answers = append(submission.Answers, answer)
+		submission.KnockedOut = submission.KnockedOut || answer.KnockedOut
+		submissions[applicationId] = submission
+	}
+	return submissions, answerRows.Err()
+}
+
+// EraseApplicant removes everything stored about an applicant in one
+// transaction and leaves a tombstone in its place: the profile, the
+// applications with their answers and history, the match scores and tokens,
+// the id in jobs.applicants and finally the users row. Login attempts are
+// kept for throttling but lose the email. The audit log is append-only and
+// keeps its entries, but the applicant's email in them is replaced with its
+// hash. Stored resume files are up to the caller.
+func (s *service) EraseApplicant(id int, erasure models.Erasure) (models.Erasure, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Erasure{}, err
+	}
+	defer tx.Rollback()
+	if erasure, err = eraseApplicant(tx, id, erasure); err != nil {
+		return models.Erasure{}, err
+	}
+	return erasure, tx.Commit()
+}
+
+// EraseApplicantByRule is EraseApplicant for a retention rule, if it still
+// applies at now. removeFiles deletes the stored files once that is certain,
+// before the rows go.
+func (s *service) EraseApplicantByRule(id int, erasure models.Erasure, rule retention.Rule, now time.Time,
+	removeFiles func() error) (models.Erasure, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Erasure{}, err
+	}
+	defer tx.Rollback()
+	if err = retentionStillApplies(tx, rule, id, now); err != nil {
+		return models.Erasure{}, err
+	}
+	if err = removeFiles(); err != nil {
+		return models.Erasure{}, err
+	}
+	if erasure, err = eraseApplicant(tx, id, erasure); err != nil {
+		return models.Erasure{}, err
+	}
+	return erasure, tx.Commit()
+}
+
+func eraseApplicant(tx txConn, id int, erasure models.Erasure) (models.Erasure, error) {
+	var userType, email string
+	err := tx.QueryRow("SELECT type, email FROM users WHERE id = $1 FOR UPDATE", id).Scan(&userType, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Erasure{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.Erasure{}, err
+	}
+	if apiUserType(userType) != models.Applicant {
+		return models.Erasure{}, ErrNotApplicant
+	}
+
+	// lets reject_audit_log_change through the actor email redaction below
+	if _, err = tx.Exec("SET LOCAL app.erasing = 'on'"); err != nil {
+		return models.Erasure{}, err
+	}
+	erasure.Removed = map[string]int{}
+	steps := []struct {
+		table string
+		query string
+		args  []interface{}
+	}{
+		{"jobs.applicants", "UPDATE jobs SET applicants = array_remove(applicants, $1) WHERE $1 = ANY(applicants)", []interface{}{id}},
+		{"applications", "DELETE FROM applications WHERE applicant = $1", []interface{}{id}},
+		{"application_events", "UPDATE application_events SET changed_by = NULL WHERE changed_by = $1", []interface{}{id}},
+		{"match_scores", "DELETE FROM match_scores WHERE applicant = $1", []interface{}{id}},
+		{"profile", "DELETE FROM profile WHERE applicant = $1", []interface{}{id}},
+		{"login_attempts", "UPDATE login_attempts SET email = '', user_id = NULL WHERE user_id = $1 OR lower(email) = lower($2)", []interface{}{id, email}},
+		{"audit_log", `UPDATE audit_log SET actor_email = $3
+            WHERE actor_email IS NOT NULL AND (actor_id = $1 OR lower(actor_email) = lower($2))`,
+			[]interface{}{id, email, ErasedActor(email)}},
+		{"users", "DELETE FROM users WHERE id = $1", []interface{}{id}},
+	}
+	for _, step := range steps {
+		result, err := tx.Exec(step.query, step.args...)
+		if err != nil {
+			return models.Erasure{}, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return models.Erasure{}, err
+		}
+		if n > 0 {
+			erasure.Removed[step.table] = int(n)
+		}
+	}
+
+	erasure.UserId = id
+	erasure.EmailHash = EmailHash(email)
+	removed, err := json.Marshal(erasure.Removed)
+	if err != nil {
+		return models.Erasure{}, err
+	}
+	query := `INSERT INTO erasures (user_id, email_hash, erased_by, self_service, reason, erased_at, removed)
+        VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = tx.QueryRow(query, erasure.UserId, erasure.EmailHash, erasure.ErasedBy, erasure.SelfService,
+		nullIfEmpty(erasure.Reason), erasure.ErasedAt, removed).Scan(&erasure.Id)
+	if err != nil {
+		return models.Erasure{}, err
+	}
+	return erasure, nil
+}
+
+// ListErasures returns the newest tombstones first.
+func (s *service) ListErasures(limit int, offset int) ([]models.Erasure, error) {
+	query := `SELECT id, user_id, email_hash, erased_by, self_service, coalesce(reason, ''), erased_at, removed
+        FROM erasures ORDER BY id DESC LIMIT $1 OFFSET $2`
+	rows, err := s.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	erasures := []models.Erasure{}
+	for rows.Next() {
+		var erasure models.Erasure
+		var removed []byte
+		err = rows.Scan(&erasure.Id, &erasure.UserId, &erasure.EmailHash, &erasure.ErasedBy, &erasure.SelfService,
+			&erasure.Reason, &erasure.ErasedAt, &removed)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(removed, &erasure.Removed); err != nil {
+			return nil, err
+		}
+		erasures = append(erasures, erasure)
+	}
+	return erasures, rows.Err()
+}
+
+func (s *service) CountErasures() (int, error) {
+	var total int
+	err := s.db.QueryRow("SELECT count(*) FROM erasures").Scan(&total)
+	return total, err
+}
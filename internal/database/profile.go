@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+const profileColumns = `applicant, resume_file_address,
+    coalesce(array_to_string(skills, ', '), ''), coalesce(array_to_string(education, ', '), ''),
+    coalesce(array_to_string(experience, ', '), ''), coalesce(name, ''), coalesce(email, ''), coalesce(phone, ''),
+    manual_fields`
+
+// scanProfile reads a row selected with profileColumns, followed by any extra
+// columns the caller asked for, and decrypts the email and phone.
+func (s *service) scanProfile(row rowScanner, extra ...interface{}) (models.Profile, error) {
+	var p models.Profile
+	dest := []interface{}{&p.Applicant, &p.ResumeFileAddress, &p.Skills, &p.Education, &p.Experience,
+		&p.Name, &p.Email, &p.Phone, pq.Array(&p.ManualFields)}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return p, err
+	}
+	applicant, err := strconv.Atoi(p.Applicant)
+	if err != nil {
+		return p, err
+	}
+	if p.Email, err = s.decrypt(p.Email, profileEmail, applicant); err != nil {
+		return p, err
+	}
+	p.Phone, err = s.decrypt(p.Phone, profilePhone, applicant)
+	return p, err
+}
+
+// Profile fields an applicant may correct by hand.
+var ManualProfileFields = []string{"name", "phone", "skills", "education", "experience"}
+
+func (s *service) GetUser(email string) (models.User, error) {
+	query := `SELECT id, name, email, address, type, profile_headline, active,
+        email_verified_at IS NOT NULL, mfa_enabled FROM users WHERE email = $1`
+	var user models.User
+	var userType string
+	err := s.db.QueryRow(query, email).Scan(&user.Id, &user.Name, &user.Email, &user.Address, &userType, &user.ProfileHeadline, &user.Active, &user.EmailVerified, &user.MFAEnabled)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.Address, err = s.decrypt(user.Address, usersAddress, user.Id); err != nil {
+		return models.User{}, err
+	}
+	user.UserType = apiUserType(userType)
+	return user, nil
+}
+
+// UpdateProfileManual applies an applicant's own corrections and marks the
+// changed fields as manual so the resume parser no longer overwrites them.
+func (s *service) UpdateProfileManual(userId int, update models.UpdateProfileRequest) error {
+	var sets []string
+	var args []interface{}
+	var edited []string
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, column+" = $"+strconv.Itoa(len(args)))
+		edited = append(edited, column)
+	}
+	if update.Name != nil {
+		set("name", *update.Name)
+	}
+	if update.Phone != nil {
+		phone, err := s.encrypt(*update.Phone, profilePhone, userId)
+		if err != nil {
+			return err
+		}
+		set("phone", phone)
+		args = append(args, s.phoneIndex(*update.Phone))
+		sets = append(sets, "phone_bidx = $"+strconv.Itoa(len(args)))
+	}
+	if update.Skills != nil {
+		set("skills", pq.Array(*update.Skills))
+	}
+	if update.Education != nil {
+		set("education", pq.Array(*update.Education))
+	}
+	if update.Experience != nil {
+		set("experience", pq.Array(*update.Experience))
+	}
+	args = append(args, pq.Array(edited), pq.Array(update.Reset), userId)
+	n := len(args)
+	// pq sends nil slices as NULL, and f <> ALL(NULL) is never true
+	sets = append(sets, fmt.Sprintf(`manual_fields = ARRAY(
+            SELECT DISTINCT f FROM unnest(manual_fields || coalesce($%d::varchar[], '{}')) f
+            WHERE f <> ALL(coalesce($%d::varchar[], '{}')) ORDER BY f)`, n-2, n-1))
+	query := "UPDATE profile SET " + strings.Join(sets, ", ") + fmt.Sprintf(" WHERE applicant = $%d", n)
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
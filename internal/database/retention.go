@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+)
+
+// ErrRetentionNoLongerApplies is returned when an applicant was active again
+// between finding a retention match and purging it.
+var ErrRetentionNoLongerApplies = errors.New("Retention rule no longer applies")
+
+// applicantActivity is the last activity of every applicant: signing up,
+// logging in, a change to one of their applications or to their profile.
+const applicantActivity = `SELECT u.id, coalesce(greatest(u.created_at, l.last_login, a.last_change, p.updated_at),
+            'epoch'::timestamp) AS last_activity
+        FROM users u
+        LEFT JOIN (SELECT user_id, max(attempted_at) AS last_login FROM login_attempts
+            WHERE outcome = 'succeeded' GROUP BY user_id) l ON l.user_id = u.id
+        LEFT JOIN (SELECT applicant, max(updated_at) AS last_change FROM applications
+            GROUP BY applicant) a ON a.applicant = u.id
+        LEFT JOIN (SELECT applicant, max(updated_at) AS updated_at FROM profile
+            GROUP BY applicant) p ON p.applicant = u.id
+        WHERE u.type = 'user'`
+
+// last activity of every applicant a retention subject can apply to
+var retentionSubjects = map[retention.Subject]string{
+	retention.Rejected: applicantActivity + `
+            AND EXISTS (SELECT 1 FROM applications WHERE applicant = u.id)
+            AND NOT EXISTS (SELECT 1 FROM applications WHERE applicant = u.id
+                AND stage NOT IN ('rejected', 'withdrawn'))`,
+	retention.Inactive: applicantActivity,
+}
+
+// retentionStillApplies re-checks a match in the transaction purging it, with
+// the applicant's row locked, as they may have logged in or applied since it
+// was found.
+func retentionStillApplies(tx txConn, rule retention.Rule, userId int, now time.Time) error {
+	subject, ok := retentionSubjects[rule.Subject]
+	if !ok {
+		return fmt.Errorf("unknown retention subject %q", rule.Subject)
+	}
+	err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userId).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var applies bool
+	query := "SELECT EXISTS (SELECT 1 FROM (" + subject + ") c WHERE id = $1 AND last_activity < $2)"
+	if err = tx.QueryRow(query, userId, rule.Cutoff(now)).Scan(&applies); err != nil {
+		return err
+	}
+	if !applies {
+		return ErrRetentionNoLongerApplies
+	}
+	return nil
+}
+
+// FindRetentionMatches returns the applicants the rule applies to at now.
+// Resumes are only deleted where there is a profile left to delete.
+func (s *service) FindRetentionMatches(rule retention.Rule, now time.Time) ([]models.RetentionMatch, error) {
+	subject, ok := retentionSubjects[rule.Subject]
+	if !ok {
+		return nil, fmt.Errorf("unknown retention subject %q", rule.Subject)
+	}
+	query := "SELECT id, last_activity FROM (" + subject + ") c WHERE last_activity < $1"
+	if rule.Action == retention.DeleteResume {
+		query += " AND EXISTS (SELECT 1 FROM profile WHERE applicant = c.id)"
+	}
+	rows, err := s.db.Query(query+" ORDER BY id", rule.Cutoff(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	matches := []models.RetentionMatch{}
+	for rows.Next() {
+		match := models.RetentionMatch{Rule: rule.String()}
+		if err = rows.Scan(&match.UserId, &match.LastActivity); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// DeleteApplicantResume deletes the profile parsed from an applicant's resume
+// and the match scores computed from it, if rule still applies at now.
+// removeFiles deletes the files once that is certain, before the rows go.
+func (s *service) DeleteApplicantResume(applicantId int, rule retention.Rule, now time.Time,
+	removeFiles func() error) (map[string]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err = retentionStillApplies(tx, rule, applicantId, now); err != nil {
+		return nil, err
+	}
+	if err = removeFiles(); err != nil {
+		return nil, err
+	}
+	removed := map[string]int{}
+	for _, table := range []string{"match_scores", "profile"} {
+		result, err := tx.Exec("DELETE FROM "+table+" WHERE applicant = $1", applicantId)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			removed[table] = int(n)
+		}
+	}
+	return removed, tx.Commit()
+}
+
+func (s *service) RecordRetentionPurge(purge models.RetentionPurge) (int, error) {
+	removed, err := json.Marshal(purge.Removed)
+	if err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO retention_purges (rule, action, user_id, last_activity, purged_at, erasure_id, removed)
+        VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	erasureId := sql.NullInt64{Int64: int64(purge.ErasureId), Valid: purge.ErasureId != 0}
+	var id int
+	err = s.db.QueryRow(query, purge.Rule, purge.Action, purge.UserId, purge.LastActivity, purge.PurgedAt, erasureId,
+		removed).Scan(&id)
+	return id, err
+}
+
+// ListRetentionPurges returns the newest purges first.
+func (s *service) ListRetentionPurges(limit int, offset int) ([]models.RetentionPurge, error) {
+	query := `SELECT id, rule, action, user_id, last_activity, purged_at, coalesce(erasure_id, 0), removed
+        FROM retention_purges ORDER BY id DESC LIMIT $1 OFFSET $2`
+	rows, err := s.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	purges := []models.RetentionPurge{}
+	for rows.Next() {
+		var purge models.RetentionPurge
+		var removed []byte
+		err = rows.Scan(&purge.Id, &purge.Rule, &purge.Action, &purge.UserId, &purge.LastActivity, &purge.PurgedAt,
+			&purge.ErasureId, &removed)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(removed, &purge.Removed); err != nil {
+			return nil, err
+		}
+		purges = append(purges, purge)
+	}
+	return purges, rows.Err()
+}
+
+func (s *service) CountRetentionPurges() (int, error) {
+	var total int
+	err := s.db.QueryRow("SELECT count(*) FROM retention_purges").Scan(&total)
+	return total, err
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	pq "github.com/lib/pq"
+	"resume-backend-parser/internal/models"
+)
+
+// SetScreeningQuestions replaces the screening questions of a job. Answers
+// already given to the old questions are kept.
+func (s *service) SetScreeningQuestions(jobId int, questions []models.ScreeningQuestion) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM jobs WHERE id = $1 FOR UPDATE", jobId).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrJobNotFound
+	} else if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM screening_questions WHERE job_id = $1", jobId)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO screening_questions (job_id, position, prompt, type, required, options, knockout_answers, min_value, max_value)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	for i, q := range questions {
+		_, err = tx.Exec(query, jobId, i, q.Prompt, q.Type, q.Required,
+			pq.Array(nonNil(q.Options)), pq.Array(nonNil(q.KnockoutAnswers)), q.Min, q.Max)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func (s *service) GetScreeningQuestions(jobId int) ([]models.ScreeningQuestion, error) {
+	query := `SELECT id, prompt, type, required, options, knockout_answers, min_value, max_value
+        FROM screening_questions WHERE job_id = $1 ORDER BY position`
+	rows, err := s.db.Query(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	questions := []models.ScreeningQuestion{}
+	for rows.Next() {
+		var q models.ScreeningQuestion
+		var min, max sql.NullFloat64
+		err = rows.Scan(&q.Id, &q.Prompt, &q.Type, &q.Required, pq.Array(&q.Options), pq.Array(&q.KnockoutAnswers), &min, &max)
+		if err != nil {
+			return nil, err
+		}
+		if min.Valid {
+			q.Min = &min.Float64
+		}
+		if max.Valid {
+			q.Max = &max.Float64
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// saveSubmission replaces the cover letter and answers of an application.
+func saveSubmission(tx txConn, applicationId int, submission models.ApplicationSubmission) error {
+	_, err := tx.Exec("UPDATE applications SET cover_letter = $1 WHERE id = $2", nullIfEmpty(submission.CoverLetter), applicationId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM application_answers WHERE application_id = $1", applicationId)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO application_answers (application_id, question_id, prompt, answer, knocked_out) VALUES ($1, $2, $3, $4, $5)"
+	for _, answer := range submission.Answers {
+		_, err = tx.Exec(query, applicationId, answer.QuestionId, answer.Prompt, answer.Answer, answer.KnockedOut)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetJobSubmissions returns what each active applicant of a job submitted,
+// keyed by applicant id.
+func (s *service) GetJobSubmissions(jobId int) (map[int]models.ApplicationSubmission, error) {
+	query := `SELECT a.id, a.applicant, a.stage, coalesce(a.cover_letter, '')
+        FROM applications a WHERE a.job_id = $1 AND a.stage <> 'withdrawn'`
+	rows, err := s.db.Query(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	submissions := map[int]models.ApplicationSubmission{}
+	applicants := map[int]int{}
+	for rows.Next() {
+		var applicationId, applicant int
+		var submission models.ApplicationSubmission
+		err = rows.Scan(&applicationId, &applicant, &submission.Stage, &submission.CoverLetter)
+		if err != nil {
+			return nil, err
+		}
+		submission.Answers = []models.ScreeningAnswer{}
+		submissions[applicant] = submission
+		applicants[applicationId] = applicant
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT aa.application_id, coalesce(aa.question_id, 0), aa.prompt, aa.answer, aa.knocked_out
+        FROM application_answers aa JOIN applications a ON a.id = aa.application_id
+        WHERE a.job_id = $1 AND a.stage <> 'withdrawn'
+        ORDER BY aa.application_id, aa.question_id`
+	answerRows, err := s.db.Query(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer answerRows.Close()
+	for answerRows.Next() {
+		var applicationId int
+		var answer models.ScreeningAnswer
+		err = answerRows.Scan(&applicationId, &answer.QuestionId, &answer.Prompt, &answer.Answer, &answer.KnockedOut)
+		if err != nil {
+			return nil, err
+		}
+		applicant := applicants[applicationId]
+		submission := submissions[applicant]
+		submission.Answers = append(submission.Answers, answer)
+		submission.KnockedOut = submission.KnockedOut || answer.KnockedOut
+		submissions[applicant] = submission
+	}
+	return submissions, answerRows.Err()
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+
+	"resume-backend-parser/internal/models"
+)
+
+var ErrEmptySearchQuery = errors.New("Search query is empty")
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>"
+
+// ToTSQuery turns a user supplied search string into a to_tsquery expression.
+// Bare words are ANDed together, "quoted phrases" must appear in order and a
+// trailing * turns a word into a prefix match (e.g. `eng*` matches engineer).
+// The upper case operators AND, OR and NOT (or a leading -) are passed through;
+// as in postgres, NOT binds tighter than AND, which binds tighter than OR.
+func ToTSQuery(q string) (string, error) {
+	var out []string
+	operator := ""
+	negate := false
+	for _, token := range splitSearchTokens(q) {
+		switch token {
+		case "AND":
+			operator = "&"
+			continue
+		case "OR":
+			operator = "|"
+			continue
+		case "NOT", "-":
+			negate = true
+			continue
+		}
+		if len(token) > 1 && strings.HasPrefix(token, "-") {
+			negate = true
+			token = token[1:]
+		}
+		term := searchTerm(token)
+		if term == "" {
+			continue
+		}
+		if negate {
+			term = "!" + term
+			negate = false
+		}
+		if len(out) > 0 {
+			if operator == "" {
+				operator = "&"
+			}
+			out = append(out, operator)
+		}
+		operator = ""
+		out = append(out, term)
+	}
+	if len(out) == 0 {
+		return "", ErrEmptySearchQuery
+	}
+	return strings.Join(out, " "), nil
+}
+
+// searchTerm converts a single word or quoted phrase into a tsquery operand.
+func searchTerm(token string) string {
+	if strings.HasPrefix(token, `"`) {
+		words := lexemes(strings.Trim(token, `"`))
+		if len(words) == 0 {
+			return ""
+		}
+		return "(" + strings.Join(words, " <-> ") + ")"
+	}
+	prefix := strings.HasSuffix(token, "*")
+	words := lexemes(strings.TrimRight(token, "*"))
+	if len(words) == 0 {
+		return ""
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
+
+// splitSearchTokens splits on whitespace while keeping quoted phrases together.
+// An unterminated quote runs to the end of the input.
+func splitSearchTokens(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuote := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			if inQuote {
+				current.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				current.WriteRune(r)
+			}
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// lexemes strips everything but letters and digits so that user input can never
+// inject tsquery operators.
+func lexemes(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func nullIntsToInts(values []sql.NullInt64) []int {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		if v.Valid {
+			ints[i] = int(v.Int64)
+		}
+	}
+	return ints
+}
+
+func (s *service) SearchJobs(q string, limit int, offset int) ([]models.JobSearchResult, error) {
+	tsQuery, err := ToTSQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + jobColumns + `,
+            ts_rank_cd(search_vector, query) AS rank,
+            ts_headline('english', title, query, $4 || ', HighlightAll=true'),
+            ts_headline('english', description, query, $4 || ', MaxFragments=2, MaxWords=20, MinWords=5')
+        FROM jobs, to_tsquery('english', $1) query
+        WHERE search_vector @@ query
+        ORDER BY rank DESC, posted_on DESC
+        LIMIT $2 OFFSET $3`
+	rows, err := s.db.Query(query, tsQuery, limit, offset, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []models.JobSearchResult{}
+	for rows.Next() {
+		var result models.JobSearchResult
+		result.Job, err = scanJob(rows, &result.Rank, &result.TitleHighlight, &result.DescriptionSnippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *service) CountJobSearchResults(q string) (int, error) {
+	tsQuery, err := ToTSQuery(q)
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT count(*) FROM jobs WHERE search_vector @@ to_tsquery('english', $1)"
+	var total int
+	err = s.db.QueryRow(query, tsQuery).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+var ErrInvalidToken = errors.New("Invalid or expired token")
+
+// CreateUserToken stores a single-use token. Earlier unused tokens of the same
+// purpose are invalidated, so only the latest link a user received works.
+func (s *service) CreateUserToken(id string, userId int, purpose models.TokenPurpose, expiresAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := "UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL"
+	_, err = tx.Exec(query, now, userId, purpose)
+	if err != nil {
+		return err
+	}
+	query = "INSERT INTO user_tokens (id, user_id, purpose, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err = tx.Exec(query, id, userId, purpose, now, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// consumeToken marks a token as used and returns its user.
+func consumeToken(tx txConn, id string, purpose models.TokenPurpose, now time.Time) (int, error) {
+	query := `UPDATE user_tokens SET used_at = $1
+        WHERE id = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`
+	var userId int
+	err := tx.QueryRow(query, now, id, purpose).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	return userId, err
+}
+
+// VerifyEmail consumes an email verification token and returns the verified
+// user.
+func (s *service) VerifyEmail(tokenId string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	userId, err := consumeToken(tx, tokenId, models.TokenVerifyEmail, now)
+	if err != nil {
+		return 0, err
+	}
+	query := "UPDATE users SET email_verified_at = coalesce(email_verified_at, $1) WHERE id = $2"
+	_, err = tx.Exec(query, now, userId)
+	if err != nil {
+		return 0, err
+	}
+	return userId, tx.Commit()
+}
+
+// ResetPassword consumes a password reset token and sets the new password.
+// Following the emailed link proves the address, so it is verified as well.
+func (s *service) ResetPassword(tokenId string, passwordHash string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	userId, err := consumeToken(tx, tokenId, models.TokenResetPassword, now)
+	if err != nil {
+		return 0, err
+	}
+	query := "UPDATE users SET password_hash = $1, email_verified_at = coalesce(email_verified_at, $2) WHERE id = $3"
+	_, err = tx.Exec(query, passwordHash, now, userId)
+	if err != nil {
+		return 0, err
+	}
+	return userId, tx.Commit()
+}
+
+func (s *service) IsEmailVerified(userId int) (bool, error) {
+	var verified bool
+	err := s.db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1", userId).Scan(&verified)
+	return verified, err
+}
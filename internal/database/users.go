@@ -0,0 +1,276 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+var (
+	ErrUserNotFound = errors.New("User not found")
+	ErrLastAdmin    = errors.New("At least one active admin is required")
+)
+
+const userSummaryColumns = `id, name, email, type, active, created_at, deactivated_at,
+    CASE WHEN locked_until > now() THEN locked_until END, mfa_enabled, blind_reviewer`
+
+// dbUserType maps the API user type onto the user_type enum.
+func dbUserType(userType models.UserType) string {
+	if userType == models.Admin {
+		return "admin"
+	}
+	return "user"
+}
+
+func apiUserType(userType string) models.UserType {
+	if userType == "admin" {
+		return models.Admin
+	}
+	return models.Applicant
+}
+
+func scanUserSummary(row rowScanner) (models.UserSummary, error) {
+	var user models.UserSummary
+	var userType string
+	var createdAt, deactivatedAt, lockedUntil sql.NullTime
+	err := row.Scan(&user.Id, &user.Name, &user.Email, &userType, &user.Active, &createdAt, &deactivatedAt, &lockedUntil,
+		&user.MFAEnabled, &user.BlindReviewer)
+	if err != nil {
+		return models.UserSummary{}, err
+	}
+	user.UserType = apiUserType(userType)
+	if createdAt.Valid {
+		user.CreatedAt = &createdAt.Time
+	}
+	if deactivatedAt.Valid {
+		user.DeactivatedAt = &deactivatedAt.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	return user, nil
+}
+
+func userFilterClause(filters models.UserFilters) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if filters.UserType != "" {
+		where = append(where, "type = "+arg(dbUserType(filters.UserType)))
+	}
+	if filters.Active != nil {
+		where = append(where, "active = "+arg(*filters.Active))
+	}
+	if q := strings.TrimSpace(filters.Query); q != "" {
+		pattern := arg(escapeLike(q))
+		where = append(where, "(name ILIKE '%' || "+pattern+" || '%' OR email ILIKE '%' || "+pattern+" || '%')")
+	}
+	if len(where) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+func (s *service) ListUsers(filters models.UserFilters, limit int, offset int) ([]models.UserSummary, error) {
+	where, args := userFilterClause(filters)
+	args = append(args, limit, offset)
+	query := "SELECT " + userSummaryColumns + " FROM users" + where +
+		" ORDER BY id LIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.UserSummary{}
+	for rows.Next() {
+		user, err := scanUserSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *service) CountUsers(filters models.UserFilters) (int, error) {
+	where, args := userFilterClause(filters)
+	var total int
+	err := s.db.QueryRow("SELECT count(*) FROM users"+where, args...).Scan(&total)
+	return total, err
+}
+
+func (s *service) GetUserById(id int) (models.UserSummary, error) {
+	query := "SELECT " + userSummaryColumns + " FROM users WHERE id = $1"
+	user, err := scanUserSummary(s.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserSummary{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+// CreateAdmin creates an admin account. Admins have no address or headline,
+// and their email counts as verified since another admin vouched for it.
+func (s *service) CreateAdmin(name string, email string, passwordHash string) (int, error) {
+	query := `INSERT INTO users (name, email, password_hash, address, profile_headline, type, email_verified_at)
+        VALUES ($1, $2, $3, '', '', 'admin', $4) RETURNING id`
+	var id int
+	err := s.db.QueryRow(query, name, email, passwordHash, time.Now().UTC()).Scan(&id)
+	return id, err
+}
+
+func (s *service) IsUserActive(email string) (bool, error) {
+	var active bool
+	err := s.db.QueryRow("SELECT active FROM users WHERE email = $1", email).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+// changeUser runs update on the user within a transaction, refusing changes
+// that would leave the system without an active admin. stillAdmin reports
+// whether the user remains an active admin after the change.
+func (s *service) changeUser(id int, stillAdmin bool, update string, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// locking the active admins serializes concurrent demotions
+	rows, err := tx.Query("SELECT id FROM users WHERE type = 'admin' AND active FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	var admins []int
+	for rows.Next() {
+		var adminId int
+		if err = rows.Scan(&adminId); err != nil {
+			rows.Close()
+			return err
+		}
+		admins = append(admins, adminId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if !stillAdmin && len(admins) == 1 && admins[0] == id {
+		return ErrLastAdmin
+	}
+
+	result, err := tx.Exec(update, append(args, id)...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *service) SetUserType(id int, userType models.UserType) error {
+	user, err := s.GetUserById(id)
+	if err != nil {
+		return err
+	}
+	stillAdmin := userType == models.Admin && user.Active
+	return s.changeUser(id, stillAdmin, "UPDATE users SET type = $1 WHERE id = $2", dbUserType(userType))
+}
+
+// SetUserActive deactivates or reactivates an account. Deactivated users can
+// neither log in nor use tokens issued before.
+func (s *service) SetUserActive(id int, active bool) error {
+	user, err := s.GetUserById(id)
+	if err != nil {
+		return err
+	}
+	stillAdmin := active && user.UserType == models.Admin
+	query := `UPDATE users SET active = $1,
+        deactivated_at = CASE WHEN $1 THEN NULL ELSE coalesce(deactivated_at, now()) END
+        WHERE id = $2`
+	return s.changeUser(id, stillAdmin, query, active)
+}
+
+func (s *service) SetUserPassword(id int, passwordHash string) error {
+	result, err := s.db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetPasswordHash returns the id and the stored password hash of the
+// account, which the caller checks the password against.
+func (s *service) GetPasswordHash(email string) (int, string, error) {
+	var id int
+	var passwordHash string
+	err := s.db.QueryRow("SELECT id, password_hash FROM users WHERE email = $1", email).Scan(&id, &passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrUserNotFound
+	}
+	return id, passwordHash, err
+}
+
+// GetLegacyPasswords returns the passwords still stored in plaintext, from
+// before they were hashed with bcrypt, keyed by user id.
+func (s *service) GetLegacyPasswords() (map[int]string, error) {
+	rows, err := s.db.Query(`SELECT id, password_hash FROM users WHERE password_hash NOT LIKE '$2_$%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	passwords := map[int]string{}
+	for rows.Next() {
+		var id int
+		var password string
+		if err = rows.Scan(&id, &password); err != nil {
+			return nil, err
+		}
+		passwords[id] = password
+	}
+	return passwords, rows.Err()
+}
+
+// ReplacePasswordHash stores the hash of a legacy password, unless the
+// password was changed meanwhile.
+func (s *service) ReplacePasswordHash(id int, old string, passwordHash string) error {
+	_, err := s.db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3", passwordHash, id, old)
+	return err
+}
+
+// ActiveAdmins returns the ids of all active admins.
+func (s *service) ActiveAdmins() ([]int, error) {
+	rows, err := s.db.Query("SELECT id FROM users WHERE type = 'admin' AND active ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	admins := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		admins = append(admins, id)
+	}
+	return admins, rows.Err()
+}
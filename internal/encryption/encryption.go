@@ -0,0 +1,199 @@
+// Package encryption encrypts sensitive values and files at rest with
+// envelope encryption: every value gets its own data key, which is stored
+// next to it wrapped by a KeyProvider. After the provider's key is rotated
+// the server re-encrypts every value and file still under an old key in the
+// background, with new data keys.
+//
+// Encrypted values look like enc:v2:<key id>:<wrapped data key>:<ciphertext>;
+// encrypted files start with the same header up to the wrapped data key,
+// followed by a newline and the raw ciphertext. Anything else is taken as
+// plaintext written before encryption was turned on. v1 is the same format,
+// from when the database bound values to their column only, see Legacy.
+//
+// Encrypted columns can't be searched, so exact matches go through blind
+// indexes: an HMAC of the normalized value under a key of its own.
+package encryption
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	prefix       = "enc:v2:"
+	legacyPrefix = "enc:v1:"
+)
+
+var (
+	ErrCorrupt = errors.New("encrypted value is corrupt or was tampered with")
+	ErrNoKeys  = errors.New("value is encrypted but no encryption keys are configured")
+)
+
+// Cipher encrypts and decrypts values. A nil Cipher leaves new values in
+// plaintext, for setups without keys.
+type Cipher struct {
+	provider KeyProvider
+	indexKey []byte
+}
+
+func New(provider KeyProvider, indexKey []byte) (*Cipher, error) {
+	if len(indexKey) < 32 {
+		return nil, errors.New("encryption: the index key must be at least 32 bytes")
+	}
+	return &Cipher{provider: provider, indexKey: indexKey}, nil
+}
+
+// FromKeyfile returns a Cipher using the keys of a local keyfile.
+func FromKeyfile(path string) (*Cipher, error) {
+	keyfile, err := LoadKeyfile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(keyfile, keyfile.indexKey)
+}
+
+// CurrentPrefix starts every value encrypted under the current key, which
+// lets the database find the ones that aren't.
+func (c *Cipher) CurrentPrefix() string {
+	return prefix + c.provider.CurrentKeyID() + ":"
+}
+
+// encrypted reports whether data starts with the header of either version.
+func encrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix)) || bytes.HasPrefix(data, []byte(legacyPrefix))
+}
+
+// Legacy reports whether a value was encrypted as v1, whose associated data
+// named the column but not the row. They are still read that way until they
+// are re-encrypted, which they are as they don't start with CurrentPrefix.
+func Legacy(value string) bool {
+	return strings.HasPrefix(value, legacyPrefix)
+}
+
+// header generates a data key and returns it with the header naming its
+// wrapped form.
+func (c *Cipher) header() ([]byte, string, error) {
+	dataKey, err := randomKey()
+	if err != nil {
+		return nil, "", err
+	}
+	keyID, wrapped, err := c.provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return dataKey, prefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(wrapped), nil
+}
+
+// dataKey unwraps the data key of a header.
+func (c *Cipher) dataKey(header string) ([]byte, error) {
+	if c == nil {
+		return nil, ErrNoKeys
+	}
+	header = strings.TrimPrefix(strings.TrimPrefix(header, prefix), legacyPrefix)
+	keyID, encoded, ok := strings.Cut(header, ":")
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return c.provider.UnwrapKey(keyID, wrapped)
+}
+
+// EncryptString encrypts a value. aad names where the value belongs, e.g. the
+// column, so it can't be moved elsewhere unnoticed. Empty values stay empty.
+func (c *Cipher) EncryptString(value string, aad string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
+	}
+	dataKey, header, err := c.header()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, []byte(value), aad)
+	if err != nil {
+		return "", err
+	}
+	return header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString decrypts a value from EncryptString; plaintext is returned as
+// it is.
+func (c *Cipher) DecryptString(value string, aad string) (string, error) {
+	if !encrypted([]byte(value)) {
+		return value, nil
+	}
+	i := strings.LastIndexByte(value, ':')
+	dataKey, err := c.dataKey(value[:i])
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	plaintext, err := open(dataKey, sealed, aad)
+	return string(plaintext), err
+}
+
+// Encrypt encrypts the contents of a file.
+func (c *Cipher) Encrypt(data []byte, aad string) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	dataKey, header, err := c.header()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dataKey, data, aad)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header+"\n"), sealed...), nil
+}
+
+// Decrypt decrypts the contents of a file from Encrypt; plaintext is returned
+// as it is.
+func (c *Cipher) Decrypt(data []byte, aad string) ([]byte, error) {
+	if !encrypted(data) {
+		return data, nil
+	}
+	header, sealed, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	dataKey, err := c.dataKey(string(header))
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, sealed, aad)
+}
+
+// Stale reports whether a value or file content is in plaintext, in the v1
+// format or encrypted under a key that is no longer current.
+func (c *Cipher) Stale(data []byte) bool {
+	if c == nil || len(data) == 0 {
+		return false
+	}
+	return !bytes.HasPrefix(data, []byte(c.CurrentPrefix()))
+}
+
+// BlindIndex is the blind index of a value that has already been normalized.
+// column keeps equal values of different columns from matching. It is empty
+// for empty values and without a Cipher.
+func (c *Cipher) BlindIndex(column string, value string) string {
+	if c == nil || value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(column))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
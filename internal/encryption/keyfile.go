@@ -0,0 +1,179 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyProvider wraps and unwraps the data keys values are encrypted with,
+// under key encryption keys it never hands out. This is the shape of a KMS
+// API; Keyfile is the local implementation.
+type KeyProvider interface {
+	// CurrentKeyID is the key new data keys are wrapped with.
+	CurrentKeyID() string
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey also has to work for keys that are no longer current, until
+	// everything was re-encrypted.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyfile keeps the key encryption keys in a local JSON file, along with the
+// key of the blind indexes. Rotating adds a key and makes it current; older
+// keys stay for decrypting until they are removed by hand.
+type Keyfile struct {
+	CurrentKey string            `json:"currentKey"`
+	Keys       map[string]string `json:"keys"`
+	IndexKey   string            `json:"indexKey"`
+
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// NewKeyfile generates a keyfile with one key.
+func NewKeyfile() (*Keyfile, error) {
+	indexKey, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyfile{Keys: map[string]string{}, IndexKey: base64.StdEncoding.EncodeToString(indexKey)}
+	if _, err = k.Rotate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadKeyfile reads and checks a keyfile.
+func LoadKeyfile(path string) (*Keyfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var k Keyfile
+	if err = json.Unmarshal(b, &k); err != nil {
+		return nil, fmt.Errorf("keyfile %s: %w", path, err)
+	}
+	if err = k.decode(); err != nil {
+		return nil, fmt.Errorf("keyfile %s: %w", path, err)
+	}
+	return &k, nil
+}
+
+func (k *Keyfile) decode() error {
+	k.keys = map[string][]byte{}
+	for id, encoded := range k.Keys {
+		if id == "" || strings.ContainsAny(id, ":\n") {
+			return fmt.Errorf("invalid key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("key %s is not 32 base64 encoded bytes", id)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[k.CurrentKey]; !ok {
+		return fmt.Errorf("current key %q is missing", k.CurrentKey)
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(k.IndexKey)
+	if err != nil || len(indexKey) < 32 {
+		return errors.New("indexKey is not at least 32 base64 encoded bytes")
+	}
+	k.indexKey = indexKey
+	return nil
+}
+
+// Rotate adds a new key and makes it current. It returns the id of the key.
+func (k *Keyfile) Rotate() (string, error) {
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	id := "k" + strconv.FormatInt(time.Now().UTC().Unix(), 10)
+	for n := 2; k.Keys[id] != ""; n++ {
+		id = "k" + strconv.FormatInt(time.Now().UTC().Unix(), 10) + "-" + strconv.Itoa(n)
+	}
+	k.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	k.CurrentKey = id
+	return id, k.decode()
+}
+
+// Save writes the keyfile readable by the owner only.
+func (k *Keyfile) Save(path string) error {
+	b, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (k *Keyfile) CurrentKeyID() string {
+	return k.CurrentKey
+}
+
+// WrapKey encrypts a data key with AES-GCM under the current key.
+func (k *Keyfile) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.CurrentKey], dataKey, k.CurrentKey)
+	return k.CurrentKey, wrapped, err
+}
+
+func (k *Keyfile) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q is not in the keyfile", keyID)
+	}
+	return open(key, wrapped, keyID)
+}
+
+// seal encrypts with AES-256-GCM and puts the nonce in front.
+func seal(key []byte, plaintext []byte, aad string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(aad)), nil
+}
+
+func open(key []byte, sealed []byte, aad string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(aad))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
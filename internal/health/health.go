@@ -0,0 +1,150 @@
+// Package health runs the readiness checks of the components the server
+// depends on, such as the database. Every check has a timeout and its result
+// is reused for a while, so frequent probes by load balancers or orchestrators
+// don't put load on the components.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Up   Status = "up"
+	Down Status = "down"
+	// an optional component is down, the server still works without it
+	Degraded Status = "degraded"
+)
+
+// Check is a component the server depends on.
+type Check struct {
+	Name string
+	// an optional component being down degrades the server but doesn't
+	// make it unready
+	Optional bool
+	// how long Func may take before the component counts as down
+	Timeout time.Duration
+	// how long a result is reused
+	CacheFor time.Duration
+	// Func reports the component as down with an error. It should return
+	// once ctx ends.
+	Func func(ctx context.Context) error
+
+	mu        sync.Mutex
+	last      Result
+	checkedAt time.Time
+}
+
+type Result struct {
+	Status    Status    `json:"status"`
+	Optional  bool      `json:"optional,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	// whether the result is from an earlier check
+	Cached bool `json:"cached"`
+}
+
+type Report struct {
+	Status     Status            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+// run returns the cached result or checks the component. Concurrent callers
+// wait for the same check.
+func (c *Check) run(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.CacheFor {
+		cached := c.last
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Func(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out after " + c.Timeout.String())
+	}
+
+	c.last = Result{Status: Up, Optional: c.Optional, LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start.UTC()}
+	if err != nil {
+		c.last.Status = Down
+		c.last.Error = err.Error()
+	}
+	c.checkedAt = start
+	return c.last
+}
+
+// Checker aggregates the checks that decide whether the server is ready.
+type Checker struct {
+	mu     sync.Mutex
+	checks []*Check
+}
+
+func New(checks ...*Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+// Add adds a check, replacing one of the same name.
+func (c *Checker) Add(check *Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, existing := range c.checks {
+		if existing.Name == check.Name {
+			c.checks[i] = check
+			return
+		}
+	}
+	c.checks = append(c.checks, check)
+}
+
+// Check runs all checks concurrently. The server is down when a required
+// component is down and degraded when only optional ones are.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]*Check(nil), c.checks...)
+	c.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *Check) {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: Up, Components: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Components[check.Name] = results[i]
+		switch {
+		case results[i].Status == Up:
+		case check.Optional:
+			if report.Status == Up {
+				report.Status = Degraded
+			}
+		default:
+			report.Status = Down
+		}
+	}
+	return report
+}
@@ -0,0 +1,66 @@
+// Package lockout decides how long a login has to wait after failed
+// attempts. Failures are counted per account and per client IP; each failure
+// beyond the free ones doubles the delay before the next attempt, and too many
+// failures lock the account or block the IP for a while.
+package lockout
+
+import "time"
+
+type Policy struct {
+	// failures without any delay
+	AccountFreeAttempts int
+	IPFreeAttempts      int
+	// delay after the first failure beyond the free ones, doubled for every
+	// further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// consecutive failures that lock an account, and for how long
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// failures from one IP within IPWindow that block it for IPWindow
+	IPWindow      time.Duration
+	IPMaxFailures int
+}
+
+var DefaultPolicy = Policy{
+	AccountFreeAttempts: 3,
+	IPFreeAttempts:      10,
+	BaseDelay:           time.Second,
+	MaxDelay:            30 * time.Second,
+	LockoutThreshold:    10,
+	LockoutDuration:     15 * time.Minute,
+	IPWindow:            15 * time.Minute,
+	IPMaxFailures:       50,
+}
+
+// Delay is the wait enforced after failures failed attempts.
+func (p Policy) Delay(failures int, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := free + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// AccountWait is how long the account has to wait before the next attempt.
+// lockedUntil is zero for accounts that aren't locked.
+func (p Policy) AccountWait(failures int, lastFailure time.Time, lockedUntil time.Time, now time.Time) time.Duration {
+	until := lastFailure.Add(p.Delay(failures, p.AccountFreeAttempts))
+	if lockedUntil.After(until) {
+		until = lockedUntil
+	}
+	return max(until.Sub(now), 0)
+}
+
+// IPWait is how long an IP has to wait before the next attempt, given its
+// failures within the last IPWindow.
+func (p Policy) IPWait(failures int, lastFailure time.Time, now time.Time) time.Duration {
+	until := lastFailure.Add(p.Delay(failures, p.IPFreeAttempts))
+	if failures >= p.IPMaxFailures {
+		until = lastFailure.Add(p.IPWindow)
+	}
+	return max(until.Sub(now), 0)
+}
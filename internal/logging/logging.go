@@ -0,0 +1,97 @@
+// Package logging sets up the structured logger of the server. Records get
+// the request id and the trace of their context, and personal data is
+// redacted: attributes named like personal data lose their value, and email
+// addresses are masked wherever else they turn up, e.g. in errors.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"resume-backend-parser/internal/config"
+)
+
+const redacted = "[redacted]"
+
+// attributes whose values are never logged
+var personalKeys = map[string]bool{
+	"email": true, "phone": true, "address": true, "name": true, "first_name": true, "last_name": true,
+	"password": true, "token": true, "secret": true, "authorization": true, "apikey": true, "api_key": true,
+	"code": true, "profile": true, "resume": true,
+}
+
+var emailPattern = regexp.MustCompile(`[^\s@<>()"',;:]+@[^\s@<>()"',;:]+\.[A-Za-z]{2,}`)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log records carry id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID is the request id of ctx, empty outside of requests.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New returns a logger writing to w in the configured format.
+func New(w io.Writer, cfg config.Logging) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, AddSource: cfg.Source, ReplaceAttr: redact}
+	var h slog.Handler
+	if cfg.Format == config.JSONLogs {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler{h})
+}
+
+// Redact masks the email addresses in s.
+func Redact(s string) string {
+	return emailPattern.ReplaceAllString(s, redacted)
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if personalKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// handler adds the request id and the trace of the context to records.
+type handler struct {
+	slog.Handler
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	// the message isn't an attribute, so ReplaceAttr doesn't see it
+	r.Message = Redact(r.Message)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,203 @@
+// Package matching scores parsed applicant profiles against structured job
+// requirements.
+package matching
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+// Weights of each component in the final 0-100 score. Components the job does
+// not ask for are left out and the remaining weights are scaled up.
+const (
+	requiredSkillsWeight  = 50.0
+	preferredSkillsWeight = 15.0
+	experienceWeight      = 20.0
+	educationWeight       = 15.0
+)
+
+// skillAliases maps common spellings onto a single canonical name.
+var skillAliases = map[string]string{
+	"golang":              "go",
+	"js":                  "javascript",
+	"ts":                  "typescript",
+	"postgres":            "postgresql",
+	"psql":                "postgresql",
+	"k8s":                 "kubernetes",
+	"node":                "node.js",
+	"nodejs":              "node.js",
+	"reactjs":             "react",
+	"react.js":            "react",
+	"py":                  "python",
+	"c sharp":             "c#",
+	"csharp":              "c#",
+	"cplusplus":           "c++",
+	"amazon web services": "aws",
+}
+
+// CanonicalSkill lower cases a skill, collapses whitespace and resolves aliases.
+func CanonicalSkill(skill string) string {
+	s := strings.Join(strings.Fields(strings.ToLower(skill)), " ")
+	if alias, ok := skillAliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+var educationRank = map[models.EducationLevel]int{
+	models.EducationNone:       0,
+	models.EducationHighSchool: 1,
+	models.EducationAssociate:  2,
+	models.EducationBachelor:   3,
+	models.EducationMaster:     4,
+	models.EducationDoctorate:  5,
+}
+
+// educationKeywords are checked from the highest degree down.
+var educationKeywords = []struct {
+	level   models.EducationLevel
+	pattern *regexp.Regexp
+}{
+	{models.EducationDoctorate, regexp.MustCompile(`(?i)\b(?:ph\.?\s?d\b|doctorate\b|doctor of\b)`)},
+	{models.EducationMaster, regexp.MustCompile(`(?i)\b(?:masters?\b|m\.?\s?sc\b|m\.?\s?tech\b|mba\b|m\.?\s?eng\b|m\.s\.)`)},
+	{models.EducationBachelor, regexp.MustCompile(`(?i)\b(?:bachelors?\b|b\.?\s?sc\b|b\.?\s?tech\b|b\.?\s?eng\b|b\.[sae]\.)`)},
+	{models.EducationAssociate, regexp.MustCompile(`(?i)\bassociate'?s? (?:degree|of)\b`)},
+	{models.EducationHighSchool, regexp.MustCompile(`(?i)\b(?:high school|secondary school|diploma)\b`)},
+}
+
+var experienceYearsPattern = regexp.MustCompile(`(?i)\b(\d{1,2})\+?\s*(?:years?|yrs?)\b`)
+
+// EstimateEducationLevel infers the highest degree mentioned in the parsed
+// education entries or the resume text. It returns "" when nothing matches.
+func EstimateEducationLevel(education []string, resumeText string) models.EducationLevel {
+	text := strings.Join(education, "\n") + "\n" + resumeText
+	for _, k := range educationKeywords {
+		if k.pattern.MatchString(text) {
+			return k.level
+		}
+	}
+	return ""
+}
+
+// EstimateExperienceYears looks for statements such as "5+ years" in the
+// experience entries and resume text and returns the largest one.
+func EstimateExperienceYears(experience []string, resumeText string) int {
+	text := strings.Join(experience, "\n") + "\n" + resumeText
+	years := 0
+	for _, m := range experienceYearsPattern.FindAllStringSubmatch(text, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > years && n <= 60 {
+			years = n
+		}
+	}
+	return years
+}
+
+// hasSkill reports whether the applicant lists the skill, or failing that
+// mentions it as a whole word in the resume text. Very short skills such as
+// "go" or "r" are too ambiguous to look for in free text.
+func hasSkill(skills map[string]bool, resumeText string, skill string) bool {
+	canonical := CanonicalSkill(skill)
+	if skills[canonical] {
+		return true
+	}
+	if resumeText == "" || len(canonical) < 3 {
+		return false
+	}
+	pattern := `(?i)(^|[^\w+#.])` + regexp.QuoteMeta(canonical) + `($|[^\w+#])`
+	matched, _ := regexp.MatchString(pattern, resumeText)
+	return matched
+}
+
+func splitSkills(skills map[string]bool, resumeText string, wanted []string) ([]string, []string) {
+	matched := []string{}
+	missing := []string{}
+	for _, skill := range wanted {
+		if hasSkill(skills, resumeText, skill) {
+			matched = append(matched, skill)
+		} else {
+			missing = append(missing, skill)
+		}
+	}
+	return matched, missing
+}
+
+// Score compares a parsed profile against a job's requirements and explains
+// how the 0-100 result was reached.
+func Score(job models.Job, profile models.MatchProfile) models.MatchScore {
+	result := models.MatchScore{
+		JobId:      job.Id,
+		Applicant:  profile.Applicant,
+		ComputedAt: time.Now().UTC(),
+	}
+	skills := map[string]bool{}
+	for _, skill := range profile.Skills {
+		skills[CanonicalSkill(skill)] = true
+	}
+
+	var earned, available float64
+	if len(job.RequiredSkills) > 0 {
+		result.MatchedSkills, result.MissingSkills = splitSkills(skills, profile.ResumeText, job.RequiredSkills)
+		available += requiredSkillsWeight
+		earned += requiredSkillsWeight * float64(len(result.MatchedSkills)) / float64(len(job.RequiredSkills))
+		result.Explanation = append(result.Explanation,
+			fmt.Sprintf("has %d of %d required skills", len(result.MatchedSkills), len(job.RequiredSkills)))
+	}
+	if len(job.PreferredSkills) > 0 {
+		result.MatchedPreferredSkills, result.MissingPreferredSkills = splitSkills(skills, profile.ResumeText, job.PreferredSkills)
+		available += preferredSkillsWeight
+		earned += preferredSkillsWeight * float64(len(result.MatchedPreferredSkills)) / float64(len(job.PreferredSkills))
+		result.Explanation = append(result.Explanation,
+			fmt.Sprintf("has %d of %d preferred skills", len(result.MatchedPreferredSkills), len(job.PreferredSkills)))
+	}
+
+	result.ExperienceYears = EstimateExperienceYears(profile.Experience, profile.ResumeText)
+	if job.MinExperienceYears > 0 {
+		available += experienceWeight
+		switch {
+		case result.ExperienceYears >= job.MinExperienceYears:
+			earned += experienceWeight
+			result.Explanation = append(result.Explanation,
+				fmt.Sprintf("%d years of experience meets the %d year minimum", result.ExperienceYears, job.MinExperienceYears))
+		case result.ExperienceYears == 0:
+			result.Explanation = append(result.Explanation, "years of experience could not be determined from the resume")
+		default:
+			earned += experienceWeight * float64(result.ExperienceYears) / float64(job.MinExperienceYears)
+			result.Explanation = append(result.Explanation,
+				fmt.Sprintf("%d years of experience is below the %d year minimum", result.ExperienceYears, job.MinExperienceYears))
+		}
+	}
+
+	result.EducationLevel = EstimateEducationLevel(profile.Education, profile.ResumeText)
+	if required, ok := educationRank[job.EducationLevel]; ok && required > 0 {
+		available += educationWeight
+		actual, known := educationRank[result.EducationLevel]
+		switch {
+		case !known:
+			result.Explanation = append(result.Explanation, "education level could not be determined from the resume")
+		case actual >= required:
+			earned += educationWeight
+			result.Explanation = append(result.Explanation,
+				fmt.Sprintf("%s education meets the %s requirement", result.EducationLevel, job.EducationLevel))
+		case actual == required-1:
+			earned += educationWeight / 2
+			result.Explanation = append(result.Explanation,
+				fmt.Sprintf("%s education is one level below the %s requirement", result.EducationLevel, job.EducationLevel))
+		default:
+			result.Explanation = append(result.Explanation,
+				fmt.Sprintf("%s education is below the %s requirement", result.EducationLevel, job.EducationLevel))
+		}
+	}
+
+	if available == 0 {
+		result.Explanation = append(result.Explanation, "job has no structured requirements to score against")
+		return result
+	}
+	result.Score = int(math.Round(earned / available * 100))
+	return result
+}
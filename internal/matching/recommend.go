@@ -0,0 +1,170 @@
+package matching
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"resume-backend-parser/internal/models"
+)
+
+// Weights of the two signals a recommendation is ranked by. Jobs without any
+// skills listed are ranked on text similarity alone.
+const (
+	skillOverlapWeight = 0.6
+	similarityWeight   = 0.4
+	maxSharedTerms     = 5
+)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "our": true, "that": true, "the": true, "their": true, "this": true,
+	"to": true, "we": true, "will": true, "with": true, "you": true, "your": true, "who": true, "work": true,
+	"years": true, "year": true, "experience": true, "team": true, "job": true, "role": true,
+}
+
+// tokenize lower cases text and splits it into terms, keeping characters such
+// as + and # that are part of skill names (c++, c#).
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	})
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if len(w) < 2 || stopwords[w] {
+			continue
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+type vector map[string]float64
+
+// tfidf weighs term frequencies by inverse document frequency and normalizes
+// the result to unit length.
+func tfidf(terms []string, idf func(string) float64) vector {
+	v := vector{}
+	for _, term := range terms {
+		v[term]++
+	}
+	var norm float64
+	for term, tf := range v {
+		v[term] = tf * idf(term)
+		norm += v[term] * v[term]
+	}
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for term := range v {
+			v[term] /= norm
+		}
+	}
+	return v
+}
+
+// cosine returns the similarity of two unit vectors and the terms that
+// contributed most to it.
+func cosine(a vector, b vector) (float64, []string) {
+	type contribution struct {
+		term  string
+		value float64
+	}
+	var sum float64
+	var shared []contribution
+	for term, weight := range a {
+		if other, ok := b[term]; ok {
+			sum += weight * other
+			shared = append(shared, contribution{term, weight * other})
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].value != shared[j].value {
+			return shared[i].value > shared[j].value
+		}
+		return shared[i].term < shared[j].term
+	})
+	terms := []string{}
+	for i := 0; i < len(shared) && i < maxSharedTerms; i++ {
+		terms = append(terms, shared[i].term)
+	}
+	return sum, terms
+}
+
+func jobDocument(job models.Job) string {
+	return strings.Join([]string{job.Title, job.Description,
+		strings.Join(job.RequiredSkills, " "), strings.Join(job.PreferredSkills, " ")}, " ")
+}
+
+// Recommend ranks jobs for an applicant by the overlap between their skills and
+// the jobs' skills, and by TF-IDF cosine similarity between their resume and
+// the job descriptions. Jobs that share nothing with the profile are dropped.
+func Recommend(profile models.MatchProfile, jobs []models.Job) []models.JobRecommendation {
+	documents := make([][]string, len(jobs))
+	df := map[string]int{}
+	for i, job := range jobs {
+		documents[i] = tokenize(jobDocument(job))
+		seen := map[string]bool{}
+		for _, term := range documents[i] {
+			if !seen[term] {
+				seen[term] = true
+				df[term]++
+			}
+		}
+	}
+	// smoothed idf so that terms missing from every job still get a weight
+	idf := func(term string) float64 {
+		return math.Log(float64(1+len(jobs))/float64(1+df[term])) + 1
+	}
+	profileText := strings.Join([]string{strings.Join(profile.Skills, " "), strings.Join(profile.Experience, " "),
+		strings.Join(profile.Education, " "), profile.ResumeText}, " ")
+	profileVector := tfidf(tokenize(profileText), idf)
+
+	skills := map[string]bool{}
+	for _, skill := range profile.Skills {
+		skills[CanonicalSkill(skill)] = true
+	}
+
+	recommendations := []models.JobRecommendation{}
+	for i, job := range jobs {
+		similarity, sharedTerms := cosine(profileVector, tfidf(documents[i], idf))
+		matchedRequired, _ := splitSkills(skills, profile.ResumeText, job.RequiredSkills)
+		matchedPreferred, _ := splitSkills(skills, profile.ResumeText, job.PreferredSkills)
+
+		var score float64
+		// required skills count double towards the overlap
+		possible := 2*len(job.RequiredSkills) + len(job.PreferredSkills)
+		if possible > 0 {
+			overlap := float64(2*len(matchedRequired)+len(matchedPreferred)) / float64(possible)
+			score = skillOverlapWeight*overlap + similarityWeight*similarity
+		} else {
+			score = similarity
+		}
+		if score <= 0 {
+			continue
+		}
+
+		reasons := []string{}
+		if len(matchedRequired) > 0 {
+			reasons = append(reasons, fmt.Sprintf("you have %d of %d required skills: %s",
+				len(matchedRequired), len(job.RequiredSkills), strings.Join(matchedRequired, ", ")))
+		}
+		if len(matchedPreferred) > 0 {
+			reasons = append(reasons, "you have preferred skills: "+strings.Join(matchedPreferred, ", "))
+		}
+		if len(sharedTerms) > 0 {
+			reasons = append(reasons, "the description mentions terms from your resume: "+strings.Join(sharedTerms, ", "))
+		}
+		recommendations = append(recommendations, models.JobRecommendation{
+			Job:     job,
+			Score:   math.Round(score*1000) / 10,
+			Reasons: reasons,
+		})
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	return recommendations
+}
@@ -0,0 +1,129 @@
+// Package metrics holds the Prometheus metrics of the server, served by
+// Handler. The metrics are package variables registered on a registry of
+// their own, so only what is listed here is exposed.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "resume_parser"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of database queries by the database.Service method running them.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Failed database queries by the database.Service method running them.",
+	}, []string{"method"})
+
+	ParserRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "parser_request_duration_seconds",
+		Help:      "Duration of calls to the resume parser by provider.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"provider"})
+	ParserErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "parser_errors_total",
+		Help:      "Failed calls to the resume parser by provider and reason: request, status or decode.",
+	}, []string{"provider", "reason"})
+
+	JobRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "background_job_running",
+		Help:      "Whether a background job is running.",
+	}, []string{"job"})
+	JobBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "background_job_backlog",
+		Help:      "Items, such as purges or re-encrypted rows and files, the last run of a background job found to process.",
+	}, []string{"job"})
+	JobLastRun = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "background_job_last_run_timestamp_seconds",
+		Help:      "When the last run of a background job finished.",
+	}, []string{"job"})
+
+	Signups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Accounts created, by method: password or sso.",
+	}, []string{"method"})
+	ResumeUploads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resume_uploads_total",
+		Help:      "Resumes uploaded.",
+	})
+	Applications = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "applications_total",
+		Help:      "Applications to jobs.",
+	})
+)
+
+// the pool the gauges of dbStats read, see SetDBStats
+var dbStats atomic.Pointer[func() sql.DBStats]
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration, DBQueryDuration, DBQueryErrors, ParserRequestDuration, ParserErrors,
+		JobRunning, JobBacklog, JobLastRun, Signups, ResumeUploads, Applications,
+	)
+	pool := func(name string, help string, value func(s sql.DBStats) float64) prometheus.GaugeFunc {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Name: "db_pool_" + name, Help: help},
+			func() float64 {
+				stats := dbStats.Load()
+				if stats == nil {
+					return 0
+				}
+				return value((*stats)())
+			})
+	}
+	Registry.MustRegister(
+		pool("max_open_connections", "Maximum number of open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		pool("open_connections", "Open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		pool("in_use_connections", "Connections in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		pool("idle_connections", "Idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		pool("wait_count", "Connections waited for in total.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		pool("wait_duration_seconds", "Time spent waiting for connections in total.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
+
+// SetDBStats sets the connection pool the db_pool gauges show.
+func SetDBStats(stats func() sql.DBStats) {
+	dbStats.Store(&stats)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
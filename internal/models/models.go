@@ -12,13 +12,75 @@ const (
 	Admin     UserType = "Admin"
 )
 
+// TokenPurpose is what an emailed single-use token can be used for.
+type TokenPurpose string
+
+const (
+	TokenVerifyEmail   TokenPurpose = "verify_email"
+	TokenResetPassword TokenPurpose = "reset_password"
+)
+
+type EducationLevel string
+
+const (
+	EducationNone       EducationLevel = "none"
+	EducationHighSchool EducationLevel = "high_school"
+	EducationAssociate  EducationLevel = "associate"
+	EducationBachelor   EducationLevel = "bachelor"
+	EducationMaster     EducationLevel = "master"
+	EducationDoctorate  EducationLevel = "doctorate"
+)
+
+type RemotePolicy string
+
+const (
+	Onsite RemotePolicy = "onsite"
+	Hybrid RemotePolicy = "hybrid"
+	Remote RemotePolicy = "remote"
+)
+
+type EmploymentType string
+
+const (
+	FullTime   EmploymentType = "full_time"
+	PartTime   EmploymentType = "part_time"
+	Contract   EmploymentType = "contract"
+	Internship EmploymentType = "internship"
+	Temporary  EmploymentType = "temporary"
+)
+
+type ApplicationStage string
+
+const (
+	StageApplied   ApplicationStage = "applied"
+	StageScreening ApplicationStage = "screening"
+	StageInterview ApplicationStage = "interview"
+	StageOffer     ApplicationStage = "offer"
+	StageHired     ApplicationStage = "hired"
+	StageRejected  ApplicationStage = "rejected"
+	StageWithdrawn ApplicationStage = "withdrawn"
+)
+
+type QuestionType string
+
+const (
+	FreeText       QuestionType = "free_text"
+	YesNo          QuestionType = "yes_no"
+	MultipleChoice QuestionType = "multiple_choice"
+	Numeric        QuestionType = "numeric"
+)
+
 type User struct {
+	Id              int      `json:"id"`
 	Name            string   `json:"name"`
 	Email           string   `json:"email"`
 	Address         string   `json:"address"`
 	UserType        UserType `json:"userType"`
-	PasswordHash    string   `json:"passwordHash"`
+	PasswordHash    string   `json:"-"`
 	ProfileHeadline string   `json:"profileHeadline"`
+	Active          bool     `json:"active"`
+	EmailVerified   bool     `json:"emailVerified"`
+	MFAEnabled      bool     `json:"mfaEnabled"`
 	Profile         Profile  `json:"profile"`
 }
 
@@ -31,6 +93,13 @@ type Profile struct {
 	Name              string `json:"name"`
 	Email             string `json:"email"`
 	Phone             string `json:"phone"`
+	// ManualFields lists the fields the applicant corrected by hand; a later
+	// re-parse of their resume leaves these alone.
+	ManualFields      []string `json:"manualFields"`
+	// set in blind hiring views, see internal/blind. A redacted profile has
+	// the pseudonym as its applicant.
+	Pseudonym         string   `json:"pseudonym,omitempty"`
+	Redacted          bool     `json:"redacted,omitempty"`
 }
 
 type Job struct {
@@ -42,6 +111,35 @@ type Job struct {
     Applicants        []int     `json:"applicants"`
     PostedOn          time.Time `json:"postedOn"`
 	PostedBy          string      `json:"postedBy"`
+	JobRequirements
+	BlindHiring       BlindHiring `json:"blindHiring"`
+}
+
+// BlindHiring hides who the candidates of a job are from admins until their
+// application reaches RevealStage, or the server's default reveal stage when
+// empty.
+type BlindHiring struct {
+	Enabled     bool             `json:"enabled"`
+	RevealStage ApplicationStage `json:"revealStage,omitempty"`
+}
+
+// JobRequirements are the structured parts of a job posting. Empty values mean
+// the posting does not restrict on that field.
+type JobRequirements struct {
+	RequiredSkills     []string       `json:"requiredSkills"`
+	PreferredSkills    []string       `json:"preferredSkills"`
+	MinExperienceYears int            `json:"minExperienceYears"`
+	EducationLevel     EducationLevel `json:"educationLevel,omitempty"`
+	Location           string         `json:"location,omitempty"`
+	RemotePolicy       RemotePolicy   `json:"remotePolicy,omitempty"`
+	EmploymentType     EmploymentType `json:"employmentType,omitempty"`
+	Salary             *SalaryRange   `json:"salary,omitempty"`
+}
+
+type SalaryRange struct {
+	Min      int    `json:"min"`
+	Max      int    `json:"max"`
+	Currency string `json:"currency"`
 }
 
 type SignUpRequest struct {
@@ -57,8 +155,57 @@ type LoginRequest struct {
     Password string `json:"password"`
 }
 
+// LoginResponse carries the token, or an MFA token when a second step is
+// needed: entering a code, or enrolling first if MFA is mandatory.
 type LoginResponse struct {
-    Token string `json:"token"`
+    Token                 string `json:"token,omitempty"`
+    MFARequired           bool   `json:"mfaRequired,omitempty"`
+    MFAEnrollmentRequired bool   `json:"mfaEnrollmentRequired,omitempty"`
+    MFAToken              string `json:"mfaToken,omitempty"`
+}
+
+type LoginMFARequest struct {
+    MFAToken string `json:"mfaToken"`
+    Code     string `json:"code"`
+}
+
+// MFAState is the second factor of an account as stored.
+type MFAState struct {
+    Secret      string
+    Enabled     bool
+    LastCounter int64
+}
+
+type MFAStatusResponse struct {
+    Enabled           bool `json:"enabled"`
+    Required          bool `json:"required"`
+    RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type MFASetupResponse struct {
+    Secret          string `json:"secret"`
+    ProvisioningURI string `json:"provisioningUri"`
+}
+
+// MFACodeRequest carries a code from the authenticator app or a recovery code.
+type MFACodeRequest struct {
+    Code string `json:"code"`
+}
+
+// MFARecoveryCodesResponse shows the recovery codes, once. Enabling MFA
+// during login also returns the token.
+type MFARecoveryCodesResponse struct {
+    RecoveryCodes []string `json:"recoveryCodes"`
+    Token         string   `json:"token,omitempty"`
+}
+
+// OIDCIdentity is a user signed in through the single sign-on provider.
+type OIDCIdentity struct {
+    Issuer        string
+    Subject       string
+    Email         string
+    EmailVerified bool
+    Name          string
 }
 
 type CreateJobRequest struct {
@@ -66,6 +213,7 @@ type CreateJobRequest struct {
     Description string `json:"description"`
     CompanyName string `json:"companyName"`
     TotalApplications string `json:"totalApplications"`
+    JobRequirements
 }
 
 type CreateJobResponse struct {
@@ -96,6 +244,9 @@ type CreateApplicantProfileRequest struct {
 type AdminGetJobResponse struct {
     Job Job `json:"job"`
     Applicants []Profile `json:"applicants"`
+    Scores map[string]MatchScore `json:"scores,omitempty"`
+    Questions []ScreeningQuestion `json:"questions"`
+    Submissions map[string]ApplicationSubmission `json:"submissions,omitempty"`
 }
 
 type ApplicantsResponse struct {
@@ -123,3 +274,419 @@ type Institute struct {
 type Experience struct {
     Role string `json:"role"`
 }
+
+type JobSearchResult struct {
+    Job              Job     `json:"job"`
+    Rank             float64 `json:"rank"`
+    TitleHighlight   string  `json:"titleHighlight"`
+    DescriptionSnippet string `json:"descriptionSnippet"`
+}
+
+type JobSearchResponse struct {
+    Query   string            `json:"query"`
+    Total   int               `json:"total"`
+    Results []JobSearchResult `json:"results"`
+}
+
+type CandidateSearchFilters struct {
+    Query      string   `json:"query"`
+    Skills     []string `json:"skills"`
+    Education  string   `json:"education"`
+    Experience string   `json:"experience"`
+    Name       string   `json:"name"`
+    // email and phone match exactly, ignoring case and phone formatting
+    Email      string   `json:"email"`
+    Phone      string   `json:"phone"`
+}
+
+type CandidateSearchResult struct {
+    Profile    Profile           `json:"profile"`
+    Rank       float64           `json:"rank"`
+    Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type CandidateSearchResponse struct {
+    Filters CandidateSearchFilters  `json:"filters"`
+    Total   int                     `json:"total"`
+    Results []CandidateSearchResult `json:"results"`
+}
+
+// MatchProfile is the parsed resume data the scoring engine works on.
+type MatchProfile struct {
+    Applicant  int      `json:"applicant"`
+    Skills     []string `json:"skills"`
+    Education  []string `json:"education"`
+    Experience []string `json:"experience"`
+    ResumeText string   `json:"-"`
+}
+
+type MatchScore struct {
+    JobId                  int            `json:"jobId"`
+    Applicant              int            `json:"applicant"`
+    Score                  int            `json:"score"`
+    MatchedSkills          []string       `json:"matchedSkills"`
+    MissingSkills          []string       `json:"missingSkills"`
+    MatchedPreferredSkills []string       `json:"matchedPreferredSkills"`
+    MissingPreferredSkills []string       `json:"missingPreferredSkills"`
+    ExperienceYears        int            `json:"experienceYears"`
+    EducationLevel         EducationLevel `json:"educationLevel,omitempty"`
+    Explanation            []string       `json:"explanation"`
+    ComputedAt             time.Time      `json:"computedAt"`
+}
+
+type JobRecommendation struct {
+    Job     Job      `json:"job"`
+    Score   float64  `json:"score"`
+    Reasons []string `json:"reasons"`
+}
+
+type JobRecommendationsResponse struct {
+    Recommendations []JobRecommendation `json:"recommendations"`
+}
+
+// UpdateProfileRequest is a partial update; fields left out are unchanged.
+// Reset hands fields back to the resume parser.
+type UpdateProfileRequest struct {
+    Name       *string   `json:"name"`
+    Phone      *string   `json:"phone"`
+    Skills     *[]string `json:"skills"`
+    Education  *[]string `json:"education"`
+    Experience *[]string `json:"experience"`
+    Reset      []string  `json:"reset"`
+}
+
+type MeResponse struct {
+    User    User     `json:"user"`
+    Profile *Profile `json:"profile"`
+}
+
+type Application struct {
+    Id          int                `json:"id"`
+    Job         Job                `json:"job"`
+    Applicant   int                `json:"applicant"`
+    Stage       ApplicationStage   `json:"stage"`
+    AppliedAt   time.Time          `json:"appliedAt"`
+    UpdatedAt   time.Time          `json:"updatedAt"`
+    WithdrawnAt *time.Time         `json:"withdrawnAt,omitempty"`
+    History     []ApplicationEvent `json:"history"`
+}
+
+type ApplicationEvent struct {
+    FromStage ApplicationStage `json:"fromStage,omitempty"`
+    ToStage   ApplicationStage `json:"toStage"`
+    ChangedBy *int             `json:"changedBy,omitempty"`
+    ChangedAt time.Time        `json:"changedAt"`
+}
+
+type UpdateApplicationStageRequest struct {
+    Stage ApplicationStage `json:"stage"`
+}
+
+type VerifyEmailRequest struct {
+    Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+    Email string `json:"email"`
+}
+
+type PasswordResetRequest struct {
+    Token    string `json:"token"`
+    Password string `json:"password"`
+}
+
+type LoginOutcome string
+
+const (
+	LoginSucceeded LoginOutcome = "succeeded"
+	LoginFailed    LoginOutcome = "failed"
+	LoginBlocked   LoginOutcome = "blocked"
+)
+
+type LoginAttempt struct {
+    Email       string       `json:"email"`
+    UserId      int          `json:"userId,omitempty"`
+    IP          string       `json:"ip"`
+    Outcome     LoginOutcome `json:"outcome"`
+    AttemptedAt time.Time    `json:"attemptedAt"`
+}
+
+// LoginState is what the login throttle knows about the email of a login,
+// see database.Service.GetLoginState.
+type LoginState struct {
+    UserId       int
+    FailedLogins int
+    LastFailedAt time.Time
+}
+
+type UserFilters struct {
+    UserType UserType `json:"userType,omitempty"`
+    Active   *bool    `json:"active,omitempty"`
+    Query    string   `json:"query,omitempty"`
+}
+
+// UserSummary is how admins see an account in the user management API.
+type UserSummary struct {
+    Id            int        `json:"id"`
+    Name          string     `json:"name"`
+    Email         string     `json:"email"`
+    UserType      UserType   `json:"userType"`
+    Active        bool       `json:"active"`
+    CreatedAt     *time.Time `json:"createdAt,omitempty"`
+    DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+    LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+    MFAEnabled    bool       `json:"mfaEnabled"`
+    // sees candidates redacted until they reach the reveal stage
+    BlindReviewer bool       `json:"blindReviewer"`
+}
+
+type UsersResponse struct {
+    Filters UserFilters   `json:"filters"`
+    Total   int           `json:"total"`
+    Users   []UserSummary `json:"users"`
+}
+
+// APIKey is an integration's credential. The key itself is only returned
+// once, when it is created.
+type APIKey struct {
+    Id         string     `json:"id"`
+    Name       string     `json:"name"`
+    Scopes     []string   `json:"scopes"`
+    CreatedBy  int        `json:"createdBy"`
+    CreatedAt  time.Time  `json:"createdAt"`
+    ExpiresAt  time.Time  `json:"expiresAt"`
+    LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+    LastUsedIP string     `json:"lastUsedIp,omitempty"`
+    RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// APIKeyAuth is what authenticating a request with an API key needs.
+type APIKeyAuth struct {
+    Id         string
+    Hash       string
+    Scopes     []string
+    ExpiresAt  time.Time
+    Revoked    bool
+    OwnerEmail string
+}
+
+type CreateAPIKeyRequest struct {
+    Name          string   `json:"name"`
+    Scopes        []string `json:"scopes"`
+    ExpiresInDays int      `json:"expiresInDays"`
+}
+
+type CreateAPIKeyResponse struct {
+    APIKey
+    Key string `json:"key"`
+}
+
+type APIKeysResponse struct {
+    Keys []APIKey `json:"keys"`
+}
+
+// AuditAction names what an audit log entry records.
+type AuditAction string
+
+const (
+    AuditLoginSucceeded          AuditAction = "login.succeeded"
+    AuditLoginFailed             AuditAction = "login.failed"
+    AuditLoginBlocked            AuditAction = "login.blocked"
+    AuditProfileViewed           AuditAction = "profile.viewed"
+    AuditProfilesListed          AuditAction = "profile.listed"
+    AuditResumeDownloaded        AuditAction = "resume.downloaded"
+    AuditJobCreated              AuditAction = "job.created"
+    AuditJobUpdated              AuditAction = "job.updated"
+    AuditJobQuestionsUpdated     AuditAction = "job.questions_updated"
+    AuditApplicationStageChanged AuditAction = "application.stage_changed"
+    AuditUserInvited             AuditAction = "user.invited"
+    AuditUserRoleChanged         AuditAction = "user.role_changed"
+    AuditUserDeactivated         AuditAction = "user.deactivated"
+    AuditUserReactivated         AuditAction = "user.reactivated"
+    AuditUserPasswordReset       AuditAction = "user.password_reset"
+    AuditUserUnlocked            AuditAction = "user.unlocked"
+    AuditAPIKeyCreated           AuditAction = "api_key.created"
+    AuditAPIKeyRevoked           AuditAction = "api_key.revoked"
+    AuditLogExported             AuditAction = "audit.exported"
+    AuditDataExported            AuditAction = "data.exported"
+    AuditUserErased              AuditAction = "user.erased"
+    AuditRetentionPurged         AuditAction = "retention.purged"
+    AuditJobBlindHiringUpdated   AuditAction = "job.blind_hiring_updated"
+    AuditCandidateRevealed       AuditAction = "candidate.revealed"
+)
+
+type AuditEntry struct {
+    Id         int64             `json:"id"`
+    OccurredAt time.Time         `json:"occurredAt"`
+    Action     AuditAction       `json:"action"`
+    ActorId    int               `json:"actorId,omitempty"`
+    ActorEmail string            `json:"actorEmail,omitempty"`
+    APIKeyId   string            `json:"apiKeyId,omitempty"`
+    TargetType string            `json:"targetType,omitempty"`
+    TargetId   string            `json:"targetId,omitempty"`
+    IP         string            `json:"ip"`
+    RequestId  string            `json:"requestId"`
+    Details    map[string]string `json:"details,omitempty"`
+}
+
+type AuditFilters struct {
+    Action     AuditAction `json:"action,omitempty"`
+    // entries the user either performed or was the target of
+    UserId     int         `json:"userId,omitempty"`
+    ActorId    int         `json:"actorId,omitempty"`
+    ActorEmail string      `json:"actor,omitempty"`
+    TargetType string      `json:"targetType,omitempty"`
+    TargetId   string      `json:"targetId,omitempty"`
+    IP         string      `json:"ip,omitempty"`
+    RequestId  string      `json:"requestId,omitempty"`
+    From       *time.Time  `json:"from,omitempty"`
+    To         *time.Time  `json:"to,omitempty"`
+}
+
+type AuditLogResponse struct {
+    Filters AuditFilters `json:"filters"`
+    Total   int          `json:"total"`
+    Entries []AuditEntry `json:"entries"`
+}
+
+type InviteAdminRequest struct {
+    Name  string `json:"name"`
+    Email string `json:"email"`
+}
+
+type UpdateUserRoleRequest struct {
+    UserType UserType `json:"userType"`
+}
+
+type ResetPasswordRequest struct {
+    Password string `json:"password"`
+}
+
+// TemporaryPasswordResponse carries a generated password. It is only ever
+// shown once, so the admin has to pass it on to the user.
+type TemporaryPasswordResponse struct {
+    User              UserSummary `json:"user"`
+    TemporaryPassword string      `json:"temporaryPassword,omitempty"`
+}
+
+type ApplicationsResponse struct {
+    Applications []Application `json:"applications"`
+}
+
+// ScreeningQuestion is asked when applying to a job. Answers listed in
+// KnockoutAnswers, or numeric answers outside Min/Max, reject the application.
+type ScreeningQuestion struct {
+    Id              int          `json:"id"`
+    Prompt          string       `json:"prompt"`
+    Type            QuestionType `json:"type"`
+    Required        bool         `json:"required"`
+    Options         []string     `json:"options,omitempty"`
+    KnockoutAnswers []string     `json:"knockoutAnswers,omitempty"`
+    Min             *float64     `json:"min,omitempty"`
+    Max             *float64     `json:"max,omitempty"`
+}
+
+type ScreeningAnswer struct {
+    QuestionId int    `json:"questionId"`
+    Prompt     string `json:"prompt,omitempty"`
+    Answer     string `json:"answer"`
+    KnockedOut bool   `json:"knockedOut,omitempty"`
+}
+
+type ScreeningQuestionsRequest struct {
+    Questions []ScreeningQuestion `json:"questions"`
+}
+
+type ScreeningQuestionsResponse struct {
+    Questions []ScreeningQuestion `json:"questions"`
+}
+
+type ApplyJobRequest struct {
+    CoverLetter string            `json:"coverLetter"`
+    Answers     []ScreeningAnswer `json:"answers"`
+}
+
+// ApplicationSubmission is what an applicant handed in with their application.
+type ApplicationSubmission struct {
+    Stage       ApplicationStage  `json:"stage"`
+    CoverLetter string            `json:"coverLetter,omitempty"`
+    Answers     []ScreeningAnswer `json:"answers"`
+    KnockedOut  bool              `json:"knockedOut"`
+}
+
+// ExportedApplication is an application together with what the applicant
+// submitted with it.
+type ExportedApplication struct {
+    Application
+    CoverLetter string            `json:"coverLetter,omitempty"`
+    Answers     []ScreeningAnswer `json:"answers"`
+}
+
+// DataExport is everything stored about an applicant, as handed out by
+// GET /me/export.
+type DataExport struct {
+    ExportedAt   time.Time             `json:"exportedAt"`
+    Account      User                  `json:"account"`
+    Profile      *Profile              `json:"profile"`
+    Applications []ExportedApplication `json:"applications"`
+    AuditTrail   []AuditEntry          `json:"auditTrail"`
+}
+
+type EraseAccountRequest struct {
+    // the account's email, typed again to confirm
+    Confirm string `json:"confirm"`
+    Reason  string `json:"reason"`
+}
+
+// Erasure is the tombstone left behind when an applicant's data is erased.
+// The email is only kept hashed, to tell whether an address was erased.
+type Erasure struct {
+    Id          int            `json:"id"`
+    UserId      int            `json:"userId"`
+    EmailHash   string         `json:"emailHash"`
+    ErasedBy    int            `json:"erasedBy"`
+    SelfService bool           `json:"selfService"`
+    Reason      string         `json:"reason,omitempty"`
+    ErasedAt    time.Time      `json:"erasedAt"`
+    // rows removed or anonymized per table
+    Removed     map[string]int `json:"removed"`
+}
+
+type ErasuresResponse struct {
+    Total    int       `json:"total"`
+    Erasures []Erasure `json:"erasures"`
+}
+
+// RetentionMatch is an applicant a retention rule applies to.
+type RetentionMatch struct {
+    Rule         string    `json:"rule"`
+    UserId       int       `json:"userId"`
+    LastActivity time.Time `json:"lastActivity"`
+}
+
+// RetentionReport lists what the retention rules would purge right now.
+type RetentionReport struct {
+    GeneratedAt time.Time        `json:"generatedAt"`
+    Rules       []string         `json:"rules"`
+    Matches     []RetentionMatch `json:"matches"`
+}
+
+type RetentionPurge struct {
+    Id           int            `json:"id"`
+    Rule         string         `json:"rule"`
+    Action       string         `json:"action"`
+    UserId       int            `json:"userId"`
+    LastActivity time.Time      `json:"lastActivity"`
+    PurgedAt     time.Time      `json:"purgedAt"`
+    ErasureId    int            `json:"erasureId,omitempty"`
+    Removed      map[string]int `json:"removed"`
+}
+
+type RetentionPurgesResponse struct {
+    Total  int              `json:"total"`
+    Purges []RetentionPurge `json:"purges"`
+}
+
+type BlindReviewerRequest struct {
+    BlindReviewer bool `json:"blindReviewer"`
+}
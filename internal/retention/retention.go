@@ -0,0 +1,101 @@
+// Package retention describes how long candidate data is kept. A rule names
+// which applicants it applies to, what happens to their data and after how
+// many months without activity.
+package retention
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Action string
+
+const (
+	// DeleteResume deletes the stored resume files and the profile parsed
+	// from them. The account and its applications stay.
+	DeleteResume Action = "delete_resume"
+	// Erase erases the whole account, like an erasure requested by the
+	// applicant.
+	Erase Action = "erase"
+)
+
+type Subject string
+
+const (
+	// Rejected applicants have applied, and every application of theirs was
+	// rejected or withdrawn. Their activity counts as for Inactive.
+	Rejected Subject = "rejected"
+	// Inactive applicants haven't logged in, applied or changed their profile.
+	Inactive Subject = "inactive"
+)
+
+type Rule struct {
+	Action  Action
+	Subject Subject
+	Months  int
+}
+
+// String is the rule as written in the configuration, e.g.
+// delete_resume:rejected:6.
+func (r Rule) String() string {
+	return string(r.Action) + ":" + string(r.Subject) + ":" + strconv.Itoa(r.Months)
+}
+
+// Cutoff is the time before which the last activity has to be for the rule to
+// apply.
+func (r Rule) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, -r.Months, 0)
+}
+
+// ParseRules reads a comma separated list of rules in the form
+// action:subject:months.
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("retention rule %q is not action:subject:months", item)
+		}
+		rule := Rule{Action: Action(parts[0]), Subject: Subject(parts[1])}
+		if rule.Action != DeleteResume && rule.Action != Erase {
+			return nil, fmt.Errorf("retention rule %q: action must be %s or %s", item, DeleteResume, Erase)
+		}
+		if rule.Subject != Rejected && rule.Subject != Inactive {
+			return nil, fmt.Errorf("retention rule %q: subject must be %s or %s", item, Rejected, Inactive)
+		}
+		months, err := strconv.Atoi(parts[2])
+		if err != nil || months < 1 {
+			return nil, fmt.Errorf("retention rule %q: months must be a positive number", item)
+		}
+		rule.Months = months
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Rules is a list of rules that reads and prints itself in the format of
+// ParseRules, for configuration.
+type Rules []Rule
+
+func (r Rules) String() string {
+	rules := make([]string, len(r))
+	for i, rule := range r {
+		rules[i] = rule.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+func (r *Rules) UnmarshalText(text []byte) error {
+	rules, err := ParseRules(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package screening validates the screening questions attached to a job and
+// evaluates an applicant's answers against them.
+package screening
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	MaxQuestions         = 20
+	MaxPromptLength      = 500
+	MaxOptions           = 20
+	MaxOptionLength      = 200
+	MaxFreeTextLength    = 2000
+	MaxCoverLetterLength = 10000
+)
+
+// ValidateQuestions normalizes questions in place and checks that every
+// knockout rule refers to an answer the question can actually receive.
+func ValidateQuestions(questions []models.ScreeningQuestion) error {
+	if len(questions) > MaxQuestions {
+		return fmt.Errorf("a job can have at most %d screening questions", MaxQuestions)
+	}
+	for i := range questions {
+		q := &questions[i]
+		n := i + 1
+		q.Prompt = strings.TrimSpace(q.Prompt)
+		if q.Prompt == "" || utf8.RuneCountInString(q.Prompt) > MaxPromptLength {
+			return fmt.Errorf("question %d: prompt is required and can be at most %d characters", n, MaxPromptLength)
+		}
+		switch q.Type {
+		case models.FreeText:
+			if len(q.Options) > 0 || len(q.KnockoutAnswers) > 0 || q.Min != nil || q.Max != nil {
+				return fmt.Errorf("question %d: free text questions take no options or knockout rules", n)
+			}
+		case models.YesNo:
+			if len(q.Options) > 0 || q.Min != nil || q.Max != nil {
+				return fmt.Errorf("question %d: yes/no questions take no options or range", n)
+			}
+			for j, answer := range q.KnockoutAnswers {
+				answer, ok := normalizeYesNo(answer)
+				if !ok {
+					return fmt.Errorf("question %d: knockout answers must be yes or no", n)
+				}
+				q.KnockoutAnswers[j] = answer
+			}
+		case models.MultipleChoice:
+			if q.Min != nil || q.Max != nil {
+				return fmt.Errorf("question %d: multiple choice questions take no range", n)
+			}
+			if len(q.Options) < 2 || len(q.Options) > MaxOptions {
+				return fmt.Errorf("question %d: multiple choice questions need between 2 and %d options", n, MaxOptions)
+			}
+			seen := map[string]bool{}
+			for j, option := range q.Options {
+				option = strings.TrimSpace(option)
+				if option == "" || utf8.RuneCountInString(option) > MaxOptionLength || seen[option] {
+					return fmt.Errorf("question %d: options must be unique, non-empty and at most %d characters", n, MaxOptionLength)
+				}
+				seen[option] = true
+				q.Options[j] = option
+			}
+			for _, answer := range q.KnockoutAnswers {
+				if !seen[answer] {
+					return fmt.Errorf("question %d: knockout answer %q is not one of the options", n, answer)
+				}
+			}
+		case models.Numeric:
+			if len(q.Options) > 0 || len(q.KnockoutAnswers) > 0 {
+				return fmt.Errorf("question %d: numeric questions use min and max instead of options", n)
+			}
+			if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+				return fmt.Errorf("question %d: min cannot be greater than max", n)
+			}
+		default:
+			return fmt.Errorf("question %d: type must be one of free_text, yes_no, multiple_choice, numeric", n)
+		}
+	}
+	return nil
+}
+
+func normalizeYesNo(answer string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "yes", "y", "true":
+		return "yes", true
+	case "no", "n", "false":
+		return "no", true
+	}
+	return "", false
+}
+
+// Evaluate checks the answers against the questions and reports whether any of
+// them is a knockout. The returned answers are normalized, carry the prompt
+// they were given to, and are in question order; unanswered optional
+// questions are left out.
+func Evaluate(questions []models.ScreeningQuestion, answers []models.ScreeningAnswer) ([]models.ScreeningAnswer, bool, error) {
+	given := map[int]string{}
+	for _, answer := range answers {
+		if _, ok := given[answer.QuestionId]; ok {
+			return nil, false, fmt.Errorf("question %d is answered more than once", answer.QuestionId)
+		}
+		given[answer.QuestionId] = strings.TrimSpace(answer.Answer)
+	}
+
+	evaluated := []models.ScreeningAnswer{}
+	knockedOut := false
+	for _, q := range questions {
+		answer, ok := given[q.Id]
+		delete(given, q.Id)
+		if !ok || answer == "" {
+			if q.Required {
+				return nil, false, fmt.Errorf("question %d (%s) is required", q.Id, q.Prompt)
+			}
+			continue
+		}
+		result := models.ScreeningAnswer{QuestionId: q.Id, Prompt: q.Prompt, Answer: answer}
+		switch q.Type {
+		case models.FreeText:
+			if utf8.RuneCountInString(answer) > MaxFreeTextLength {
+				return nil, false, fmt.Errorf("question %d: answers can be at most %d characters", q.Id, MaxFreeTextLength)
+			}
+		case models.YesNo:
+			normalized, ok := normalizeYesNo(answer)
+			if !ok {
+				return nil, false, fmt.Errorf("question %d: answer yes or no", q.Id)
+			}
+			result.Answer = normalized
+			result.KnockedOut = slices.Contains(q.KnockoutAnswers, normalized)
+		case models.MultipleChoice:
+			if !slices.Contains(q.Options, answer) {
+				return nil, false, fmt.Errorf("question %d: answer one of %s", q.Id, strings.Join(q.Options, ", "))
+			}
+			result.KnockedOut = slices.Contains(q.KnockoutAnswers, answer)
+		case models.Numeric:
+			value, err := strconv.ParseFloat(answer, 64)
+			// NaN compares false to both bounds and would pass any knockout
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, false, fmt.Errorf("question %d: answer with a number", q.Id)
+			}
+			result.KnockedOut = (q.Min != nil && value < *q.Min) || (q.Max != nil && value > *q.Max)
+		}
+		knockedOut = knockedOut || result.KnockedOut
+		evaluated = append(evaluated, result)
+	}
+	for id := range given {
+		return nil, false, fmt.Errorf("question %d does not belong to this job", id)
+	}
+	return evaluated, knockedOut, nil
+}
+
+// Public strips the knockout rules so applicants can't tailor their answers.
+func Public(questions []models.ScreeningQuestion) []models.ScreeningQuestion {
+	public := make([]models.ScreeningQuestion, len(questions))
+	for i, q := range questions {
+		q.KnockoutAnswers = nil
+		q.Min = nil
+		q.Max = nil
+		public[i] = q
+	}
+	return public
+}
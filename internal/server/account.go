@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/mailer"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// issueToken stores a single-use token for the user and returns the signed
+// value to put into the emailed link.
+func (s *Server) issueToken(ctx context.Context, userId int, email string, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	expiresAt := time.Now().UTC().Add(ttl)
+	if err := s.db.WithContext(ctx).CreateUserToken(id, userId, purpose, expiresAt); err != nil {
+		return "", err
+	}
+	return s.CreateActionToken(email, purpose, id, expiresAt)
+}
+
+func (s *Server) sendVerificationEmail(ctx context.Context, userId int, email string) error {
+	token, err := s.issueToken(ctx, userId, email, models.TokenVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: "Please confirm your email address by opening the link below. It is valid for 48 hours.\n\n" +
+			link + "\n\nIf you did not sign up, you can ignore this email.",
+	})
+}
+
+func (s *Server) sendPasswordResetEmail(ctx context.Context, userId int, email string) error {
+	token, err := s.issueToken(ctx, userId, email, models.TokenResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: "Open the link below to choose a new password. It is valid for one hour and can be used once.\n\n" +
+			link + "\n\nIf you did not ask for a password reset, you can ignore this email.",
+	})
+}
+
+func (s *Server) VerifyEmailHandler(c echo.Context) error {
+	var apiReq models.VerifyEmailRequest
+	err := json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || apiReq.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	tokenId, err := s.DecodeActionToken(apiReq.Token, models.TokenVerifyEmail)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": database.ErrInvalidToken.Error()})
+	}
+	_, err = s.dbFor(c).VerifyEmail(tokenId)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidToken) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Email verified"})
+}
+
+func (s *Server) ResendVerificationHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if user.EmailVerified {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email is already verified"})
+	}
+
+	err = s.sendVerificationEmail(c.Request().Context(), user.Id, user.Email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error sending verification email"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+func (s *Server) ForgotPasswordHandler(c echo.Context) error {
+	var apiReq models.ForgotPasswordRequest
+	err := json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || strings.TrimSpace(apiReq.Email) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	// the response, and its timing, is the same whether or not the account
+	// exists, so this endpoint can't be used to find out who has an account
+	apiResp := map[string]string{"message": "If an account exists for this email, a reset link has been sent"}
+	user, err := s.dbFor(c).GetUser(strings.TrimSpace(apiReq.Email))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logError(c, err)
+		}
+		return c.JSON(http.StatusOK, apiResp)
+	}
+	if !user.Active {
+		return c.JSON(http.StatusOK, apiResp)
+	}
+	// the echo context is reused once the handler returns. Shutdown waits
+	// for the email, as it does for the background jobs: the requests are
+	// drained first, so it is tracked before the wait starts.
+	ctx := c.Request().Context()
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		if err := s.sendPasswordResetEmail(ctx, user.Id, user.Email); err != nil {
+			slog.ErrorContext(ctx, "sending the password reset email", "user_id", user.Id, "error", err)
+		}
+	}()
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) ResetPasswordHandler(c echo.Context) error {
+	var apiReq models.PasswordResetRequest
+	err := json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || apiReq.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if err = ValidatePassword(apiReq.Password); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	tokenId, err := s.DecodeActionToken(apiReq.Token, models.TokenResetPassword)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": database.ErrInvalidToken.Error()})
+	}
+
+	passwordHash, err := PassToHash(apiReq.Password)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	_, err = s.dbFor(c).ResetPassword(tokenId, passwordHash)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidToken) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/apikey"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	apiKeyHeader      = "X-API-Key"
+	apiKeyContextKey  = "apiKey"
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+	maxAPIKeyName     = 100
+)
+
+// apiKeyRoutes are the endpoints integrations can call with an API key, and
+// the scope each of them needs. Keys are refused everywhere else.
+var apiKeyRoutes = map[string]apikey.Scope{
+	"GET /jobs":                                            apikey.JobsRead,
+	"GET /jobs/search":                                     apikey.JobsRead,
+	"GET /jobs/:job_id/questions":                          apikey.JobsRead,
+	"POST /admin/job":                                      apikey.JobsWrite,
+	"PUT /admin/job/:job_id":                               apikey.JobsWrite,
+	"PUT /admin/job/:job_id/questions":                     apikey.JobsWrite,
+	"GET /admin/job/:job_id":                               apikey.ApplicationsRead,
+	"GET /admin/applicants":                                apikey.ApplicationsRead,
+	"GET /admin/applicants/search":                         apikey.ApplicationsRead,
+	"GET /admin/applicant/:applicant_id":                   apikey.ApplicationsRead,
+	"GET /admin/applicant/:applicant_id/resume":            apikey.ApplicationsRead,
+	"PUT /admin/job/:job_id/applicant/:applicant_id/stage": apikey.ApplicationsWrite,
+}
+
+// authenticateAPIKeys checks the API key of requests that carry one. A key
+// acts for the admin who created it, limited to the endpoints its scopes
+// allow; the handlers get the admin from callerEmail.
+func (s *Server) authenticateAPIKeys(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(apiKeyHeader)
+		if key == "" {
+			return next(c)
+		}
+		scope, ok := apiKeyRoutes[c.Request().Method+" "+c.Path()]
+		if !ok {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "API keys can't be used for this endpoint"})
+		}
+		id, secret, ok := apikey.Parse(key)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+		}
+		auth, err := s.dbFor(c).GetAPIKeyAuth(id)
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+		}
+		if err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		if !apikey.Matches(secret, auth.Hash) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+		}
+		if auth.Revoked {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "API key has been revoked"})
+		}
+		now := time.Now().UTC()
+		if !now.Before(auth.ExpiresAt) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "API key has expired"})
+		}
+		if !slices.Contains(auth.Scopes, string(scope)) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "API key lacks the " + string(scope) + " scope"})
+		}
+
+		if err = s.dbFor(c).TouchAPIKey(auth.Id, now, clientIP(c)); err != nil {
+			logError(c, err)
+		}
+		c.Set(apiKeyContextKey, auth)
+		return next(c)
+	}
+}
+
+// callerEmail returns who calls an endpoint that accepts API keys: the owner
+// of the key, or else the subject of the bearer token.
+func (s *Server) callerEmail(c echo.Context) (string, error) {
+	if auth, ok := c.Get(apiKeyContextKey).(models.APIKeyAuth); ok {
+		return auth.OwnerEmail, nil
+	}
+	return s.DecodeAuthToken(c.Request().Header.Get("Authorization"))
+}
+
+func (s *Server) AdminListAPIKeysHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	keys, err := s.dbFor(c).ListAPIKeys()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, models.APIKeysResponse{Keys: keys})
+}
+
+func (s *Server) AdminCreateAPIKeyHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	userId, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	var apiReq models.CreateAPIKeyRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	name := strings.TrimSpace(apiReq.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyName {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("name is required and can be at most %d characters", maxAPIKeyName)})
+	}
+	if len(apiReq.Scopes) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "at least one scope is required"})
+	}
+	scopes := []string{}
+	for _, scope := range apiReq.Scopes {
+		if !apikey.ValidScope(scope) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown scope " + scope})
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	days := apiReq.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+	if days < 1 || days > maxAPIKeyDays {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("expiresInDays must be between 1 and %d", maxAPIKeyDays)})
+	}
+
+	key, id, hash, err := apikey.Generate()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	created, err := s.dbFor(c).CreateAPIKey(models.APIKey{
+		Id:        id,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedBy: userId,
+		ExpiresAt: time.Now().UTC().AddDate(0, 0, days),
+	}, hash)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditAPIKeyCreated, ActorId: userId, ActorEmail: user,
+		TargetType: "api_key", TargetId: created.Id,
+		Details: map[string]string{"name": created.Name, "scopes": strings.Join(created.Scopes, " ")}})
+	return c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{APIKey: created, Key: key})
+}
+
+func (s *Server) AdminRevokeAPIKeyHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	key, err := s.dbFor(c).RevokeAPIKey(c.Param("key_id"))
+	if errors.Is(err, database.ErrAPIKeyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditAPIKeyRevoked, ActorEmail: user, TargetType: "api_key",
+		TargetId: key.Id})
+	return c.JSON(http.StatusOK, key)
+}
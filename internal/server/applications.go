@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+// Stages an admin can move an application to. Withdrawing is up to the
+// applicant.
+var adminStages = map[models.ApplicationStage]bool{
+	models.StageApplied:   true,
+	models.StageScreening: true,
+	models.StageInterview: true,
+	models.StageOffer:     true,
+	models.StageHired:     true,
+	models.StageRejected:  true,
+}
+
+func (s *Server) WithdrawApplicationHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	jobId, err := strconv.Atoi(c.QueryParam("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	err = s.dbFor(c).WithdrawApplication(jobId, id)
+	if err != nil {
+		if errors.Is(err, database.ErrApplicationNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, database.ErrApplicationClosed) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Application can no longer be withdrawn"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error withdrawing application"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Application withdrawn"})
+}
+
+func (s *Server) AdminUpdateApplicationStageHandler(c echo.Context) error {
+	user, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	adminId, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	jobId, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	applicantId, err := s.applicantParam(c)
+	if err != nil {
+		return applicantParamError(c, err)
+	}
+	var apiReq models.UpdateApplicationStageRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || !adminStages[apiReq.Stage] {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "stage must be one of applied, screening, interview, offer, hired, rejected"})
+	}
+
+	err = s.dbFor(c).UpdateApplicationStage(jobId, applicantId, apiReq.Stage, adminId)
+	if err != nil {
+		if errors.Is(err, database.ErrApplicationNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, database.ErrApplicationClosed) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Application was withdrawn"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditApplicationStageChanged, ActorId: adminId, ActorEmail: user,
+		TargetType: "user", TargetId: strconv.Itoa(applicantId),
+		Details: map[string]string{"jobId": strconv.Itoa(jobId), "stage": string(apiReq.Stage)}})
+	return c.JSON(http.StatusOK, map[string]string{"message": "Application stage updated"})
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/logging"
+	"resume-backend-parser/internal/models"
+)
+
+// audit appends an entry for the request to the audit log, filling in the
+// time, client IP, request id and the API key used. The IP is the parsed one,
+// so whatever a client puts in its headers fits the column. A failure to
+// record is logged but doesn't fail the request.
+func (s *Server) audit(c echo.Context, entry models.AuditEntry) {
+	entry.OccurredAt = time.Now().UTC()
+	entry.IP = clientIP(c)
+	entry.RequestId = logging.RequestID(c.Request().Context())
+	if auth, ok := c.Get(apiKeyContextKey).(models.APIKeyAuth); ok {
+		entry.APIKeyId = auth.Id
+	}
+	if err := s.dbFor(c).AppendAuditEntry(entry); err != nil {
+		logError(c, err)
+	}
+}
+
+func auditFilters(c echo.Context) (models.AuditFilters, error) {
+	filters := models.AuditFilters{
+		Action:     models.AuditAction(c.QueryParam("action")),
+		ActorEmail: c.QueryParam("actor"),
+		TargetType: c.QueryParam("targetType"),
+		TargetId:   c.QueryParam("targetId"),
+		IP:         c.QueryParam("ip"),
+		RequestId:  c.QueryParam("requestId"),
+	}
+	if ip := net.ParseIP(filters.IP); ip != nil {
+		// IPs are stored in their canonical form
+		filters.IP = ip.String()
+	}
+	for name, dest := range map[string]*int{"actorId": &filters.ActorId, "userId": &filters.UserId} {
+		v := c.QueryParam(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return models.AuditFilters{}, errors.New("Invalid " + name)
+		}
+		*dest = id
+	}
+	for name, dest := range map[string]**time.Time{"from": &filters.From, "to": &filters.To} {
+		v := c.QueryParam(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, v)
+		}
+		if err != nil {
+			return models.AuditFilters{}, fmt.Errorf("%s must be a date or an RFC 3339 time", name)
+		}
+		t = t.UTC()
+		*dest = &t
+	}
+	return filters, nil
+}
+
+func (s *Server) AdminAuditLogHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filters, err := auditFilters(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	apiResp := models.AuditLogResponse{Filters: filters}
+	apiResp.Total, err = s.dbFor(c).CountAuditEntries(filters)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Entries, err = s.dbFor(c).ListAuditEntries(filters, limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+var auditCSVHeader = []string{"id", "occurredAt", "action", "actorId", "actorEmail", "apiKeyId", "targetType",
+	"targetId", "ip", "requestId", "details"}
+
+func auditCSVRecord(entry models.AuditEntry) []string {
+	actorId := ""
+	if entry.ActorId != 0 {
+		actorId = strconv.Itoa(entry.ActorId)
+	}
+	details := ""
+	if len(entry.Details) > 0 {
+		b, _ := json.Marshal(entry.Details)
+		details = string(b)
+	}
+	return []string{strconv.FormatInt(entry.Id, 10), entry.OccurredAt.Format(time.RFC3339), string(entry.Action),
+		actorId, csvSafe(entry.ActorEmail), entry.APIKeyId, entry.TargetType, csvSafe(entry.TargetId), entry.IP,
+		entry.RequestId, csvSafe(details)}
+}
+
+// csvSafe keeps spreadsheets from running a value as a formula. Failed
+// logins put whatever was typed into the actor email.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// AdminExportAuditLogHandler streams all entries matching the filters as CSV
+// or, with format=json, as one JSON object per line. Exports are audited too.
+func (s *Server) AdminExportAuditLogHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	filters, err := auditFilters(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be csv or json"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditLogExported, ActorEmail: user, Details: map[string]string{"format": format}})
+
+	filename := "audit-log-" + time.Now().UTC().Format("20060102-150405")
+	resp := c.Response()
+	if format == "json" {
+		resp.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		resp.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.jsonl"`)
+		resp.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(resp)
+		err = s.dbFor(c).ExportAuditEntries(filters, func(entry models.AuditEntry) error {
+			return enc.Encode(entry)
+		})
+	} else {
+		resp.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		resp.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.csv"`)
+		resp.WriteHeader(http.StatusOK)
+		w := csv.NewWriter(resp)
+		w.Write(auditCSVHeader)
+		err = s.dbFor(c).ExportAuditEntries(filters, func(entry models.AuditEntry) error {
+			return w.Write(auditCSVRecord(entry))
+		})
+		w.Flush()
+		if err == nil {
+			err = w.Error()
+		}
+	}
+	// the status is already sent, so a failure can only cut the export short
+	if err != nil {
+		logError(c, err)
+	}
+	return nil
+}
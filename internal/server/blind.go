@@ -0,0 +1,299 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/blind"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+func (s *Server) revealStage(job models.Job) models.ApplicationStage {
+	if job.BlindHiring.RevealStage != "" {
+		return job.BlindHiring.RevealStage
+	}
+	return s.blindRevealStage
+}
+
+// applicantParam reads the applicant_id path parameter, which is either a
+// user id or, as blind hiring views show them, a pseudonym.
+func (s *Server) applicantParam(c echo.Context) (int, error) {
+	param := c.Param("applicant_id")
+	if blind.IsPseudonym(param) {
+		return s.dbFor(c).GetUserIdByPseudonym(param)
+	}
+	return strconv.Atoi(param)
+}
+
+// applicantParamError maps the errors of applicantParam onto a response.
+func applicantParamError(c echo.Context, err error) error {
+	if errors.Is(err, database.ErrUserNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	logError(c, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+}
+
+func (s *Server) auditReveal(c echo.Context, user string, applicantId int, jobId int, stage models.ApplicationStage) {
+	s.audit(c, models.AuditEntry{Action: models.AuditCandidateRevealed, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(applicantId), Details: map[string]string{"jobId": strconv.Itoa(jobId), "stage": string(stage)}})
+}
+
+// redactJobView hides the candidates of a job who haven't reached its reveal
+// stage yet, in the profiles as well as the scores and submissions. Showing
+// one who has is audited as a reveal.
+func (s *Server) redactJobView(c echo.Context, user string, apiResp *models.AdminGetJobResponse) error {
+	reveal := s.revealStage(apiResp.Job)
+	stages, err := s.dbFor(c).GetJobApplicationStages(apiResp.Job.Id)
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for id := range stages {
+		ids = append(ids, id)
+	}
+	for _, profile := range apiResp.Applicants {
+		id, err := strconv.Atoi(profile.Applicant)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	pseudonyms, err := s.dbFor(c).GetPseudonyms(ids)
+	if err != nil {
+		return err
+	}
+	hidden := func(key string) (string, bool) {
+		id, err := strconv.Atoi(key)
+		if err != nil || blind.Reached(stages[id], reveal) {
+			return key, false
+		}
+		return pseudonyms[id], true
+	}
+
+	for i, profile := range apiResp.Applicants {
+		if pseudonym, ok := hidden(profile.Applicant); ok {
+			apiResp.Applicants[i] = blind.Redact(profile, pseudonym)
+			continue
+		}
+		id, _ := strconv.Atoi(profile.Applicant)
+		apiResp.Applicants[i].Pseudonym = pseudonyms[id]
+		s.auditReveal(c, user, id, apiResp.Job.Id, reveal)
+	}
+	scores := map[string]models.MatchScore{}
+	for key, score := range apiResp.Scores {
+		if pseudonym, ok := hidden(key); ok {
+			score.Applicant = 0
+			key = pseudonym
+		}
+		scores[key] = score
+	}
+	apiResp.Scores = scores
+	submissions := map[string]models.ApplicationSubmission{}
+	for key, submission := range apiResp.Submissions {
+		if pseudonym, ok := hidden(key); ok {
+			key = pseudonym
+			submission = blind.RedactSubmission(submission)
+		}
+		submissions[key] = submission
+	}
+	apiResp.Submissions = submissions
+	// the ids would tell who the candidates are
+	apiResp.Job.Applicants = nil
+	return nil
+}
+
+// applicantHidden decides whether the caller may see who an applicant is
+// outside of a job: not while one of their applications is blind and none
+// has reached its reveal stage. For blind reviewers every application is
+// blind. Showing a candidate of a blind application is audited as a reveal.
+func (s *Server) applicantHidden(c echo.Context, user string, applicantId int) (bool, error) {
+	reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+	if err != nil {
+		return false, err
+	}
+	return s.applicantHiddenFrom(c, user, reviewer, applicantId)
+}
+
+// applicantHiddenFrom is applicantHidden for a caller known to be a blind
+// reviewer or not.
+func (s *Server) applicantHiddenFrom(c echo.Context, user string, reviewer bool, applicantId int) (bool, error) {
+	applications, err := s.dbFor(c).GetApplications(applicantId)
+	if err != nil {
+		return false, err
+	}
+	blindApplications := 0
+	for _, application := range applications {
+		if !application.Job.BlindHiring.Enabled && !reviewer {
+			continue
+		}
+		blindApplications++
+		reveal := s.revealStage(application.Job)
+		if blind.Reached(blind.Stages(application), reveal) {
+			s.auditReveal(c, user, applicantId, application.Job.Id, reveal)
+			return false, nil
+		}
+	}
+	return blindApplications > 0 || reviewer, nil
+}
+
+// redactProfiles hides the candidates of a list the caller may not see. Blind
+// reviewers see every one redacted, they are revealed through the applicant
+// view only; other admins see those redacted that applicantHidden hides.
+func (s *Server) redactProfiles(c echo.Context, user string, reviewer bool, profiles []models.Profile) error {
+	ids := []int{}
+	for _, profile := range profiles {
+		id, err := strconv.Atoi(profile.Applicant)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	pseudonyms, err := s.dbFor(c).GetPseudonyms(ids)
+	if err != nil {
+		return err
+	}
+	for i, profile := range profiles {
+		hidden := reviewer
+		if !hidden {
+			if hidden, err = s.applicantHiddenFrom(c, user, false, ids[i]); err != nil {
+				return err
+			}
+		}
+		if hidden {
+			profiles[i] = blind.Redact(profile, pseudonyms[ids[i]])
+		}
+	}
+	return nil
+}
+
+// AdminSetBlindHiringHandler turns blind hiring for a job on or off. Blind
+// reviewers can't change it.
+func (s *Server) AdminSetBlindHiringHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if reviewer {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Blind reviewers cannot change blind hiring"})
+	}
+
+	jobId, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.BlindHiring
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if apiReq.RevealStage != "" && !blind.ValidRevealStage(apiReq.RevealStage) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "revealStage must be one of screening, interview, offer, hired"})
+	}
+
+	err = s.dbFor(c).SetJobBlindHiring(jobId, apiReq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditJobBlindHiringUpdated, ActorEmail: user, TargetType: "job",
+		TargetId: strconv.Itoa(jobId), Details: map[string]string{"enabled": strconv.FormatBool(apiReq.Enabled),
+			"revealStage": string(apiReq.RevealStage)}})
+	job, err := s.dbFor(c).GetJob(jobId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, job.BlindHiring)
+}
+
+// AdminSetBlindReviewerHandler marks an admin as a blind reviewer, who sees
+// every candidate redacted until they reach the reveal stage. Blind reviewers
+// can't change it, not even for themselves.
+func (s *Server) AdminSetBlindReviewerHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if reviewer {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Blind reviewers cannot change blind hiring"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.BlindReviewerRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	target, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	if target.UserType != models.Admin {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Only admins can be blind reviewers"})
+	}
+
+	err = s.dbFor(c).SetBlindReviewer(userId, apiReq.BlindReviewer)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditUserRoleChanged, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(userId), Details: map[string]string{"blindReviewer": strconv.FormatBool(apiReq.BlindReviewer)}})
+	apiResp, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// rows rewritten per database transaction
+const reencryptBatchSize = 100
+
+// runReencryption encrypts what is still in plaintext or under an older key,
+// see startJob. After a key rotation the old key can be removed from the
+// keyfile once a run found nothing left to do.
+func (s *Server) runReencryption(ctx context.Context) int {
+	rows, files, err := s.reencrypt(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "re-encryption", "error", err)
+	}
+	if rows > 0 || files > 0 {
+		slog.InfoContext(ctx, "re-encryption: rewrote stale data", "rows", rows, "files", files)
+	}
+	return rows + files
+}
+
+// reencrypt works in batches and files, and stops in between when ctx ends.
+func (s *Server) reencrypt(ctx context.Context) (int, int, error) {
+	rows := 0
+	for ctx.Err() == nil {
+		n, err := s.db.ReencryptPII(reencryptBatchSize)
+		rows += n
+		if err != nil {
+			return rows, 0, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	files, err := s.reencryptResumes(ctx)
+	return rows, files, err
+}
+
+// reencryptResumes rewrites the stored resume files that are stale. A file
+// that fails is logged and skipped until the next run.
+func (s *Server) reencryptResumes(ctx context.Context) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(s.resumesDir, "*", "*"))
+	if err != nil {
+		return 0, err
+	}
+	rewritten := 0
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := s.reencryptResume(ctx, path)
+		if err != nil {
+			slog.ErrorContext(ctx, "re-encryption: rewriting a resume", "path", path, "error", err)
+			continue
+		}
+		if ok {
+			rewritten++
+		}
+	}
+	return rewritten, nil
+}
+
+func (s *Server) reencryptResume(ctx context.Context, path string) (bool, error) {
+	s.resumesMu.Lock()
+	defer s.resumesMu.Unlock()
+	info, err := os.Lstat(path)
+	// the applicant may have uploaded a new resume or been erased meanwhile
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil || !info.Mode().IsRegular() || filepath.Base(path)[0] == '.' {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !s.cipher.Stale(data) {
+		return false, err
+	}
+	data, err = s.cipher.Decrypt(data, resumeAAD(path))
+	if err != nil {
+		return false, err
+	}
+	return true, writeResume(ctx, s.cipher, path, data)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/health"
+	"resume-backend-parser/internal/metrics"
+)
+
+// the parser is a third party that is paid per request, it is asked less often
+const parserCheckCacheFor = time.Minute
+
+// job is a background job, run by startJob.
+type job struct {
+	name     string
+	interval time.Duration
+
+	mu sync.Mutex
+	// when the current run started, zero between runs
+	running  time.Time
+	finished time.Time
+}
+
+// startJob runs a job right away and then every interval, until ctx ends. A
+// run that is going on when ctx ends should stop soon after. run returns how
+// many items it processed, for the backlog metric.
+func (s *Server) startJob(ctx context.Context, name string, interval time.Duration, run func(context.Context) int) {
+	j := &job{name: name, interval: interval}
+	s.jobs = append(s.jobs, j)
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			j.mu.Lock()
+			j.running = time.Now()
+			j.mu.Unlock()
+			metrics.JobRunning.WithLabelValues(name).Set(1)
+			processed := run(ctx)
+			j.mu.Lock()
+			j.running, j.finished = time.Time{}, time.Now()
+			j.mu.Unlock()
+			metrics.JobRunning.WithLabelValues(name).Set(0)
+			metrics.JobBacklog.WithLabelValues(name).Set(float64(processed))
+			metrics.JobLastRun.WithLabelValues(name).SetToCurrentTime()
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// behind reports a job whose runs don't keep up with its interval, e.g.
+// because there is more to purge or re-encrypt than one run gets through.
+func (j *job) behind(now time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.running.IsZero() && now.Sub(j.running) > j.interval {
+		return fmt.Errorf("%s has been running since %s", j.name, j.running.UTC().Format(time.RFC3339))
+	}
+	if !j.finished.IsZero() && now.Sub(j.finished) > 2*j.interval {
+		return fmt.Errorf("%s last finished at %s", j.name, j.finished.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// readinessChecks are the components of /readyz. Resumes are still stored
+// without the parser and the background jobs catch up later, so those two
+// only degrade the server.
+func (s *Server) readinessChecks(cfg config.Health) *health.Checker {
+	check := func(name string, optional bool, f func(ctx context.Context) error) *health.Check {
+		return &health.Check{Name: name, Optional: optional, Timeout: cfg.Timeout, CacheFor: cfg.CacheFor, Func: f}
+	}
+	parser := check("parser", true, s.checkParser)
+	parser.CacheFor = max(parser.CacheFor, parserCheckCacheFor)
+	return health.New(
+		check("database", false, s.db.Ping),
+		check("storage", false, s.checkStorage),
+		parser,
+		check("jobs", true, s.checkJobs),
+	)
+}
+
+// checkStorage checks that resumes can be written.
+func (s *Server) checkStorage(ctx context.Context) error {
+	f, err := os.CreateTemp(s.resumesDir, ".health-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkParser checks that the resume parser answers. Only its server
+// failing counts, as it doesn't have an endpoint for this.
+func (s *Server) checkParser(ctx context.Context) error {
+	if s.parserAPIKey == "" {
+		return errors.New("no API key is configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.parserURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", s.parserAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Server) checkJobs(ctx context.Context) error {
+	var behind []string
+	now := time.Now()
+	for _, j := range s.jobs {
+		if err := j.behind(now); err != nil {
+			behind = append(behind, err.Error())
+		}
+	}
+	if len(behind) > 0 {
+		return errors.New(strings.Join(behind, "; "))
+	}
+	return nil
+}
+
+// LivezHandler answers as long as the process serves requests, whatever the
+// state of the components; restarting it wouldn't bring them back.
+func (s *Server) LivezHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": string(health.Up)})
+}
+
+// ReadyzHandler reports whether the server should get traffic, with the
+// status of every component. It answers 503 when a required component is
+// down and once the server is shutting down.
+func (s *Server) ReadyzHandler(c echo.Context) error {
+	if s.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+	}
+	report := s.readiness.Check(c.Request().Context())
+	if report.Status == health.Down {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
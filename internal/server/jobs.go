@@ -0,0 +1,202 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	maxTitleLength       = 50
+	maxCompanyNameLength = 50
+	maxDescriptionLength = 10000
+	maxLocationLength    = 100
+	maxSkillLength       = 50
+	maxSkillsPerList     = 50
+	maxExperienceYears   = 50
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var educationLevels = map[models.EducationLevel]bool{
+	models.EducationNone:       true,
+	models.EducationHighSchool: true,
+	models.EducationAssociate:  true,
+	models.EducationBachelor:   true,
+	models.EducationMaster:     true,
+	models.EducationDoctorate:  true,
+}
+
+var remotePolicies = map[models.RemotePolicy]bool{
+	models.Onsite: true,
+	models.Hybrid: true,
+	models.Remote: true,
+}
+
+var employmentTypes = map[models.EmploymentType]bool{
+	models.FullTime:   true,
+	models.PartTime:   true,
+	models.Contract:   true,
+	models.Internship: true,
+	models.Temporary:  true,
+}
+
+// normalizeSkills trims each skill and drops empty and case-insensitive
+// duplicate entries while keeping the original order.
+func normalizeSkills(skills []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, skill := range skills {
+		skill = strings.TrimSpace(skill)
+		key := strings.ToLower(skill)
+		if skill == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, skill)
+	}
+	return normalized
+}
+
+func validateSkills(field string, skills []string) error {
+	if len(skills) > maxSkillsPerList {
+		return fmt.Errorf("%s can contain at most %d entries", field, maxSkillsPerList)
+	}
+	for _, skill := range skills {
+		if utf8.RuneCountInString(skill) > maxSkillLength {
+			return fmt.Errorf("%s entries can be at most %d characters", field, maxSkillLength)
+		}
+	}
+	return nil
+}
+
+// validateJob normalizes a job posting in place and reports the first field
+// that is missing or out of range.
+func validateJob(job *models.Job) error {
+	job.Title = strings.TrimSpace(job.Title)
+	job.CompanyName = strings.TrimSpace(job.CompanyName)
+	job.Location = strings.TrimSpace(job.Location)
+	job.RequiredSkills = normalizeSkills(job.RequiredSkills)
+	job.PreferredSkills = normalizeSkills(job.PreferredSkills)
+
+	if job.Title == "" || utf8.RuneCountInString(job.Title) > maxTitleLength {
+		return fmt.Errorf("title is required and can be at most %d characters", maxTitleLength)
+	}
+	if strings.TrimSpace(job.Description) == "" || utf8.RuneCountInString(job.Description) > maxDescriptionLength {
+		return fmt.Errorf("description is required and can be at most %d characters", maxDescriptionLength)
+	}
+	if job.CompanyName == "" || utf8.RuneCountInString(job.CompanyName) > maxCompanyNameLength {
+		return fmt.Errorf("companyName is required and can be at most %d characters", maxCompanyNameLength)
+	}
+	if job.TotalApplications < 1 {
+		return errors.New("totalApplications must be a positive number")
+	}
+	if err := validateSkills("requiredSkills", job.RequiredSkills); err != nil {
+		return err
+	}
+	if err := validateSkills("preferredSkills", job.PreferredSkills); err != nil {
+		return err
+	}
+	required := map[string]bool{}
+	for _, skill := range job.RequiredSkills {
+		required[strings.ToLower(skill)] = true
+	}
+	for _, skill := range job.PreferredSkills {
+		if required[strings.ToLower(skill)] {
+			return fmt.Errorf("%s cannot be both a required and a preferred skill", skill)
+		}
+	}
+	if job.MinExperienceYears < 0 || job.MinExperienceYears > maxExperienceYears {
+		return fmt.Errorf("minExperienceYears must be between 0 and %d", maxExperienceYears)
+	}
+	if job.EducationLevel != "" && !educationLevels[job.EducationLevel] {
+		return errors.New("educationLevel must be one of none, high_school, associate, bachelor, master, doctorate")
+	}
+	if utf8.RuneCountInString(job.Location) > maxLocationLength {
+		return fmt.Errorf("location can be at most %d characters", maxLocationLength)
+	}
+	if job.RemotePolicy != "" && !remotePolicies[job.RemotePolicy] {
+		return errors.New("remotePolicy must be one of onsite, hybrid, remote")
+	}
+	if job.EmploymentType != "" && !employmentTypes[job.EmploymentType] {
+		return errors.New("employmentType must be one of full_time, part_time, contract, internship, temporary")
+	}
+	if job.Salary != nil {
+		job.Salary.Currency = strings.ToUpper(strings.TrimSpace(job.Salary.Currency))
+		if job.Salary.Min < 0 || job.Salary.Max < job.Salary.Min {
+			return errors.New("salary.min must be non-negative and no greater than salary.max")
+		}
+		if !currencyCode.MatchString(job.Salary.Currency) {
+			return errors.New("salary.currency must be a three letter ISO 4217 code")
+		}
+	}
+	return nil
+}
+
+func (s *Server) AdminUpdateJobOpeningHandler(c echo.Context) error {
+	user, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	jobId, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.CreateJobRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	totalApplications, err := strconv.Atoi(apiReq.TotalApplications)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	job := models.Job{
+		Title:             apiReq.Title,
+		Description:       apiReq.Description,
+		CompanyName:       apiReq.CompanyName,
+		TotalApplications: totalApplications,
+		JobRequirements:   apiReq.JobRequirements,
+	}
+	err = validateJob(&job)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	err = s.dbFor(c).UpdateJob(jobId, job)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	updated, err := s.dbFor(c).GetJob(jobId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditJobUpdated, ActorEmail: user, TargetType: "job",
+		TargetId: strconv.Itoa(jobId), Details: map[string]string{"title": job.Title}})
+	s.recomputeJobScores(c.Request().Context(), updated)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Job updated successfully"})
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/logging"
+)
+
+// request ids clients may pass in X-Request-ID, others are replaced so they
+// can't forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID gives every request an id, the one of X-Request-ID when the
+// client sent a valid one, and puts it in the request context for the log.
+func requestID() echo.MiddlewareFunc {
+	generate := middleware.DefaultRequestIDConfig.Generator
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Generator: generate,
+		RequestIDHandler: func(c echo.Context, id string) {
+			if !validRequestID.MatchString(id) {
+				id = generate()
+				c.Response().Header().Set(echo.HeaderXRequestID, id)
+			}
+			c.SetRequest(c.Request().WithContext(logging.WithRequestID(c.Request().Context(), id)))
+		},
+	})
+}
+
+// accessLog logs every request. The query string isn't logged, as searches
+// take personal data such as emails.
+func accessLog() echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogMethod:    true,
+		LogURIPath:   true,
+		LogRoutePath: true,
+		LogStatus:    true,
+		LogLatency:   true,
+		LogError:     true,
+		HandleError:  true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			level := slog.LevelInfo
+			if v.Status >= 500 {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{slog.String("method", v.Method), slog.String("path", v.URIPath),
+				slog.String("route", v.RoutePath), slog.Int("status", v.Status), slog.Duration("latency", v.Latency)}
+			if v.Error != nil {
+				attrs = append(attrs, slog.Any("error", v.Error))
+			}
+			slog.LogAttrs(c.Request().Context(), level, "request", attrs...)
+			return nil
+		},
+	})
+}
+
+func logPanic(c echo.Context, err error, stack []byte) error {
+	slog.ErrorContext(c.Request().Context(), "panic handling request", "route", c.Path(), "error", err,
+		"stack", string(stack))
+	return err
+}
+
+// logError logs an error handling a request, with the request id and the
+// route. The source of the record is the caller.
+func logError(c echo.Context, err error) {
+	logAt(c.Request().Context(), slog.LevelError, "handling request", "route", c.Path(), "error", err)
+}
+
+// logAt logs with the caller of its caller as the source.
+func logAt(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	logger.Handler().Handle(ctx, r)
+}
+
+// dbFor is the database with the context of the request, so that its log
+// records carry the request id.
+func (s *Server) dbFor(c echo.Context) database.Service {
+	return s.db.WithContext(c.Request().Context())
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+// invalidCredentials is the one answer to every failed login, so the response
+// doesn't tell whether the account exists.
+const invalidCredentials = "Invalid email or password"
+
+// loginWait returns the login state of the email, whether an account has it,
+// and how long the attempt has to wait because of earlier failures of the
+// email or the client IP. Emails without an account wait just as long.
+func (s *Server) loginWait(c echo.Context, email string, ip string, now time.Time) (models.LoginState, bool, time.Duration, error) {
+	db := s.dbFor(c)
+	failures, lastFailure, err := db.GetIPLoginFailures(ip, now.Add(-s.lockout.IPWindow))
+	if err != nil {
+		return models.LoginState{}, false, 0, err
+	}
+	wait := s.lockout.IPWait(failures, lastFailure, now)
+
+	state, err := db.GetLoginState(email)
+	if err != nil {
+		return models.LoginState{}, false, 0, err
+	}
+	var lockedUntil time.Time
+	if state.FailedLogins >= s.lockout.LockoutThreshold {
+		lockedUntil = state.LastFailedAt.Add(s.lockout.LockoutDuration)
+	}
+	wait = max(wait, s.lockout.AccountWait(state.FailedLogins, state.LastFailedAt, lockedUntil, now))
+	return state, state.UserId != 0, wait, nil
+}
+
+// checkPassword checks the password of the account. It takes as long whether
+// or not the account exists, and hashes a matching password that is still
+// stored in plaintext.
+func (s *Server) checkPassword(c echo.Context, email string, password string) (bool, error) {
+	id, passwordHash, err := s.dbFor(c).GetPasswordHash(email)
+	if errors.Is(err, database.ErrUserNotFound) {
+		CheckPassword(unknownAccountHash(), password)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !CheckPassword(passwordHash, password) {
+		return false, nil
+	}
+	if LegacyPassword(passwordHash) {
+		// the login goes ahead, the next one tries again
+		hash, err := PassToHash(password)
+		if err == nil {
+			err = s.dbFor(c).ReplacePasswordHash(id, passwordHash, hash)
+		}
+		if err != nil {
+			logError(c, err)
+		}
+	}
+	return true, nil
+}
+
+var loginAuditActions = map[models.LoginOutcome]models.AuditAction{
+	models.LoginSucceeded: models.AuditLoginSucceeded,
+	models.LoginFailed:    models.AuditLoginFailed,
+	models.LoginBlocked:   models.AuditLoginBlocked,
+}
+
+// recordLoginAttempt records an attempt for the throttle and in the audit
+// log. A failure to record is logged but doesn't fail the login.
+func (s *Server) recordLoginAttempt(c echo.Context, attempt models.LoginAttempt) {
+	if err := s.dbFor(c).RecordLoginAttempt(attempt); err != nil {
+		logError(c, err)
+	}
+	entry := models.AuditEntry{Action: loginAuditActions[attempt.Outcome], ActorId: attempt.UserId, ActorEmail: attempt.Email}
+	if attempt.UserId != 0 {
+		entry.TargetType = "user"
+		entry.TargetId = strconv.Itoa(attempt.UserId)
+	}
+	s.audit(c, entry)
+}
+
+// firstFactorPassed is the step after the password or the identity provider
+// checked out. Users with MFA, or who need it, get a token for the second
+// factor or for enrolling; everyone else is logged in.
+func (s *Server) firstFactorPassed(c echo.Context, user models.User, attempt models.LoginAttempt, failedLogins int) (models.LoginResponse, error) {
+	var apiResp models.LoginResponse
+	var err error
+	// the login only counts as successful after the second factor, so the
+	// failed attempts are kept until then
+	if user.MFAEnabled || s.mfaRequired(user) {
+		tokenType := MFAPendingTokenType
+		apiResp.MFARequired = true
+		if !user.MFAEnabled {
+			tokenType = MFAEnrollTokenType
+			apiResp.MFARequired = false
+			apiResp.MFAEnrollmentRequired = true
+		}
+		apiResp.MFAToken, err = s.CreateMFAToken(user.Email, tokenType)
+		return apiResp, err
+	}
+	apiResp.Token, err = s.loggedIn(c, attempt, failedLogins)
+	return apiResp, err
+}
+
+// loggedIn records the successful attempt, clears earlier failures and
+// returns the auth token.
+func (s *Server) loggedIn(c echo.Context, attempt models.LoginAttempt, failedLogins int) (string, error) {
+	attempt.Outcome = models.LoginSucceeded
+	s.recordLoginAttempt(c, attempt)
+	if failedLogins > 0 {
+		if err := s.dbFor(c).UnlockUser(attempt.UserId); err != nil {
+			logError(c, err)
+		}
+	}
+	return s.CreateTokens(attempt.Email)
+}
+
+// completeLogin logs in after the second factor and responds with the auth
+// token.
+func (s *Server) completeLogin(c echo.Context, attempt models.LoginAttempt, failedLogins int) error {
+	var apiResp models.LoginResponse
+	var err error
+	apiResp.Token, err = s.loggedIn(c, attempt, failedLogins)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func tooManyLoginAttempts(c echo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many login attempts, try again later"})
+}
+
+func (s *Server) AdminUnlockUserHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	err = s.dbFor(c).UnlockUser(userId)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditUserUnlocked, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(userId)})
+	apiResp, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/matching"
+	"resume-backend-parser/internal/models"
+)
+
+// scoreApplication computes and stores the match score of one applicant for
+// one job. Applicants without a parsed profile get no score.
+func (s *Server) scoreApplication(ctx context.Context, job models.Job, userId int) (models.MatchScore, bool, error) {
+	db := s.db.WithContext(ctx)
+	profile, err := db.GetMatchProfile(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MatchScore{}, false, nil
+	}
+	if err != nil {
+		return models.MatchScore{}, false, err
+	}
+	score := matching.Score(job, profile)
+	return score, true, db.SaveMatchScore(score)
+}
+
+// recomputeApplicantScores rescores every job the applicant applied to, e.g.
+// after their resume was parsed again.
+func (s *Server) recomputeApplicantScores(ctx context.Context, userId int) {
+	jobs, err := s.db.WithContext(ctx).GetAppliedJobs(userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "rescoring the applicant", "user_id", userId, "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if _, _, err := s.scoreApplication(ctx, job, userId); err != nil {
+			slog.ErrorContext(ctx, "rescoring an application", "job_id", job.Id, "user_id", userId, "error", err)
+		}
+	}
+}
+
+// recomputeJobScores rescores every applicant of a job, e.g. after its
+// requirements were edited.
+func (s *Server) recomputeJobScores(ctx context.Context, job models.Job) {
+	seen := map[int]bool{}
+	for _, applicant := range job.Applicants {
+		if seen[applicant] {
+			continue
+		}
+		seen[applicant] = true
+		if _, _, err := s.scoreApplication(ctx, job, applicant); err != nil {
+			slog.ErrorContext(ctx, "rescoring an application", "job_id", job.Id, "user_id", applicant, "error", err)
+		}
+	}
+}
+
+// jobScores returns the stored score of each applicant keyed by applicant id,
+// computing any that are missing.
+func (s *Server) jobScores(ctx context.Context, job models.Job) (map[string]models.MatchScore, error) {
+	stored, err := s.db.WithContext(ctx).GetMatchScores(job.Id)
+	if err != nil {
+		return nil, err
+	}
+	scores := map[string]models.MatchScore{}
+	for _, score := range stored {
+		scores[strconv.Itoa(score.Applicant)] = score
+	}
+	for _, applicant := range job.Applicants {
+		key := strconv.Itoa(applicant)
+		if _, ok := scores[key]; ok {
+			continue
+		}
+		score, ok, err := s.scoreApplication(ctx, job, applicant)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			scores[key] = score
+		}
+	}
+	return scores, nil
+}
+
+// sortByScore orders applicants by descending match score; unscored
+// applicants go last in their original order.
+func sortByScore(applicants []models.Profile, scores map[string]models.MatchScore) {
+	sort.SliceStable(applicants, func(i, j int) bool {
+		a, aOk := scores[applicants[i].Applicant]
+		b, bOk := scores[applicants[j].Applicant]
+		if aOk != bOk {
+			return aOk
+		}
+		return a.Score > b.Score
+	})
+}
+
+func (s *Server) GetRecommendedJobsHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	profile, err := s.dbFor(c).GetMatchProfile(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Upload a resume to get recommendations"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	jobs, err := s.dbFor(c).GetOpenJobs(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	recommendations := matching.Recommend(profile, jobs)
+	start := min(offset, len(recommendations))
+	end := min(start+limit, len(recommendations))
+	apiResp := models.JobRecommendationsResponse{Recommendations: recommendations[start:end]}
+	return c.JSON(http.StatusOK, apiResp)
+}
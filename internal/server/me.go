@@ -0,0 +1,210 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	maxNameLength      = 50
+	maxPhoneLength     = 50
+	maxProfileEntries  = 50
+	maxProfileEntryLen = 200
+)
+
+var phoneNumber = regexp.MustCompile(`^[0-9+()\-. ]*$`)
+
+func trimEntries(field string, entries []string) ([]string, error) {
+	trimmed := []string{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if utf8.RuneCountInString(entry) > maxProfileEntryLen {
+			return nil, fmt.Errorf("%s entries can be at most %d characters", field, maxProfileEntryLen)
+		}
+		trimmed = append(trimmed, entry)
+	}
+	if len(trimmed) > maxProfileEntries {
+		return nil, fmt.Errorf("%s can contain at most %d entries", field, maxProfileEntries)
+	}
+	return trimmed, nil
+}
+
+// validateProfileUpdate normalizes an applicant's corrections in place.
+func validateProfileUpdate(update *models.UpdateProfileRequest) error {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			return fmt.Errorf("name is required and can be at most %d characters", maxNameLength)
+		}
+		update.Name = &name
+	}
+	if update.Phone != nil {
+		phone := strings.TrimSpace(*update.Phone)
+		if len(phone) > maxPhoneLength || !phoneNumber.MatchString(phone) {
+			return errors.New("phone can only contain digits, spaces and + ( ) - .")
+		}
+		update.Phone = &phone
+	}
+	if update.Skills != nil {
+		skills := normalizeSkills(*update.Skills)
+		if err := validateSkills("skills", skills); err != nil {
+			return err
+		}
+		update.Skills = &skills
+	}
+	if update.Education != nil {
+		education, err := trimEntries("education", *update.Education)
+		if err != nil {
+			return err
+		}
+		update.Education = &education
+	}
+	if update.Experience != nil {
+		experience, err := trimEntries("experience", *update.Experience)
+		if err != nil {
+			return err
+		}
+		update.Experience = &experience
+	}
+	edited := map[string]bool{
+		"name": update.Name != nil, "phone": update.Phone != nil, "skills": update.Skills != nil,
+		"education": update.Education != nil, "experience": update.Experience != nil,
+	}
+	for _, field := range update.Reset {
+		if !slices.Contains(database.ManualProfileFields, field) {
+			return fmt.Errorf("reset can only contain %s", strings.Join(database.ManualProfileFields, ", "))
+		}
+		if edited[field] {
+			return fmt.Errorf("%s cannot be edited and reset at the same time", field)
+		}
+	}
+	return nil
+}
+
+func (s *Server) GetMeHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var apiResp models.MeResponse
+	apiResp.User, err = s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	profile, err := s.dbFor(c).GetApplicantProfile(apiResp.User.Id)
+	if err == nil {
+		apiResp.Profile = &profile
+	} else if !errors.Is(err, database.ErrProfileNotFound) {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) UpdateMyProfileHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	var apiReq models.UpdateProfileRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	err = validateProfileUpdate(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	err = s.dbFor(c).UpdateProfileManual(id, apiReq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Upload a resume before editing your profile"})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.recomputeApplicantScores(c.Request().Context(), id)
+
+	var apiResp models.ApplicantResponse
+	apiResp.Applicant, err = s.dbFor(c).GetApplicantProfile(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) GetMyApplicationsHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	apiResp := models.ApplicationsResponse{}
+	apiResp.Applications, err = s.dbFor(c).GetApplications(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	for i, application := range apiResp.Applications {
+		apiResp.Applications[i] = applicantView(application)
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+// applicantView is an application as its applicant gets to see it: the
+// applicants of other people and the admins who moved it between stages are
+// none of their business.
+func applicantView(application models.Application) models.Application {
+	application.Job.Applicants = nil
+	history := make([]models.ApplicationEvent, len(application.History))
+	for i, event := range application.History {
+		event.ChangedBy = nil
+		history[i] = event
+	}
+	application.History = history
+	return application
+}
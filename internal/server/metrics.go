@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/metrics"
+)
+
+// instrumentRequests measures every request by its route, rather than its
+// path, which would give a series per id.
+func instrumentRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request().Method, route(c), strconv.Itoa(status(c, err))).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// route is the route a request matched, or "unmatched".
+func route(c echo.Context) string {
+	if c.Path() == "" {
+		return "unmatched"
+	}
+	return c.Path()
+}
+
+// status is the status of the response to a request the handler returned
+// err for.
+func status(c echo.Context, err error) int {
+	// errors only turn into responses further out, unless the access log
+	// already handled them
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// MetricsHandler serves the Prometheus metrics, to callers with the metrics
+// token when one is configured.
+func (s *Server) MetricsHandler(c echo.Context) error {
+	if s.metricsToken != "" {
+		token := c.Request().Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+s.metricsToken)) != 1 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		}
+	}
+	metrics.Handler().ServeHTTP(c.Response(), c.Request())
+	return nil
+}
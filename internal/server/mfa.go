@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/totp"
+)
+
+const recoveryCodeCount = 10
+
+// mfaRequired reports whether the user has to use a second factor, whether
+// or not they enrolled yet.
+func (s *Server) mfaRequired(user models.User) bool {
+	return s.mfaRequiredForAdmins && user.UserType == models.Admin
+}
+
+// verifyMFACode accepts a current code from the authenticator app or an
+// unused recovery code. Either can only be used once.
+func (s *Server) verifyMFACode(c echo.Context, userId int, mfa models.MFAState, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if counter, ok := totp.Validate(mfa.Secret, code, time.Now(), mfa.LastCounter); ok {
+		return s.dbFor(c).UseTOTPCounter(userId, counter)
+	}
+	if len(code) == totp.Digits {
+		return false, nil
+	}
+	return s.dbFor(c).UseRecoveryCode(userId, totp.HashRecoveryCode(code))
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// mfaEnrollmentCaller authenticates the enrollment endpoints, which accept an
+// auth token as well as the enroll token handed out when logging in to an
+// account that has to enroll first.
+func (s *Server) mfaEnrollmentCaller(token string) (string, bool, error) {
+	email, err := s.DecodeAuthToken(token)
+	if err == nil {
+		return email, false, nil
+	}
+	if len(token) < 8 {
+		return "", false, err
+	}
+	email, err = s.DecodeMFAToken(token[7:], MFAEnrollTokenType)
+	return email, err == nil, err
+}
+
+func (s *Server) LoginMFAHandler(c echo.Context) error {
+	var apiReq models.LoginMFARequest
+	err := json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || apiReq.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	email, err := s.DecodeMFAToken(apiReq.MFAToken, MFAPendingTokenType)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	// wrong codes count as failed logins, so the throttle applies here too
+	now := time.Now().UTC()
+	attempt := models.LoginAttempt{Email: email, IP: clientIP(c), AttemptedAt: now}
+	state, _, wait, err := s.loginWait(c, email, attempt.IP, now)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	attempt.UserId = state.UserId
+	if wait > 0 {
+		attempt.Outcome = models.LoginBlocked
+		s.recordLoginAttempt(c, attempt)
+		return tooManyLoginAttempts(c, wait)
+	}
+	user, err := s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !user.Active {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is deactivated"})
+	}
+	mfa, err := s.dbFor(c).GetMFA(user.Id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !mfa.Enabled {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	ok, err := s.verifyMFACode(c, user.Id, mfa, apiReq.Code)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !ok {
+		attempt.Outcome = models.LoginFailed
+		s.recordLoginAttempt(c, attempt)
+		err = s.dbFor(c).RecordFailedLogin(user.Id, now, s.lockout.LockoutThreshold, now.Add(s.lockout.LockoutDuration))
+		if err != nil {
+			logError(c, err)
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid code"})
+	}
+	return s.completeLogin(c, attempt, state.FailedLogins)
+}
+
+func (s *Server) GetMFAStatusHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	apiResp := models.MFAStatusResponse{Enabled: user.MFAEnabled, Required: s.mfaRequired(user)}
+	if user.MFAEnabled {
+		apiResp.RecoveryCodesLeft, err = s.dbFor(c).CountRecoveryCodes(user.Id)
+		if err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) SetupMFAHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, _, err := s.mfaEnrollmentCaller(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	err = s.dbFor(c).SetMFASecret(id, secret)
+	if err != nil {
+		if errors.Is(err, database.ErrMFAAlreadyEnabled) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp := models.MFASetupResponse{Secret: secret, ProvisioningURI: totp.ProvisioningURI(secret, Issuer, email)}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) EnableMFAHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, enrolling, err := s.mfaEnrollmentCaller(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	var apiReq models.MFACodeRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	mfa, err := s.dbFor(c).GetMFA(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if mfa.Enabled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": database.ErrMFAAlreadyEnabled.Error()})
+	}
+	if mfa.Secret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Set up two-factor authentication first"})
+	}
+	// only a code from the app proves the secret was stored correctly
+	counter, ok := totp.Validate(mfa.Secret, strings.TrimSpace(apiReq.Code), time.Now(), 0)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid code"})
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	err = s.dbFor(c).EnableMFA(id, counter, hashes)
+	if err != nil {
+		if errors.Is(err, database.ErrMFAAlreadyEnabled) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	apiResp := models.MFARecoveryCodesResponse{RecoveryCodes: codes}
+	if enrolling {
+		// enrolling finishes the login that handed out the enroll token
+		attempt := models.LoginAttempt{Email: email, UserId: id, IP: clientIP(c), Outcome: models.LoginSucceeded, AttemptedAt: time.Now().UTC()}
+		s.recordLoginAttempt(c, attempt)
+		if err = s.dbFor(c).UnlockUser(id); err != nil {
+			logError(c, err)
+		}
+		apiResp.Token, err = s.CreateTokens(email)
+		if err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+// requireMFACode authenticates the caller and checks the code in the request
+// body, for changes to an enabled second factor. When ok is false the error
+// response has been written already and err is the result of writing it.
+func (s *Server) requireMFACode(c echo.Context) (models.User, bool, error) {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return models.User{}, false, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return models.User{}, false, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return models.User{}, false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	var apiReq models.MFACodeRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return models.User{}, false, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	mfa, err := s.dbFor(c).GetMFA(user.Id)
+	if err != nil {
+		logError(c, err)
+		return models.User{}, false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !mfa.Enabled {
+		return models.User{}, false, c.JSON(http.StatusBadRequest, map[string]string{"error": "Two-factor authentication is not enabled"})
+	}
+	ok, err := s.verifyMFACode(c, user.Id, mfa, apiReq.Code)
+	if err != nil {
+		logError(c, err)
+		return models.User{}, false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !ok {
+		return models.User{}, false, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid code"})
+	}
+	return user, true, nil
+}
+
+func (s *Server) DisableMFAHandler(c echo.Context) error {
+	user, ok, err := s.requireMFACode(c)
+	if !ok {
+		return err
+	}
+	if s.mfaRequired(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Two-factor authentication is mandatory for admins"})
+	}
+	err = s.dbFor(c).DisableMFA(user.Id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+func (s *Server) RegenerateRecoveryCodesHandler(c echo.Context) error {
+	user, ok, err := s.requireMFACode(c)
+	if !ok {
+		return err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	err = s.dbFor(c).ReplaceRecoveryCodes(user.Id, hashes)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
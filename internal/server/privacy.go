@@ -0,0 +1,318 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/models"
+)
+
+const maxErasureReasonLength = 500
+
+// resumeDir is where the resume files of a user are stored.
+func (s *Server) resumeDir(userId int) string {
+	return filepath.Join(s.resumesDir, strconv.Itoa(userId))
+}
+
+// WriteDataExport writes the export as a ZIP archive: account.json,
+// profile.json (if there is a profile), applications.json, audit_trail.json
+// and the stored resume files from dir under resumes/, decrypted with cipher.
+func WriteDataExport(w io.Writer, export models.DataExport, dir string, cipher *encryption.Cipher) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"account.json", export.Account},
+		{"profile.json", export.Profile},
+		{"applications.json", export.Applications},
+		{"audit_trail.json", export.AuditTrail},
+	}
+	for _, file := range files {
+		if file.name == "profile.json" && export.Profile == nil {
+			continue
+		}
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(file.v); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err = addFileToZip(archive, "resumes/"+entry.Name(), filepath.Join(dir, entry.Name()), cipher); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func addFileToZip(archive *zip.Writer, name string, path string, cipher *encryption.Cipher) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := readResume(cipher, path)
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	dst, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(data)
+	return err
+}
+
+// ExportMyDataHandler sends the caller everything stored about them as a ZIP
+// archive, see WriteDataExport.
+func (s *Server) ExportMyDataHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	export := models.DataExport{ExportedAt: time.Now().UTC()}
+	export.Account, err = s.dbFor(c).GetUser(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	id := export.Account.Id
+	profile, err := s.dbFor(c).GetApplicantProfile(id)
+	if err == nil {
+		export.Profile = &profile
+	} else if !errors.Is(err, database.ErrProfileNotFound) {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	applications, err := s.dbFor(c).GetApplications(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	submissions, err := s.dbFor(c).GetApplicantSubmissions(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	export.Applications = []models.ExportedApplication{}
+	for _, application := range applications {
+		application = applicantView(application)
+		submission := submissions[application.Id]
+		answers := submission.Answers
+		if answers == nil {
+			answers = []models.ScreeningAnswer{}
+		}
+		export.Applications = append(export.Applications, models.ExportedApplication{
+			Application: application, CoverLetter: submission.CoverLetter, Answers: answers})
+	}
+	export.AuditTrail = []models.AuditEntry{}
+	err = s.dbFor(c).ExportAuditEntries(models.AuditFilters{UserId: id}, func(entry models.AuditEntry) error {
+		export.AuditTrail = append(export.AuditTrail, entry)
+		return nil
+	})
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	dir := s.resumeDir(id)
+
+	s.audit(c, models.AuditEntry{Action: models.AuditDataExported, ActorId: id, ActorEmail: email, TargetType: "user",
+		TargetId: strconv.Itoa(id)})
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "application/zip")
+	resp.Header().Set(echo.HeaderContentDisposition,
+		`attachment; filename="my-data-`+export.ExportedAt.Format("20060102")+`.zip"`)
+	resp.WriteHeader(http.StatusOK)
+	// the status is already sent, so a failure can only cut the archive short
+	if err = WriteDataExport(resp, export, dir, s.cipher); err != nil {
+		logError(c, err)
+	}
+	return nil
+}
+
+// eraseApplicantData deletes the stored files of the applicant and then the
+// rest of their data. The files go first: if the database part fails the
+// erasure can simply be repeated, while the other way round the files would be
+// left without anything pointing at them.
+func (s *Server) eraseApplicantData(c echo.Context, userId int, erasure models.Erasure) (models.Erasure, error) {
+	if err := s.removeResumes(userId); err != nil {
+		return models.Erasure{}, err
+	}
+	return s.dbFor(c).EraseApplicant(userId, erasure)
+}
+
+// eraseApplicant handles an erasure request; actor is recorded in the audit
+// log.
+func (s *Server) eraseApplicant(c echo.Context, userId int, erasure models.Erasure, actor models.AuditEntry) error {
+	erasure.Reason = strings.TrimSpace(erasure.Reason)
+	if utf8.RuneCountInString(erasure.Reason) > maxErasureReasonLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("reason can be at most %d characters", maxErasureReasonLength)})
+	}
+	user, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	if user.UserType != models.Applicant {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": database.ErrNotApplicant.Error()})
+	}
+
+	erasure.ErasedAt = time.Now().UTC()
+	erasure, err = s.eraseApplicantData(c, userId, erasure)
+	if errors.Is(err, database.ErrNotApplicant) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	actor.Action = models.AuditUserErased
+	actor.TargetType = "user"
+	actor.TargetId = strconv.Itoa(userId)
+	actor.Details = map[string]string{"erasureId": strconv.Itoa(erasure.Id)}
+	s.audit(c, actor)
+	return c.JSON(http.StatusOK, erasure)
+}
+
+// EraseMyAccountHandler lets applicants erase their own account. They confirm
+// by typing their email again.
+func (s *Server) EraseMyAccountHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	email, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	id, err := s.dbFor(c).GetUserId(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	var apiReq models.EraseAccountRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if !strings.EqualFold(strings.TrimSpace(apiReq.Confirm), email) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "confirm must be the email of the account"})
+	}
+
+	// the account is gone afterwards, so the entry only keeps the id
+	return s.eraseApplicant(c, id, models.Erasure{ErasedBy: id, SelfService: true, Reason: apiReq.Reason},
+		models.AuditEntry{ActorId: id})
+}
+
+func (s *Server) AdminEraseUserHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	adminId, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.EraseAccountRequest
+	// the body is optional
+	if err = json.NewDecoder(c.Request().Body).Decode(&apiReq); err != nil && !errors.Is(err, io.EOF) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	return s.eraseApplicant(c, userId, models.Erasure{ErasedBy: adminId, Reason: apiReq.Reason},
+		models.AuditEntry{ActorId: adminId, ActorEmail: user})
+}
+
+func (s *Server) AdminListErasuresHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var apiResp models.ErasuresResponse
+	apiResp.Total, err = s.dbFor(c).CountErasures()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Erasures, err = s.dbFor(c).ListErasures(limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
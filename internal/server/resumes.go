@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/tracing"
+)
+
+// resumeAAD binds an encrypted resume file to its applicant and name, as
+// resumes/<applicant id>/<file name>.
+func resumeAAD(path string) string {
+	return "resumes/" + filepath.Base(filepath.Dir(path)) + "/" + filepath.Base(path)
+}
+
+// replaceResume stores a new resume of a user in place of their previous one
+// and returns its path.
+func (s *Server) replaceResume(ctx context.Context, userId int, filename string, data []byte) (string, error) {
+	dir := s.resumeDir(userId)
+	s.resumesMu.Lock()
+	defer s.resumesMu.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(filename))
+	return path, writeResume(ctx, s.cipher, path, data)
+}
+
+// removeResumes deletes the stored resume files of a user.
+func (s *Server) removeResumes(userId int) error {
+	s.resumesMu.Lock()
+	defer s.resumesMu.Unlock()
+	return os.RemoveAll(s.resumeDir(userId))
+}
+
+// readResume reads a stored resume file, decrypting it if it is encrypted.
+func readResume(cipher *encryption.Cipher, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Decrypt(data, resumeAAD(path))
+}
+
+// writeResume stores a resume file, encrypted when there is a cipher. The
+// file is replaced in one go, so readers never see half of it.
+func writeResume(ctx context.Context, cipher *encryption.Cipher, path string, data []byte) (err error) {
+	_, span := tracing.Start(ctx, "resumes.write", attribute.Int("size", len(data)))
+	defer func() { tracing.End(span, err) }()
+	data, err = cipher.Encrypt(data, resumeAAD(path))
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// AdminDownloadResumeHandler sends the resume file an applicant uploaded.
+func (s *Server) AdminDownloadResumeHandler(c echo.Context) error {
+	user, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	applicantId, err := s.applicantParam(c)
+	if err != nil {
+		return applicantParamError(c, err)
+	}
+	profile, err := s.dbFor(c).GetApplicantProfile(applicantId)
+	if errors.Is(err, database.ErrProfileNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if profile.ResumeFileAddress == "" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No resume uploaded"})
+	}
+	// the resume tells who the candidate is
+	hidden, err := s.applicantHidden(c, user, applicantId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if hidden {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "The resume is hidden until the candidate is revealed"})
+	}
+
+	filename := filepath.Base(profile.ResumeFileAddress)
+	path := filepath.Join(s.resumeDir(applicantId), filename)
+	data, err := readResume(s.cipher, path)
+	if errors.Is(err, os.ErrNotExist) {
+		logError(c, err)
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No resume uploaded"})
+	}
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditResumeDownloaded, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(applicantId)})
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"resume-backend-parser/internal/models"
+)
+
+// contactKeys are the fields of the parser response holding contact details.
+var contactKeys = map[string]bool{
+	"email": true, "emails": true, "phone": true, "phones": true, "phone_number": true, "address": true,
+	"url": true, "urls": true, "links": true, "linkedin": true, "github": true, "website": true,
+}
+
+var (
+	contactEmail = regexp.MustCompile(`[^\s@<>()"',;:]+@[^\s@<>()"',;:]+\.[A-Za-z]{2,}`)
+	contactURL   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+	// numbers of digits with the usual separators, see isPhoneNumber
+	contactNumber = regexp.MustCompile(`\+?\(?\d[\d ().-]{5,18}\d`)
+	digitGroup    = regexp.MustCompile(`\d+`)
+	year          = regexp.MustCompile(`^(?:19|20)\d\d$`)
+)
+
+// ResumeText is the text of a parsed resume as stored for the full text
+// search and matching. It is kept in plaintext, so the contact details are
+// left out: the contact fields of the parser response, and emails, links and
+// phone numbers anywhere else, the parsed email and phone included.
+func ResumeText(parsed map[string]interface{}, profile models.ProfileThirdParty) string {
+	var lines []string
+	for _, line := range collectStrings(withoutContacts(parsed)) {
+		for _, contact := range []string{profile.Email, profile.Phone} {
+			if contact = strings.TrimSpace(contact); contact != "" {
+				line = strings.ReplaceAll(line, contact, " ")
+			}
+		}
+		line = contactEmail.ReplaceAllString(line, " ")
+		line = contactURL.ReplaceAllString(line, " ")
+		line = contactNumber.ReplaceAllStringFunc(line, func(number string) string {
+			if isPhoneNumber(number) {
+				return " "
+			}
+			return number
+		})
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// withoutContacts copies a parser response without its contact fields, at
+// any depth.
+func withoutContacts(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = withoutContacts(item)
+		}
+		return items
+	case map[string]interface{}:
+		fields := map[string]interface{}{}
+		for key, field := range value {
+			if !contactKeys[strings.ToLower(key)] {
+				fields[key] = withoutContacts(field)
+			}
+		}
+		return fields
+	}
+	return v
+}
+
+// isPhoneNumber tells phone numbers from other runs of digits, such as the
+// year ranges of the experience, which matching reads.
+func isPhoneNumber(number string) bool {
+	groups := digitGroup.FindAllString(number, -1)
+	digits := 0
+	years := true
+	for _, group := range groups {
+		digits += len(group)
+		years = years && year.MatchString(group)
+	}
+	return digits >= 7 && digits <= 15 && !years
+}
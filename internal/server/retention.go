@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+)
+
+// runRetention applies the retention rules, see startJob.
+func (s *Server) runRetention(ctx context.Context) int {
+	purges, err := s.applyRetention(ctx, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "retention", "error", err)
+	}
+	if len(purges) > 0 {
+		slog.InfoContext(ctx, "retention: purged applicant data", "purges", len(purges))
+	}
+	return len(purges)
+}
+
+// retentionReport lists what the rules would purge at now, without purging.
+func (s *Server) retentionReport(now time.Time) (models.RetentionReport, error) {
+	report := models.RetentionReport{GeneratedAt: now, Rules: []string{}, Matches: []models.RetentionMatch{}}
+	for _, rule := range s.retentionRules {
+		report.Rules = append(report.Rules, rule.String())
+		matches, err := s.db.FindRetentionMatches(rule, now)
+		if err != nil {
+			return models.RetentionReport{}, err
+		}
+		report.Matches = append(report.Matches, matches...)
+	}
+	return report, nil
+}
+
+// applyRetention purges what the rules apply to at now and records every
+// purge. A failed purge is logged and the rest carries on; it is retried on
+// the next run, as is what is left when ctx ends.
+func (s *Server) applyRetention(ctx context.Context, now time.Time) ([]models.RetentionPurge, error) {
+	var purges []models.RetentionPurge
+	erased := map[int]bool{}
+	for _, rule := range s.retentionRules {
+		matches, err := s.db.FindRetentionMatches(rule, now)
+		if err != nil {
+			return purges, err
+		}
+		for _, match := range matches {
+			if ctx.Err() != nil {
+				return purges, nil
+			}
+			if erased[match.UserId] {
+				continue
+			}
+			purge, err := s.purge(rule, match, now)
+			if errors.Is(err, database.ErrUserNotFound) || errors.Is(err, database.ErrRetentionNoLongerApplies) {
+				continue
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "retention: purge failed", "rule", rule.String(), "user_id", match.UserId,
+					"error", err)
+				continue
+			}
+			if rule.Action == retention.Erase {
+				erased[match.UserId] = true
+			}
+			purges = append(purges, purge)
+		}
+	}
+	return purges, nil
+}
+
+func (s *Server) purge(rule retention.Rule, match models.RetentionMatch, now time.Time) (models.RetentionPurge, error) {
+	purge := models.RetentionPurge{Rule: rule.String(), Action: string(rule.Action), UserId: match.UserId,
+		LastActivity: match.LastActivity, PurgedAt: now}
+	// the match is checked again when purging, the files only go once it holds
+	removeFiles := func() error { return s.removeResumes(match.UserId) }
+	switch rule.Action {
+	case retention.DeleteResume:
+		var err error
+		purge.Removed, err = s.db.DeleteApplicantResume(match.UserId, rule, now, removeFiles)
+		if err != nil {
+			return models.RetentionPurge{}, err
+		}
+	case retention.Erase:
+		erasure, err := s.db.EraseApplicantByRule(match.UserId, models.Erasure{Reason: "retention rule " + rule.String(),
+			ErasedAt: now}, rule, now, removeFiles)
+		if err != nil {
+			return models.RetentionPurge{}, err
+		}
+		purge.ErasureId = erasure.Id
+		purge.Removed = erasure.Removed
+	default:
+		return models.RetentionPurge{}, fmt.Errorf("unknown retention action %q", rule.Action)
+	}
+
+	var err error
+	if purge.Id, err = s.db.RecordRetentionPurge(purge); err != nil {
+		return models.RetentionPurge{}, err
+	}
+	entry := models.AuditEntry{OccurredAt: now, Action: models.AuditRetentionPurged, TargetType: "user",
+		TargetId: strconv.Itoa(match.UserId), Details: map[string]string{"rule": purge.Rule}}
+	if err = s.db.AppendAuditEntry(entry); err != nil {
+		slog.Error("retention: recording the purge in the audit log", "user_id", match.UserId, "error", err)
+	}
+	return purge, nil
+}
+
+// AdminRetentionReportHandler is the dry run of the retention rules.
+func (s *Server) AdminRetentionReportHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	apiResp, err := s.retentionReport(time.Now().UTC())
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) AdminRetentionPurgesHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var apiResp models.RetentionPurgesResponse
+	apiResp.Total, err = s.dbFor(c).CountRetentionPurges()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Purges, err = s.dbFor(c).ListRetentionPurges(limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
@@ -1,104 +1,203 @@
 package server
 
 import (
+    "context"
+    "log/slog"
 	"net/http"
     "io"
-    "os"
     "bytes"
     "encoding/json"
     "fmt"
     "strconv"
+    "strings"
+    "sort"
 	"database/sql"
     "errors"
+    "time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+    "resume-backend-parser/internal/blind"
+    "resume-backend-parser/internal/database"
+    "resume-backend-parser/internal/logging"
+    "resume-backend-parser/internal/metrics"
     "resume-backend-parser/internal/models"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
 	e := echo.New()
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
+	e.IPExtractor = IPExtractor(s.trustedProxies)
+	e.Use(requestID())
+	e.Use(traceRequests)
+	e.Use(instrumentRequests)
+	e.Use(accessLog())
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{LogErrorFunc: logPanic}))
+	e.Use(s.rejectDeactivatedUsers)
+	e.Use(s.authenticateAPIKeys)
 
 	e.GET("/", s.HelloWorldHandler)
 
 	e.GET("/health", s.healthHandler)
+	e.GET("/livez", s.LivezHandler)
+	e.GET("/readyz", s.ReadyzHandler)
+	e.GET("/metrics", s.MetricsHandler)
 
 	e.POST("/signup", s.SignupHandler)
 	e.POST("/login", s.LoginHandler)
+	e.POST("/login/mfa", s.LoginMFAHandler)
+	e.GET("/auth/oidc/login", s.OIDCLoginHandler)
+	e.GET("/auth/oidc/callback", s.OIDCCallbackHandler)
+	e.POST("/verify-email", s.VerifyEmailHandler)
+	e.POST("/verify-email/resend", s.ResendVerificationHandler)
+	e.POST("/password/forgot", s.ForgotPasswordHandler)
+	e.POST("/password/reset", s.ResetPasswordHandler)
 	e.POST("/uploadResume", s.UploadResumeHandler)
 	e.POST("/admin/job", s.CreateJobOpeningHandler)
 	e.GET("/admin/job/:job_id", s.AdminGetJobOpeningHandler)
+	e.PUT("/admin/job/:job_id", s.AdminUpdateJobOpeningHandler)
 	e.GET("/admin/applicants", s.AdminGetApplicantsHandler)
+	e.GET("/admin/applicants/search", s.AdminSearchApplicantsHandler)
 	e.GET("/admin/applicant/:applicant_id", s.AdminGetApplicantHandler)
+	e.GET("/admin/applicant/:applicant_id/resume", s.AdminDownloadResumeHandler)
+	e.GET("/me", s.GetMeHandler)
+	e.PATCH("/me/profile", s.UpdateMyProfileHandler)
+	e.GET("/me/applications", s.GetMyApplicationsHandler)
+	e.GET("/me/export", s.ExportMyDataHandler)
+	e.POST("/me/erase", s.EraseMyAccountHandler)
+	e.GET("/me/mfa", s.GetMFAStatusHandler)
+	e.POST("/me/mfa/setup", s.SetupMFAHandler)
+	e.POST("/me/mfa/enable", s.EnableMFAHandler)
+	e.POST("/me/mfa/disable", s.DisableMFAHandler)
+	e.POST("/me/mfa/recovery-codes", s.RegenerateRecoveryCodesHandler)
 	e.GET("/jobs", s.GetJobOpeningsHandler)
+	e.GET("/jobs/search", s.SearchJobsHandler)
+	e.GET("/jobs/recommended", s.GetRecommendedJobsHandler)
+	e.GET("/jobs/:job_id/questions", s.GetScreeningQuestionsHandler)
+	e.PUT("/admin/job/:job_id/questions", s.AdminSetScreeningQuestionsHandler)
+	e.PUT("/admin/job/:job_id/blind-hiring", s.AdminSetBlindHiringHandler)
 	e.POST("/jobs/apply", s.ApplyJobHandler)
+	e.DELETE("/jobs/apply", s.WithdrawApplicationHandler)
+	e.PUT("/admin/job/:job_id/applicant/:applicant_id/stage", s.AdminUpdateApplicationStageHandler)
+	e.GET("/admin/users", s.AdminListUsersHandler)
+	e.POST("/admin/users/invite", s.AdminInviteAdminHandler)
+	e.PUT("/admin/users/:user_id/role", s.AdminUpdateUserRoleHandler)
+	e.POST("/admin/users/:user_id/deactivate", s.AdminDeactivateUserHandler)
+	e.POST("/admin/users/:user_id/reactivate", s.AdminReactivateUserHandler)
+	e.POST("/admin/users/:user_id/reset-password", s.AdminResetPasswordHandler)
+	e.POST("/admin/users/:user_id/unlock", s.AdminUnlockUserHandler)
+	e.POST("/admin/users/:user_id/erase", s.AdminEraseUserHandler)
+	e.PUT("/admin/users/:user_id/blind-reviewer", s.AdminSetBlindReviewerHandler)
+	e.GET("/admin/erasures", s.AdminListErasuresHandler)
+	e.GET("/admin/retention/report", s.AdminRetentionReportHandler)
+	e.GET("/admin/retention/purges", s.AdminRetentionPurgesHandler)
+	e.GET("/admin/api-keys", s.AdminListAPIKeysHandler)
+	e.POST("/admin/api-keys", s.AdminCreateAPIKeyHandler)
+	e.DELETE("/admin/api-keys/:key_id", s.AdminRevokeAPIKeyHandler)
+	e.GET("/admin/audit-log", s.AdminAuditLogHandler)
+	e.GET("/admin/audit-log/export", s.AdminExportAuditLogHandler)
 
 	return e
 }
 
-func readFileAsBytes(filename string) ([]byte, error) {
-    file, err := os.Open(filename)
-    if err != nil {
-        return nil, err
-    }
-    defer file.Close()
-
-    data, err := io.ReadAll(file)
-    if err != nil {
-        return nil, err
-    }
-    return data, nil
-}
+// names the parser in the metrics
+const parserProvider = "apilayer"
 
-func UploadResumeToThirdParty(userId int, resumePath string, s *Server) {
-    data, err := readFileAsBytes(resumePath)
+// UploadResumeToThirdParty parses the resume and fills in the profile. ctx
+// carries the request id, which is passed on to the parser as X-Request-ID,
+// and the trace, passed on as traceparent.
+func UploadResumeToThirdParty(ctx context.Context, userId int, resumePath string, s *Server) {
+    data, err := readResume(s.cipher, resumePath)
     if err != nil {
-        fmt.Println(err)
+        slog.ErrorContext(ctx, "reading the resume for the parser", "user_id", userId, "error", err)
         return
     }
 
-    var url = "https://api.apilayer.com/resume_parser/upload"
-    var api_key = os.Getenv("API_KEY")
-    req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+    var api_key = s.parserAPIKey
+    req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "POST", s.parserURL, bytes.NewReader(data))
     if err != nil {
-        fmt.Println(err)
+        slog.ErrorContext(ctx, "parsing the resume", "user_id", userId, "error", err)
         return
     }
 
 	req.Header.Set("Content-Type", "applicaiton/octet-stream")
     req.Header.Set("apikey", api_key)
-    client := &http.Client{}
-
-	resp, err := client.Do(req)
+    if id := logging.RequestID(ctx); id != "" {
+        req.Header.Set(echo.HeaderXRequestID, id)
+    }
+    start := time.Now()
+	resp, err := parserClient.Do(req)
+    metrics.ParserRequestDuration.WithLabelValues(parserProvider).Observe(time.Since(start).Seconds())
 	if err != nil {
-        fmt.Println(err)
+        metrics.ParserErrors.WithLabelValues(parserProvider, "request").Inc()
+        slog.ErrorContext(ctx, "parsing the resume", "user_id", userId, "error", err)
+        return
 	}
 	defer resp.Body.Close()
+    // on any failure the profile keeps what was parsed before
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        metrics.ParserErrors.WithLabelValues(parserProvider, "status").Inc()
+        slog.ErrorContext(ctx, "parsing the resume", "user_id", userId, "status", resp.StatusCode,
+            "duration", time.Since(start))
+        return
+    }
+    slog.InfoContext(ctx, "parsed the resume", "user_id", userId, "status", resp.StatusCode,
+        "duration", time.Since(start))
 
     body, err := io.ReadAll(resp.Body)
 	if err != nil {
-        fmt.Println(err)
+        metrics.ParserErrors.WithLabelValues(parserProvider, "request").Inc()
+        slog.ErrorContext(ctx, "reading the parser response", "user_id", userId, "error", err)
+        return
 	}
 
 	var respData map[string]interface{}
 
 	err = json.Unmarshal(body, &respData)
 	if err != nil {
-        fmt.Println(err)
+        metrics.ParserErrors.WithLabelValues(parserProvider, "decode").Inc()
+        slog.ErrorContext(ctx, "decoding the parser response", "user_id", userId, "error", err)
+        return
 	}
     var profile models.ProfileThirdParty
     err = json.Unmarshal(body, &profile)
     if err != nil {
-        fmt.Println(err)
+        metrics.ParserErrors.WithLabelValues(parserProvider, "decode").Inc()
+        slog.ErrorContext(ctx, "decoding the parsed profile", "user_id", userId, "error", err)
+        return
     }
-    fmt.Println(profile)
 
-    err = s.db.UpdateProfileWithFields(userId, profile)
+    err = s.db.WithContext(ctx).UpdateProfileWithFields(userId, profile, ResumeText(respData, profile))
     if err != nil {
-        fmt.Println(err)
+        slog.ErrorContext(ctx, "saving the parsed profile", "user_id", userId, "error", err)
+        return
+    }
+    s.recomputeApplicantScores(ctx, userId)
+}
+
+// collectStrings gathers every string value in the parser response, so fields
+// we don't model explicitly (summaries, certifications, ...) remain searchable.
+func collectStrings(v interface{}) []string {
+    var out []string
+    switch value := v.(type) {
+    case string:
+        if strings.TrimSpace(value) != "" {
+            out = append(out, value)
+        }
+    case []interface{}:
+        for _, item := range value {
+            out = append(out, collectStrings(item)...)
+        }
+    case map[string]interface{}:
+        keys := make([]string, 0, len(value))
+        for k := range value {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+            out = append(out, collectStrings(value[k])...)
+        }
     }
+    return out
 }
 
 
@@ -111,7 +210,14 @@ func (s *Server) HelloWorldHandler(c echo.Context) error {
 }
 
 func (s *Server) healthHandler(c echo.Context) error {
-	return c.JSON(http.StatusOK, s.db.Health())
+	if s.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+	}
+	stats := s.dbFor(c).Health()
+	if stats["status"] != "up" {
+		return c.JSON(http.StatusServiceUnavailable, stats)
+	}
+	return c.JSON(http.StatusOK, stats)
 }
 
 func (s *Server) SignupHandler(c echo.Context) error {
@@ -126,27 +232,40 @@ func (s *Server) SignupHandler(c echo.Context) error {
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
 
-    userExists, err := s.db.UserExists(apiReq.Email)
+    userExists, err := s.dbFor(c).UserExists(apiReq.Email)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     if userExists {
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "User already exists"})
     }
 
+    if len(apiReq.Password) > maxPasswordBytes {
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("password can be at most %d bytes", maxPasswordBytes)})
+    }
     password_encrypted, err := PassToHash(apiReq.Password)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
 
-    err = s.db.CreateUser(apiReq.Name, apiReq.Email, password_encrypted, apiReq.Address, apiReq.ProfileHeadline)
+    err = s.dbFor(c).CreateUser(apiReq.Name, apiReq.Email, password_encrypted, apiReq.Address, apiReq.ProfileHeadline)
 
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
+    metrics.Signups.WithLabelValues("password").Inc()
+
+    // a failed email doesn't fail the signup, the user can ask for a new one
+    id, err := s.dbFor(c).GetUserId(apiReq.Email)
+    if err == nil {
+        err = s.sendVerificationEmail(c.Request().Context(), id, apiReq.Email)
+    }
+    if err != nil {
+        logError(c, err)
+    }
     return c.JSON(http.StatusOK, map[string]string{"message": "User created successfully"})
 }
 
@@ -162,33 +281,47 @@ func (s *Server) LoginHandler(c echo.Context) error {
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
 
-    userExists, err := s.db.UserExists(apiReq.Email)
+    now := time.Now().UTC()
+    attempt := models.LoginAttempt{Email: apiReq.Email, IP: clientIP(c), AttemptedAt: now}
+    state, userExists, wait, err := s.loginWait(c, apiReq.Email, attempt.IP, now)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
-    if !userExists {
-        return c.JSON(http.StatusBadRequest, map[string]string{"error": "User does not exist"})
+    attempt.UserId = state.UserId
+    if wait > 0 {
+        attempt.Outcome = models.LoginBlocked
+        s.recordLoginAttempt(c, attempt)
+        return tooManyLoginAttempts(c, wait)
     }
 
-    password_encrypted, err := PassToHash(apiReq.Password)
+    login, err := s.checkPassword(c, apiReq.Email, apiReq.Password)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
-
-    login, err := s.db.CheckUserLogin(apiReq.Email, password_encrypted)
+    if !login {
+        attempt.Outcome = models.LoginFailed
+        s.recordLoginAttempt(c, attempt)
+        if userExists {
+            err = s.dbFor(c).RecordFailedLogin(state.UserId, now, s.lockout.LockoutThreshold, now.Add(s.lockout.LockoutDuration))
+            if err != nil {
+                logError(c, err)
+            }
+        }
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": invalidCredentials})
+    }
+    user, err := s.dbFor(c).GetUser(apiReq.Email)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
-    if !login {
-        return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid credentials"})
+    if !user.Active {
+        return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is deactivated"})
     }
-    var apiResp models.LoginResponse
-    apiResp.Token, err = CreateTokens(apiReq.Email)
+    apiResp, err := s.firstFactorPassed(c, user, attempt, state.FailedLogins)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     return c.JSON(http.StatusOK, apiResp)
@@ -200,102 +333,79 @@ func (s *Server) UploadResumeHandler(c echo.Context) error {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
 
-    user, err := DecodeAuthToken(token)
+    user, err := s.DecodeAuthToken(token)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    isAdmin, err := s.db.IsUserAdmin(user)
+    isAdmin, err := s.dbFor(c).IsUserAdmin(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     if isAdmin {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Only regular users can upload resumes"})
     }
-    id, err := s.db.GetUserId(user)
+    id, err := s.dbFor(c).GetUserId(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
 
     file, handler, err := c.Request().FormFile("resume")
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Error retrieving the resume."})
     }
     defer file.Close()
 
-    fmt.Printf("Uploaded File: %+v\n", handler.Filename)
-    fmt.Printf("File Size: %+v\n", handler.Size)
-    fmt.Printf("MIME Header: %+v\n", handler.Header)
+    // the file name often is the applicant's name, so it isn't logged
+    slog.DebugContext(c.Request().Context(), "resume uploaded", "user_id", id, "size", handler.Size,
+        "content_type", handler.Header.Get(echo.HeaderContentType))
 
-    current_dir, err := os.Getwd()
+    data, err := io.ReadAll(file)
     if err != nil {
-        fmt.Println(err)
-        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+        logError(c, err)
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": "Error retrieving the resume."})
     }
 
-    destination_folder := current_dir + "/resumes/" + strconv.Itoa(id) + "/"
-    // remove directory if it exists
-    err = os.RemoveAll(destination_folder)
+    // replaces the previous resume, encrypted when there is a cipher
+    resumePath, err := s.replaceResume(c.Request().Context(), id, handler.Filename, data)
     if err != nil {
-        fmt.Println("Error removing directory:", err)
+        logError(c, fmt.Errorf("saving the resume: %w", err))
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
-    err = os.Mkdir(destination_folder, 0755)
-    if err != nil {
-        fmt.Println("Error creating directory:", err)
-        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-    }
-    dst, err := os.Create(destination_folder + handler.Filename)
-    if err != nil {
-        fmt.Println("Error Creating the File")
-        fmt.Println(err)
-        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-    }
-    defer dst.Close()
 
-    _, err = io.Copy(dst, file)
+    err = s.dbFor(c).UpdateProfile(id, handler.Filename)
     if err != nil {
-        fmt.Println("Error Saving the File")
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
 
-    err = s.db.UpdateProfile(id, handler.Filename)
-    if err != nil {
-        fmt.Println(err)
-        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-    }
+    metrics.ResumeUploads.Inc()
 
-    UploadResumeToThirdParty(id, destination_folder + handler.Filename, s)
+    UploadResumeToThirdParty(c.Request().Context(), id, resumePath, s)
 
     return c.JSON(http.StatusOK, map[string]string{"message": "Resume uploaded successfully"})
 }
 
 func (s *Server) CreateJobOpeningHandler(c echo.Context) error {
-    token := c.Request().Header.Get("Authorization")
-    if token == "" {
-        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-    }
-    user, err := DecodeAuthToken(token)
+    user, err := s.callerEmail(c)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    isAdmin, err := s.db.IsUserAdmin(user)
+    isAdmin, err := s.dbFor(c).IsUserAdmin(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
-    fmt.Println(user)
     if !isAdmin {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    userId, err := s.db.GetUserId(user)
+    userId, err := s.dbFor(c).GetUserId(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
 
@@ -306,37 +416,46 @@ func (s *Server) CreateJobOpeningHandler(c echo.Context) error {
     var apiReq models.CreateJobRequest
     err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
     totalApplications, err := strconv.Atoi(apiReq.TotalApplications)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
 
-    err = s.db.CreateJob(apiReq.Title, apiReq.Description, apiReq.CompanyName, totalApplications, userId)
+    job := models.Job{
+        Title:             apiReq.Title,
+        Description:       apiReq.Description,
+        CompanyName:       apiReq.CompanyName,
+        TotalApplications: totalApplications,
+        JobRequirements:   apiReq.JobRequirements,
+    }
+    err = validateJob(&job)
     if err != nil {
-        fmt.Println(err)
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+    }
+
+    jobId, err := s.dbFor(c).CreateJob(job, userId)
+    if err != nil {
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
+    s.audit(c, models.AuditEntry{Action: models.AuditJobCreated, ActorId: userId, ActorEmail: user,
+        TargetType: "job", TargetId: strconv.Itoa(jobId), Details: map[string]string{"title": job.Title}})
     return c.JSON(http.StatusOK, map[string]string{"message": "Job created successfully"})
 }
 
 func (s *Server) AdminGetJobOpeningHandler(c echo.Context) error {
-    token := c.Request().Header.Get("Authorization")
-    if token == "" {
-        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-    }
-
-    user, err := DecodeAuthToken(token)
+    user, err := s.callerEmail(c)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    isAdmin, err := s.db.IsUserAdmin(user)
+    isAdmin, err := s.dbFor(c).IsUserAdmin(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     if !isAdmin {
@@ -349,38 +468,72 @@ func (s *Server) AdminGetJobOpeningHandler(c echo.Context) error {
     }
     jobId, err := strconv.Atoi(job_id)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
     var apiResp models.AdminGetJobResponse
-    apiResp.Job, err = s.db.GetJob(jobId)
+    apiResp.Job, err = s.dbFor(c).GetJob(jobId)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
     }
-    fmt.Println(apiResp)
-    apiResp.Applicants, err = s.db.GetApplicants(jobId)
+    apiResp.Applicants, err = s.dbFor(c).GetApplicants(jobId)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
     }
+    apiResp.Scores, err = s.jobScores(c.Request().Context(), apiResp.Job)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    apiResp.Questions, err = s.dbFor(c).GetScreeningQuestions(jobId)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    submissions, err := s.dbFor(c).GetJobSubmissions(jobId)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    apiResp.Submissions = map[string]models.ApplicationSubmission{}
+    for applicant, submission := range submissions {
+        apiResp.Submissions[strconv.Itoa(applicant)] = submission
+    }
+    reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    if apiResp.Job.BlindHiring.Enabled || reviewer {
+        err = s.redactJobView(c, user, &apiResp)
+        if err != nil {
+            logError(c, err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+        }
+    }
+    switch c.QueryParam("sort") {
+    case "":
+    case "score":
+        sortByScore(apiResp.Applicants, apiResp.Scores)
+    default:
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sort"})
+    }
+    s.audit(c, models.AuditEntry{Action: models.AuditProfilesListed, ActorEmail: user, TargetType: "job",
+        TargetId: strconv.Itoa(jobId), Details: map[string]string{"count": strconv.Itoa(len(apiResp.Applicants))}})
     return c.JSON(http.StatusOK, apiResp)
 }
 
 func (s *Server) AdminGetApplicantsHandler(c echo.Context) error {
-    token := c.Request().Header.Get("Authorization")
-    if token == "" {
-        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-    }
-
-    user, err := DecodeAuthToken(token)
+    user, err := s.callerEmail(c)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    isAdmin, err := s.db.IsUserAdmin(user)
+    isAdmin, err := s.dbFor(c).IsUserAdmin(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     if !isAdmin {
@@ -388,71 +541,81 @@ func (s *Server) AdminGetApplicantsHandler(c echo.Context) error {
     }
 
     var applicants models.ApplicantsResponse
-    applicants.Applicants, err = s.db.GetAllApplicants()
+    applicants.Applicants, err = s.dbFor(c).GetAllApplicants()
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    err = s.redactProfiles(c, user, reviewer, applicants.Applicants)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
+    s.audit(c, models.AuditEntry{Action: models.AuditProfilesListed, ActorEmail: user,
+        Details: map[string]string{"count": strconv.Itoa(len(applicants.Applicants))}})
     return c.JSON(http.StatusOK, applicants)
 }
 
 func (s *Server) AdminGetApplicantHandler(c echo.Context) error {
-    token := c.Request().Header.Get("Authorization")
-    if token == "" {
-        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-    }
-
-    user, err := DecodeAuthToken(token)
+    user, err := s.callerEmail(c)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    isAdmin, err := s.db.IsUserAdmin(user)
+    isAdmin, err := s.dbFor(c).IsUserAdmin(user)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
     if !isAdmin {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
 
-    applicant_id := c.Param("applicant_id")
-    if applicant_id == "" {
-        return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
-    }
-    applicantId, err := strconv.Atoi(applicant_id)
+    applicantId, err := s.applicantParam(c)
     if err != nil {
-        fmt.Println(err)
-        return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+        return applicantParamError(c, err)
     }
     var apiResp models.ApplicantResponse
-    apiResp.Applicant, err = s.db.GetApplicantProfile(applicantId)
+    apiResp.Applicant, err = s.dbFor(c).GetApplicantProfile(applicantId)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
     }
+    hidden, err := s.applicantHidden(c, user, applicantId)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    if hidden {
+        pseudonyms, err := s.dbFor(c).GetPseudonyms([]int{applicantId})
+        if err != nil {
+            logError(c, err)
+            return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+        }
+        apiResp.Applicant = blind.Redact(apiResp.Applicant, pseudonyms[applicantId])
+    }
+    s.audit(c, models.AuditEntry{Action: models.AuditProfileViewed, ActorEmail: user, TargetType: "user",
+        TargetId: strconv.Itoa(applicantId)})
     return c.JSON(http.StatusOK, apiResp)
 }
 
 func (s *Server) GetJobOpeningsHandler(c echo.Context) error {
-    token := c.Request().Header.Get("Authorization")
-    if token == "" {
-        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-    }
-
-    user, err := DecodeAuthToken(token)
+    _, err := s.callerEmail(c)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    fmt.Println("token verified")
-    fmt.Println(user)
 
     var apiResp models.GetJobsResponse
 
-    apiResp.Jobs, err = s.db.GetJobs()
+    apiResp.Jobs, err = s.dbFor(c).GetJobs()
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
 
@@ -465,15 +628,23 @@ func (s *Server) ApplyJobHandler(c echo.Context) error {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
 
-    user, err := DecodeAuthToken(token)
+    user, err := s.DecodeAuthToken(token)
     if err != nil {
-        fmt.Println(err)
+        logError(c, err)
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
     }
-    id, err := s.db.GetUserId(user)
+    id, err := s.dbFor(c).GetUserId(user)
     if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
     }
+    verified, err := s.dbFor(c).IsEmailVerified(id)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+    }
+    if !verified {
+        return c.JSON(http.StatusForbidden, map[string]string{"error": "Verify your email address before applying"})
+    }
 
     var job_id = c.QueryParam("job_id")
     if job_id == "" {
@@ -484,14 +655,49 @@ func (s *Server) ApplyJobHandler(c echo.Context) error {
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
     }
 
-    err = s.db.ApplyJob(jobId, id)
+    // the body with a cover letter and screening answers is optional
+    var apiReq models.ApplyJobRequest
+    defer c.Request().Body.Close()
+    body, _ := io.ReadAll(c.Request().Body)
+    if len(bytes.TrimSpace(body)) > 0 {
+        err = json.Unmarshal(body, &apiReq)
+        if err != nil {
+            return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+        }
+    }
+    questions, err := s.dbFor(c).GetScreeningQuestions(jobId)
+    if err != nil {
+        logError(c, err)
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error applying to job"})
+    }
+    submission, err := screenApplication(questions, apiReq)
+    if err != nil {
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+    }
+
+    err = s.dbFor(c).ApplyJob(jobId, id, submission)
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) {
             return c.JSON(http.StatusBadRequest, map[string]string{"error": "Job does not exist"})
         }
+        if errors.Is(err, database.ErrAlreadyApplied) {
+            return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+        }
+        logError(c, err)
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error applying to job"})
     }
+    metrics.Applications.Inc()
+    job, err := s.dbFor(c).GetJob(jobId)
+    if err == nil {
+        _, _, err = s.scoreApplication(c.Request().Context(), job, id)
+    }
+    if err != nil {
+        logError(c, err)
+    }
 
+    if submission.KnockedOut {
+        return c.JSON(http.StatusOK, map[string]string{"message": "Application submitted", "stage": string(models.StageRejected)})
+    }
 	return c.JSON(http.StatusOK, map[string]string{"message": "Successfully applied to job"})
 
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/screening"
+)
+
+// screenApplication checks an application against the job's screening
+// questions and reports whether it should be knocked out.
+func screenApplication(questions []models.ScreeningQuestion, apiReq models.ApplyJobRequest) (models.ApplicationSubmission, error) {
+	submission := models.ApplicationSubmission{CoverLetter: strings.TrimSpace(apiReq.CoverLetter)}
+	if utf8.RuneCountInString(submission.CoverLetter) > screening.MaxCoverLetterLength {
+		return submission, fmt.Errorf("coverLetter can be at most %d characters", screening.MaxCoverLetterLength)
+	}
+	var err error
+	submission.Answers, submission.KnockedOut, err = screening.Evaluate(questions, apiReq.Answers)
+	return submission, err
+}
+
+func (s *Server) GetScreeningQuestionsHandler(c echo.Context) error {
+	_, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	jobId, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	questions, err := s.dbFor(c).GetScreeningQuestions(jobId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, models.ScreeningQuestionsResponse{Questions: screening.Public(questions)})
+}
+
+func (s *Server) AdminSetScreeningQuestionsHandler(c echo.Context) error {
+	user, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	jobId, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.ScreeningQuestionsRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	err = screening.ValidateQuestions(apiReq.Questions)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	err = s.dbFor(c).SetScreeningQuestions(jobId, apiReq.Questions)
+	if err != nil {
+		if errors.Is(err, database.ErrJobNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditJobQuestionsUpdated, ActorEmail: user, TargetType: "job",
+		TargetId: strconv.Itoa(jobId), Details: map[string]string{"questions": strconv.Itoa(len(apiReq.Questions))}})
+	var apiResp models.ScreeningQuestionsResponse
+	apiResp.Questions, err = s.dbFor(c).GetScreeningQuestions(jobId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// paginationParams reads the optional limit and offset query parameters.
+func paginationParams(c echo.Context) (int, int, error) {
+	limit := defaultPageSize
+	offset := 0
+	if l := c.QueryParam("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 {
+			return 0, 0, errors.New("Invalid limit")
+		}
+		limit = min(v, maxPageSize)
+	}
+	if o := c.QueryParam("offset"); o != "" {
+		v, err := strconv.Atoi(o)
+		if err != nil || v < 0 {
+			return 0, 0, errors.New("Invalid offset")
+		}
+		offset = v
+	}
+	return limit, offset, nil
+}
+
+func (s *Server) SearchJobsHandler(c echo.Context) error {
+	_, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	q := c.QueryParam("q")
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	apiResp := models.JobSearchResponse{Query: q}
+	apiResp.Total, err = s.dbFor(c).CountJobSearchResults(q)
+	if err != nil {
+		if errors.Is(err, database.ErrEmptySearchQuery) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Results, err = s.dbFor(c).SearchJobs(q, limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) AdminSearchApplicantsHandler(c echo.Context) error {
+	user, err := s.callerEmail(c)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filters := models.CandidateSearchFilters{
+		Query:      c.QueryParam("q"),
+		Education:  c.QueryParam("education"),
+		Experience: c.QueryParam("experience"),
+		Name:       c.QueryParam("name"),
+		Email:      c.QueryParam("email"),
+		Phone:      c.QueryParam("phone"),
+	}
+	// skills may be repeated or given as a comma separated list
+	for _, skills := range c.QueryParams()["skills"] {
+		filters.Skills = append(filters.Skills, strings.Split(skills, ",")...)
+	}
+	reviewer, err := s.dbFor(c).IsBlindReviewer(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	// the full text includes the resume, so any of these could find out who
+	// a pseudonym is
+	identifying := filters.Query != "" || filters.Name != "" || filters.Email != "" || filters.Phone != "" ||
+		filters.Education != ""
+	if reviewer && identifying {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Blind reviewers can only search by skills and experience"})
+	}
+
+	apiResp := models.CandidateSearchResponse{Filters: filters}
+	apiResp.Total, err = s.dbFor(c).CountCandidateSearchResults(filters)
+	if err != nil {
+		if errors.Is(err, database.ErrEmptySearchQuery) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Results, err = s.dbFor(c).SearchCandidates(filters, limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	profiles := make([]models.Profile, len(apiResp.Results))
+	for i, result := range apiResp.Results {
+		profiles[i] = result.Profile
+	}
+	if err = s.redactProfiles(c, user, reviewer, profiles); err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	for i := range apiResp.Results {
+		// other admins only see the candidates of blind jobs redacted as
+		// well, so finding one by who they are would tell their pseudonym
+		if identifying && profiles[i].Redacted {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Candidates of blind jobs can only be searched by skills and experience"})
+		}
+		apiResp.Results[i].Profile = profiles[i]
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditProfilesListed, ActorEmail: user,
+		Details: map[string]string{"filters": SearchedFilters(filters), "count": strconv.Itoa(len(apiResp.Results)),
+			"total": strconv.Itoa(apiResp.Total)}})
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+// SearchedFilters names the filters a candidate search used, for the audit
+// log. The values stay out of it: they may be whom the admin looked for, and
+// the audit log can't be erased.
+func SearchedFilters(filters models.CandidateSearchFilters) string {
+	var used []string
+	for _, filter := range []struct{ name, value string }{
+		{"q", filters.Query}, {"education", filters.Education}, {"experience", filters.Experience},
+		{"name", filters.Name}, {"email", filters.Email}, {"phone", filters.Phone},
+	} {
+		if filter.value != "" {
+			used = append(used, filter.name)
+		}
+	}
+	if len(filters.Skills) > 0 {
+		used = append(used, "skills")
+	}
+	return strings.Join(used, ",")
+}
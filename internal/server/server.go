@@ -1,33 +1,115 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"strconv"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/joho/godotenv/autoload"
-
+	"resume-backend-parser/internal/config"
 	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/health"
+	"resume-backend-parser/internal/lockout"
+	"resume-backend-parser/internal/mailer"
+	"resume-backend-parser/internal/metrics"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
 )
 
 type Server struct {
 	port int
 
-	db database.Service
+	db     database.Service
+	mailer mailer.Mailer
+	// appURL is where the links in emails point to, e.g. the frontend
+	appURL  string
+	lockout lockout.Policy
+	// signs and verifies the tokens
+	keys *tokenKeys
+	// the key and the upload endpoint of the third-party resume parser
+	parserAPIKey string
+	parserURL    string
+	// where the resume files are stored, one directory per applicant
+	resumesDir string
+	// whether admins have to log in with a second factor
+	mfaRequiredForAdmins bool
+	// single sign-on, nil when not configured
+	sso *sso
+	// applied in the background, see runRetention
+	retentionRules []retention.Rule
+	// where blind hiring reveals candidates unless their job says otherwise
+	blindRevealStage models.ApplicationStage
+	// encrypts personal data and resume files, nil when not configured
+	cipher *encryption.Cipher
+	// held while resume files are replaced, removed or re-encrypted
+	resumesMu sync.Mutex
+
+	http *http.Server
+	// set once Shutdown started, /readyz reports the server as down then
+	shuttingDown  atomic.Bool
+	shutdownDelay time.Duration
+	// stops the background jobs, which are tracked by workers along with the
+	// emails sent after a response
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+	jobs        []*job
+	// the checks of /readyz
+	readiness *health.Checker
+	// bearer token of /metrics, open when empty
+	metricsToken string
+	// proxies whose X-Forwarded-For is trusted, see IPExtractor
+	trustedProxies []string
 }
 
-func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
+// NewServer sets up the server from a configuration that passed
+// config.Validate and starts its background jobs.
+func NewServer(cfg *config.Config) *Server {
+	keys, err := loadTokenKeys(cfg.Keys)
+	if err != nil {
+		panic(fmt.Sprintf("cannot load the token keys: %s", err))
+	}
+	resumesDir, err := filepath.Abs(cfg.Storage.ResumesDir)
+	if err != nil {
+		panic(fmt.Sprintf("invalid resumes directory: %s", err))
+	}
+	var cipher *encryption.Cipher
+	if cfg.Encryption.Keyfile != "" {
+		cipher, err = encryption.FromKeyfile(cfg.Encryption.Keyfile)
+		if err != nil {
+			panic(fmt.Sprintf("invalid encryption configuration: %s", err))
+		}
+	}
 	NewServer := &Server{
-		port: port,
+		port: cfg.Port,
+
+		db:      database.New(cfg.Database, cipher),
+		mailer:  mailer.New(cfg.SMTP),
+		appURL:  cfg.AppURL,
+		lockout: lockout.DefaultPolicy,
 
-		db: database.New(),
+		keys:                 keys,
+		parserAPIKey:         cfg.ParserAPIKey,
+		parserURL:            cfg.ParserURL,
+		resumesDir:           resumesDir,
+		mfaRequiredForAdmins: cfg.MFARequiredForAdmins,
+		sso:                  newSSO(cfg.OIDC, cfg.AppURL),
+		retentionRules:       cfg.Retention.Rules,
+		blindRevealStage:     cfg.BlindHiring.RevealStage,
+		cipher:               cipher,
+		shutdownDelay:        cfg.Shutdown.Delay,
+		metricsToken:         cfg.Metrics.Token,
+		trustedProxies:       cfg.TrustedProxies,
 	}
+	metrics.SetDBStats(NewServer.db.Stats)
 
 	// Declare Server config
-	server := &http.Server{
+	NewServer.http = &http.Server{
 		Addr:         fmt.Sprintf(":%d", NewServer.port),
 		Handler:      NewServer.RegisterRoutes(),
 		IdleTimeout:  time.Minute,
@@ -35,5 +117,63 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	ctx, stop := context.WithCancel(context.Background())
+	NewServer.stopWorkers = stop
+	if len(NewServer.retentionRules) > 0 {
+		NewServer.startJob(ctx, "retention", cfg.Retention.Interval, NewServer.runRetention)
+	}
+	if cipher != nil {
+		NewServer.startJob(ctx, "re-encryption", cfg.Encryption.ReencryptInterval, NewServer.runReencryption)
+	}
+	NewServer.readiness = NewServer.readinessChecks(cfg.Health)
+
+	return NewServer
+}
+
+// ListenAndServe serves the API on the configured port. After Shutdown it
+// returns http.ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	return s.http.ListenAndServe()
+}
+
+// Serve serves the API on l, see ListenAndServe.
+func (s *Server) Serve(l net.Listener) error {
+	return s.http.Serve(l)
+}
+
+// Shutdown stops the server in order: /readyz starts reporting it as down,
+// after the shutdown delay the listener closes and the requests in flight,
+// such as uploads, are waited for, then the background jobs and finally the
+// database pool. When ctx ends first the remaining connections are closed
+// and the jobs are given up on.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+	// the jobs don't start another run from here on
+	s.stopWorkers()
+
+	var errs []error
+	select {
+	case <-time.After(s.shutdownDelay):
+	case <-ctx.Done():
+	}
+	if err := s.http.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+		s.http.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for background jobs: %w", ctx.Err()))
+	}
+
+	if err := s.db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing the database: %w", err))
+	}
+	return errors.Join(errs...)
 }
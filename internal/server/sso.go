@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/metrics"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/oidc"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// sso is the single sign-on setup. The provider is discovered on the first
+// login, so the API starts even while the identity provider is unreachable.
+type sso struct {
+	config oidc.Config
+	// members of any of these groups are admins, everyone else gets
+	// defaultRole. With admin groups configured the role is synced on every
+	// login, so the identity provider stays the source of truth.
+	adminGroups []string
+	defaultRole models.UserType
+	// the frontend page the callback redirects to, with the token or the
+	// error in the fragment
+	finishURL string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// newSSO returns nil when SSO is off, without an issuer.
+func newSSO(cfg config.OIDC, appURL string) *sso {
+	if cfg.Issuer == "" {
+		return nil
+	}
+	return &sso{
+		config: oidc.Config{
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			GroupsClaim:  cfg.GroupsClaim,
+		},
+		adminGroups: cfg.AdminGroups,
+		defaultRole: cfg.DefaultRole,
+		finishURL:   appURL + "/sso/callback",
+	}
+}
+
+func (p *sso) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider == nil {
+		provider, err := oidc.Discover(ctx, p.config, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+// RoleForGroups maps the groups of an SSO user to their role.
+func RoleForGroups(groups []string, adminGroups []string, defaultRole models.UserType) models.UserType {
+	for _, group := range groups {
+		for _, adminGroup := range adminGroups {
+			if group == adminGroup {
+				return models.Admin
+			}
+		}
+	}
+	return defaultRole
+}
+
+func (s *Server) OIDCLoginHandler(c echo.Context) error {
+	if s.sso == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Single sign-on is not configured"})
+	}
+	provider, err := s.sso.getProvider(c.Request().Context())
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Identity provider is unavailable"})
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = oidc.RandomString(); err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+	stateToken, err := s.CreateOIDCStateToken(state, nonce, codeVerifier)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateToken,
+		Path:     "/auth/oidc",
+		MaxAge:   int(OIDCStateValidTime.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce, codeVerifier))
+}
+
+// ssoFinish sends the browser back to the frontend. The token or error go
+// into the fragment, which never reaches a server log.
+func (s *Server) ssoFinish(c echo.Context, key string, value string) error {
+	return s.ssoRedirect(c, url.Values{key: {value}})
+}
+
+func (s *Server) ssoRedirect(c echo.Context, fragment url.Values) error {
+	return c.Redirect(http.StatusFound, s.sso.finishURL+"#"+fragment.Encode())
+}
+
+func (s *Server) OIDCCallbackHandler(c echo.Context) error {
+	if s.sso == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Single sign-on is not configured"})
+	}
+	// the state is single-use
+	c.SetCookie(&http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	if errCode := c.QueryParam("error"); errCode != "" {
+		slog.WarnContext(c.Request().Context(), "oidc: sign-in failed at the identity provider", "oidc_error", errCode,
+			"description", c.QueryParam("error_description"))
+		return s.ssoFinish(c, "error", "Sign-in was cancelled or denied")
+	}
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil {
+		return s.ssoFinish(c, "error", "Sign-in expired, please try again")
+	}
+	flow, err := s.DecodeOIDCStateToken(cookie.Value)
+	if err != nil || c.QueryParam("state") != flow.ID {
+		return s.ssoFinish(c, "error", "Sign-in expired, please try again")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 15*time.Second)
+	defer cancel()
+	provider, err := s.sso.getProvider(ctx)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Identity provider is unavailable")
+	}
+	rawIDToken, err := provider.Exchange(ctx, c.QueryParam("code"), flow.CodeVerifier)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	identity, err := provider.VerifyIDToken(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+
+	userId, err := s.provisionSSOUser(c, identity)
+	if err != nil {
+		if errors.Is(err, database.ErrOIDCAccountConflict) {
+			return s.ssoFinish(c, "error", err.Error())
+		}
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	// the account may be linked under another email than the provider's
+	account, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	user, err := s.dbFor(c).GetUser(account.Email)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	if !user.Active {
+		return s.ssoFinish(c, "error", "Account is deactivated")
+	}
+
+	// the provider stands in for the password, the lockout and the second
+	// factor still apply
+	now := time.Now().UTC()
+	attempt := models.LoginAttempt{Email: user.Email, UserId: user.Id, IP: clientIP(c), AttemptedAt: now}
+	state, _, wait, err := s.loginWait(c, user.Email, attempt.IP, now)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	if wait > 0 {
+		attempt.Outcome = models.LoginBlocked
+		s.recordLoginAttempt(c, attempt)
+		return s.ssoFinish(c, "error", "Too many login attempts, try again later")
+	}
+	apiResp, err := s.firstFactorPassed(c, user, attempt, state.FailedLogins)
+	if err != nil {
+		logError(c, err)
+		return s.ssoFinish(c, "error", "Sign-in failed")
+	}
+	fragment := url.Values{}
+	if apiResp.Token != "" {
+		fragment.Set("token", apiResp.Token)
+	}
+	if apiResp.MFAToken != "" {
+		fragment.Set("mfaToken", apiResp.MFAToken)
+		fragment.Set("mfaRequired", strconv.FormatBool(apiResp.MFARequired))
+		fragment.Set("mfaEnrollmentRequired", strconv.FormatBool(apiResp.MFAEnrollmentRequired))
+	}
+	return s.ssoRedirect(c, fragment)
+}
+
+// provisionSSOUser finds or creates the account of an SSO user and applies
+// the role their groups map to.
+func (s *Server) provisionSSOUser(c echo.Context, identity oidc.Identity) (int, error) {
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	name, email, err := ValidateNewUser(name, identity.Email)
+	if err != nil {
+		return 0, err
+	}
+	// SSO users sign in at the provider, the password only fills the column
+	password, err := GeneratePassword()
+	if err != nil {
+		return 0, err
+	}
+	passwordHash, err := PassToHash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	role := RoleForGroups(identity.Groups, s.sso.adminGroups, s.sso.defaultRole)
+	userId, created, err := s.dbFor(c).ProvisionOIDCUser(models.OIDCIdentity{
+		Issuer:        identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         email,
+		EmailVerified: identity.EmailVerified,
+		Name:          name,
+	}, role, passwordHash)
+	if created {
+		metrics.Signups.WithLabelValues("sso").Inc()
+	}
+	if err != nil || created || len(s.sso.adminGroups) == 0 {
+		return userId, err
+	}
+
+	user, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		return 0, err
+	}
+	if user.UserType != role {
+		switch err = s.dbFor(c).SetUserType(userId, role); {
+		case errors.Is(err, database.ErrLastAdmin):
+			// keep the last admin rather than lock everyone out
+			slog.WarnContext(c.Request().Context(), "oidc: not demoting the last admin", "user_id", userId)
+		case err != nil:
+			return 0, err
+		default:
+			s.audit(c, models.AuditEntry{Action: models.AuditUserRoleChanged, TargetType: "user",
+				TargetId: strconv.Itoa(userId),
+				Details:  map[string]string{"from": string(user.UserType), "to": string(role), "source": "sso"}})
+		}
+	}
+	return userId, nil
+}
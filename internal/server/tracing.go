@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"resume-backend-parser/internal/tracing"
+)
+
+// parserClient calls the resume parser in a span of its own and passes the
+// trace context on to it.
+var parserClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport,
+	otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return "parser " + r.Method
+	}))}
+
+// traceRequests runs every request in a span named by its route, which
+// continues the trace of the caller when it sent a traceparent header. The
+// path isn't recorded, as it has ids in it.
+func traceRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracing.Tracer().Start(ctx, req.Method+" "+route(c), trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(req.Method), semconv.HTTPRoute(route(c)),
+				semconv.UserAgentOriginal(req.UserAgent())))
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		err := next(c)
+		code := status(c, err)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+		if code >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(code))
+		}
+		return err
+	}
+}
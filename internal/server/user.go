@@ -1,49 +1,113 @@
 package server
 
 import (
+    "crypto/rsa"
+    "crypto/subtle"
+    "fmt"
     jwt "github.com/golang-jwt/jwt/v5"
     "time"
     "os"
     "errors"
-    "log"
+    "log/slog"
     "encoding/json"
-)
-
-
-const privKeyPath =  "/keys/app.rsa"     // `$ openssl genrsa -out app.rsa 2048`
-const pubKeyPath  =  "/keys/app.rsa.pub" // `$ openssl rsa -in app.rsa -pubout > app.rsa.pub`
+    "sync"
 
-const AuthTokenValidTime = time.Minute * 15
+    "golang.org/x/crypto/bcrypt"
 
-const Issuer string = "ResumeParser"
+    "resume-backend-parser/internal/config"
+    "resume-backend-parser/internal/models"
+)
 
-func PassToHash(password string) (string, error) {
-    return password, nil
-}
 
-type ResumeClaims struct {
-    TokenType string `json:"tokenType"`
-    jwt.RegisteredClaims
+// tokenKeys sign and verify the tokens, see config.Keys.
+type tokenKeys struct {
+    sign   *rsa.PrivateKey // `$ openssl genrsa -out app.rsa 2048`
+    verify *rsa.PublicKey  // `$ openssl rsa -in app.rsa -pubout > app.rsa.pub`
 }
 
-func customParser(token string) (*jwt.Token, error) {
-    current_dir, err := os.Getwd()
+func loadTokenKeys(cfg config.Keys) (*tokenKeys, error) {
+    privBytes, err := os.ReadFile(cfg.JWTPrivateKey)
     if err != nil {
         return nil, err
     }
-    verifyBytes, err := os.ReadFile(current_dir + pubKeyPath)
+    privKey, err := jwt.ParseRSAPrivateKeyFromPEM(privBytes)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", cfg.JWTPrivateKey, err)
+    }
+    verifyBytes, err := os.ReadFile(cfg.JWTPublicKey)
     if err != nil {
         return nil, err
     }
     verifyKey, err := jwt.ParseRSAPublicKeyFromPEM(verifyBytes)
     if err != nil {
-        return nil, err
+        return nil, fmt.Errorf("%s: %w", cfg.JWTPublicKey, err)
+    }
+    return &tokenKeys{sign: privKey, verify: verifyKey}, nil
+}
+
+const AuthTokenValidTime = time.Minute * 15
+
+// MFA tokens stand in for the auth token between the password and the second
+// factor: pending tokens can only be exchanged for an auth token with a valid
+// code, enroll tokens only set up MFA when it is mandatory.
+const (
+    MFAPendingTokenType = "mfa_pending"
+    MFAEnrollTokenType  = "mfa_enroll"
+    MFATokenValidTime   = time.Minute * 5
+)
+
+// The state of an SSO login travels in a cookie between the redirect to the
+// identity provider and the callback.
+const (
+    OIDCStateTokenType = "oidc_state"
+    OIDCStateValidTime = time.Minute * 10
+)
+
+const Issuer string = "ResumeParser"
+
+// PassToHash hashes a password with bcrypt for storage, see CheckPassword.
+func PassToHash(password string) (string, error) {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    return string(hash), err
+}
+
+// CheckPassword reports whether password matches the stored hash. Passwords
+// stored before they were hashed still match, see LegacyPassword.
+func CheckPassword(passwordHash string, password string) bool {
+    if LegacyPassword(passwordHash) {
+        return subtle.ConstantTimeCompare([]byte(passwordHash), []byte(password)) == 1
+    }
+    return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+}
+
+// LegacyPassword reports whether a stored password is still in plaintext.
+// Those are hashed at the next login, or all at once with the admin command
+// hash-passwords.
+func LegacyPassword(passwordHash string) bool {
+    _, err := bcrypt.Cost([]byte(passwordHash))
+    return err != nil
+}
+
+// unknownAccountHash is checked against when the account doesn't exist, so
+// that the time a login takes doesn't tell.
+var unknownAccountHash = sync.OnceValue(func() string {
+    hash, err := PassToHash("no account has this password")
+    if err != nil {
+        panic(err)
     }
+    return hash
+})
+
+type ResumeClaims struct {
+    TokenType string `json:"tokenType"`
+    jwt.RegisteredClaims
+}
 
+func (s *Server) customParser(token string) (*jwt.Token, error) {
     parsedToken, err := jwt.Parse(
         token,
         func(token *jwt.Token) (interface{}, error) {
-            return verifyKey, nil
+            return s.keys.verify, nil
         },
     )
     if err != nil || !parsedToken.Valid{
@@ -52,8 +116,8 @@ func customParser(token string) (*jwt.Token, error) {
     return parsedToken, err
 }
 
-func getTokenExpirationTime(token string) (time.Time, error) {
-    parsedToken, err := customParser(token)
+func (s *Server) getTokenExpirationTime(token string) (time.Time, error) {
+    parsedToken, err := s.customParser(token)
     jsonString, err := json.Marshal(parsedToken.Claims)
     if err != nil {
         return time.Now().UTC(), err
@@ -68,12 +132,12 @@ func getTokenExpirationTime(token string) (time.Time, error) {
     return claims.ExpiresAt.UTC(), nil
 }
 
-func DecodeAuthToken(token string) (string, error) {
+func (s *Server) DecodeAuthToken(token string) (string, error) {
     if (len(token) < 8) {
         return "", errors.New("token length too small")
     }
     token = token[7:]
-    parsedToken, err := customParser(token)
+    parsedToken, err := s.customParser(token)
     if err != nil {
         return "", err
     }
@@ -99,7 +163,7 @@ func DecodeAuthToken(token string) (string, error) {
     return sub, nil
 }
 
-func CreateTokens(email string) (string, error) {
+func (s *Server) CreateTokens(email string) (string, error) {
     authClaims := ResumeClaims{
         "auth",
         jwt.RegisteredClaims{
@@ -113,32 +177,131 @@ func CreateTokens(email string) (string, error) {
         },
     }
 
-    current_dir, err := os.Getwd()
+    return s.signClaims(authClaims)
+}
+
+func (s *Server) signClaims(claims jwt.Claims) (string, error) {
+    authToken, err := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims).SignedString(s.keys.sign)
     if err != nil {
-        log.Println("error getting current dir", err)
+        slog.Error("signing a token", "error", err)
         return "", err
     }
 
-    privBytes, err := os.ReadFile(current_dir + privKeyPath)
+    return authToken, nil
+}
+
+// CreateActionToken signs the token of an emailed link. id refers to the
+// user_tokens row that makes the token single-use.
+func (s *Server) CreateActionToken(email string, purpose models.TokenPurpose, id string, expiresAt time.Time) (string, error) {
+    claims := ResumeClaims{
+        string(purpose),
+        jwt.RegisteredClaims{
+            Issuer:    Issuer,
+            IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+            ExpiresAt: jwt.NewNumericDate(expiresAt.UTC()),
+            NotBefore: jwt.NewNumericDate(time.Now().UTC()),
+            Subject:   email,
+            ID:        id,
+        },
+    }
+    return s.signClaims(claims)
+}
+
+func (s *Server) decodeClaims(token string, tokenType string) (ResumeClaims, error) {
+    parsedToken, err := s.customParser(token)
     if err != nil {
-        log.Println("error reading privKey", err)
-        return "", err
+        return ResumeClaims{}, err
     }
-    privKey, err := jwt.ParseRSAPrivateKeyFromPEM(privBytes)
+    jsonString, err := json.Marshal(parsedToken.Claims)
     if err != nil {
-        log.Println("error parsing privKey", err)
-        return "", err
+        return ResumeClaims{}, err
+    }
+    claims := ResumeClaims{}
+    if json.Unmarshal(jsonString, &claims) != nil {
+        return ResumeClaims{}, errors.New("Invalid token")
+    }
+    if claims.TokenType != tokenType {
+        return ResumeClaims{}, errors.New("Invalid token type")
     }
+    return claims, nil
+}
 
-    authToken, err := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), authClaims).SignedString(privKey)
+// DecodeActionToken checks the signature, expiry and purpose of an emailed
+// token and returns its id.
+func (s *Server) DecodeActionToken(token string, purpose models.TokenPurpose) (string, error) {
+    claims, err := s.decodeClaims(token, string(purpose))
     if err != nil {
-        log.Println("Error signing token:", err)
         return "", err
     }
+    if claims.ID == "" {
+        return "", errors.New("Invalid token")
+    }
+    return claims.ID, nil
+}
 
-    return authToken, nil
+func (s *Server) CreateMFAToken(email string, tokenType string) (string, error) {
+    claims := ResumeClaims{
+        tokenType,
+        jwt.RegisteredClaims{
+            Issuer:    Issuer,
+            IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+            ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(MFATokenValidTime)),
+            NotBefore: jwt.NewNumericDate(time.Now().UTC()),
+            Subject:   email,
+        },
+    }
+    return s.signClaims(claims)
 }
 
+// DecodeMFAToken returns the email of an MFA token of the given type.
+func (s *Server) DecodeMFAToken(token string, tokenType string) (string, error) {
+    claims, err := s.decodeClaims(token, tokenType)
+    if err != nil {
+        return "", err
+    }
+    return claims.Subject, nil
+}
 
+// OIDCStateClaims bind the callback of an SSO login to the browser that
+// started it. The state is the token id.
+type OIDCStateClaims struct {
+    TokenType    string `json:"tokenType"`
+    Nonce        string `json:"nonce"`
+    CodeVerifier string `json:"codeVerifier"`
+    jwt.RegisteredClaims
+}
 
+func (s *Server) CreateOIDCStateToken(state string, nonce string, codeVerifier string) (string, error) {
+    claims := OIDCStateClaims{
+        OIDCStateTokenType,
+        nonce,
+        codeVerifier,
+        jwt.RegisteredClaims{
+            Issuer:    Issuer,
+            IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+            ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(OIDCStateValidTime)),
+            NotBefore: jwt.NewNumericDate(time.Now().UTC()),
+            ID:        state,
+        },
+    }
+    return s.signClaims(claims)
+}
 
+func (s *Server) DecodeOIDCStateToken(token string) (OIDCStateClaims, error) {
+    parsedToken, err := s.customParser(token)
+    if err != nil {
+        return OIDCStateClaims{}, err
+    }
+    jsonString, err := json.Marshal(parsedToken.Claims)
+    if err != nil {
+        return OIDCStateClaims{}, err
+    }
+    claims := OIDCStateClaims{}
+    if json.Unmarshal(jsonString, &claims) != nil {
+        return OIDCStateClaims{}, errors.New("Invalid token")
+    }
+    if claims.TokenType != OIDCStateTokenType || claims.ID == "" {
+        return OIDCStateClaims{}, errors.New("Invalid token type")
+    }
+    return claims, nil
+}
@@ -0,0 +1,390 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt doesn't take longer passwords, see PassToHash
+	maxPasswordBytes = 72
+	maxEmailLength   = 50
+)
+
+// GeneratePassword returns a random password for accounts created or reset
+// by an admin.
+func GeneratePassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidatePassword checks a newly chosen password.
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password can be at most %d bytes", maxPasswordBytes)
+	}
+	return nil
+}
+
+// ValidateNewUser normalizes the name and email of an account created by an
+// admin.
+func ValidateNewUser(name string, email string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", "", fmt.Errorf("name is required and can be at most %d characters", maxNameLength)
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || len(address.Address) > maxEmailLength {
+		return "", "", fmt.Errorf("email must be a valid address of at most %d characters", maxEmailLength)
+	}
+	return name, address.Address, nil
+}
+
+// rejectDeactivatedUsers turns away requests carrying the token of a
+// deactivated account. Tokens stay valid for an hour, so refusing the login
+// alone is not enough. Invalid tokens are left to the handlers.
+func (s *Server) rejectDeactivatedUsers(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.Request().Header.Get("Authorization")
+		if token == "" {
+			return next(c)
+		}
+		email, err := s.DecodeAuthToken(token)
+		if err != nil {
+			return next(c)
+		}
+		active, err := s.dbFor(c).IsUserActive(email)
+		if err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		if !active {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Account is deactivated"})
+		}
+		return next(c)
+	}
+}
+
+// userChangeError maps the errors of the user management methods onto a
+// response.
+func userChangeError(c echo.Context, err error) error {
+	if errors.Is(err, database.ErrUserNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, database.ErrLastAdmin) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	logError(c, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+}
+
+func (s *Server) AdminListUsersHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	limit, offset, err := paginationParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filters := models.UserFilters{Query: c.QueryParam("q")}
+	switch userType := models.UserType(c.QueryParam("type")); userType {
+	case "", models.Admin, models.Applicant:
+		filters.UserType = userType
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "type must be Admin or Applicant"})
+	}
+	if a := c.QueryParam("active"); a != "" {
+		active, err := strconv.ParseBool(a)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "active must be true or false"})
+		}
+		filters.Active = &active
+	}
+
+	apiResp := models.UsersResponse{Filters: filters}
+	apiResp.Total, err = s.dbFor(c).CountUsers(filters)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	apiResp.Users, err = s.dbFor(c).ListUsers(filters, limit, offset)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) AdminInviteAdminHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var apiReq models.InviteAdminRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	name, email, err := ValidateNewUser(apiReq.Name, apiReq.Email)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	userExists, err := s.dbFor(c).UserExists(email)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if userExists {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "User already exists, change their role instead"})
+	}
+
+	password, err := GeneratePassword()
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	passwordHash, err := PassToHash(password)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	id, err := s.dbFor(c).CreateAdmin(name, email, passwordHash)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditUserInvited, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(id), Details: map[string]string{"email": email, "userType": string(models.Admin)}})
+
+	apiResp := models.TemporaryPasswordResponse{TemporaryPassword: password}
+	apiResp.User, err = s.dbFor(c).GetUserById(id)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusCreated, apiResp)
+}
+
+func (s *Server) AdminUpdateUserRoleHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	adminId, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiReq models.UpdateUserRoleRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil || (apiReq.UserType != models.Admin && apiReq.UserType != models.Applicant) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "userType must be Admin or Applicant"})
+	}
+	if userId == adminId && apiReq.UserType != models.Admin {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "You cannot remove your own admin role"})
+	}
+
+	before, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	err = s.dbFor(c).SetUserType(userId, apiReq.UserType)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditUserRoleChanged, ActorId: adminId, ActorEmail: user,
+		TargetType: "user", TargetId: strconv.Itoa(userId),
+		Details: map[string]string{"from": string(before.UserType), "to": string(apiReq.UserType)}})
+	apiResp, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) AdminDeactivateUserHandler(c echo.Context) error {
+	return s.setUserActive(c, false)
+}
+
+func (s *Server) AdminReactivateUserHandler(c echo.Context) error {
+	return s.setUserActive(c, true)
+}
+
+func (s *Server) setUserActive(c echo.Context, active bool) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	adminId, err := s.dbFor(c).GetUserId(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if userId == adminId && !active {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "You cannot deactivate your own account"})
+	}
+
+	err = s.dbFor(c).SetUserActive(userId, active)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	action := models.AuditUserDeactivated
+	if active {
+		action = models.AuditUserReactivated
+	}
+	s.audit(c, models.AuditEntry{Action: action, ActorId: adminId, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(userId)})
+	apiResp, err := s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
+
+func (s *Server) AdminResetPasswordHandler(c echo.Context) error {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	user, err := s.DecodeAuthToken(token)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	isAdmin, err := s.dbFor(c).IsUserAdmin(user)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if !isAdmin {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	// the body is optional, without a password a temporary one is generated
+	var apiReq models.ResetPasswordRequest
+	err = json.NewDecoder(c.Request().Body).Decode(&apiReq)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	var apiResp models.TemporaryPasswordResponse
+	password := apiReq.Password
+	if password == "" {
+		password, err = GeneratePassword()
+		if err != nil {
+			logError(c, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		apiResp.TemporaryPassword = password
+	} else if err = ValidatePassword(password); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	passwordHash, err := PassToHash(password)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	err = s.dbFor(c).SetUserPassword(userId, passwordHash)
+	if err != nil {
+		return userChangeError(c, err)
+	}
+	s.audit(c, models.AuditEntry{Action: models.AuditUserPasswordReset, ActorEmail: user, TargetType: "user",
+		TargetId: strconv.Itoa(userId)})
+	apiResp.User, err = s.dbFor(c).GetUserById(userId)
+	if err != nil {
+		logError(c, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return c.JSON(http.StatusOK, apiResp)
+}
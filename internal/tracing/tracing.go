@@ -0,0 +1,102 @@
+// Package tracing sets up OpenTelemetry tracing: spans are exported over
+// OTLP/HTTP or printed for local development, and the W3C trace context of
+// callers is continued and passed on to the resume parser.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/logging"
+)
+
+// the instrumentation scope of the spans
+const scope = "resume-backend-parser"
+
+// Setup installs the W3C trace context propagator and a tracer provider
+// exporting to where cfg says; the stdout exporter prints to w. Without an
+// exporter no spans are recorded, but the trace context of callers is still
+// passed on. shutdown exports the spans that are left.
+func Setup(ctx context.Context, cfg config.Tracing, w io.Writer) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case config.NoTraces:
+		return func(context.Context) error { return nil }, nil
+	case config.StdoutTraces:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case config.OTLPTraces:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		err = fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx, resource.WithFromEnv(), resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// callers that sampled a trace get their spans, whatever the ratio
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer is the tracer of the server's spans. It follows the provider
+// installed last, so it can be kept.
+func Tracer() trace.Tracer {
+	return otel.Tracer(scope)
+}
+
+// Start starts a span as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, as failed when err isn't nil. Like the log, the span
+// doesn't show emails in the error.
+func End(span trace.Span, err error) {
+	if err != nil {
+		msg := logging.Redact(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// Record adds a span for work timed by the caller, from start until now.
+// attrs are only worked out when the span is recorded.
+func Record(ctx context.Context, name string, start time.Time, err error, kind trace.SpanKind,
+	attrs func() []attribute.KeyValue) {
+	_, span := Tracer().Start(ctx, name, trace.WithTimestamp(start), trace.WithSpanKind(kind))
+	if span.IsRecording() {
+		span.SetAttributes(attrs()...)
+	}
+	End(span, err)
+}
@@ -5,14 +5,77 @@ CREATE TYPE user_type AS ENUM (
     'user'
 );
 
+CREATE TYPE education_level AS ENUM (
+    'none',
+    'high_school',
+    'associate',
+    'bachelor',
+    'master',
+    'doctorate'
+);
+
+CREATE TYPE remote_policy AS ENUM (
+    'onsite',
+    'hybrid',
+    'remote'
+);
+
+CREATE TYPE employment_type AS ENUM (
+    'full_time',
+    'part_time',
+    'contract',
+    'internship',
+    'temporary'
+);
+
+CREATE TYPE application_stage AS ENUM (
+    'applied',
+    'screening',
+    'interview',
+    'offer',
+    'hired',
+    'rejected',
+    'withdrawn'
+);
+
+CREATE TYPE question_type AS ENUM (
+    'free_text',
+    'yes_no',
+    'multiple_choice',
+    'numeric'
+);
+
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
     name VARCHAR(50) NOT NULL,
     email VARCHAR(50) NOT NULL,
-    address VARCHAR(200) NOT NULL,
+    -- encrypted when ENCRYPTION_KEYFILE is set, like the profile email and phone
+    address TEXT NOT NULL,
     type user_type NOT NULL,
     password_hash VARCHAR(200) NOT NULL,
     profile_headline VARCHAR(200) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    deactivated_at TIMESTAMP,
+    email_verified_at TIMESTAMP,
+    -- shown to admins; the login throttle counts login_attempts by email
+    failed_logins INT NOT NULL DEFAULT 0,
+    last_failed_login_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    -- failed login attempts before this no longer count, set by logging in
+    -- or an admin unlocking the account
+    failures_reset_at TIMESTAMP,
+    -- TOTP secret, set at enrollment and only in use once mfa_enabled
+    mfa_secret VARCHAR(64),
+    mfa_enabled BOOLEAN NOT NULL DEFAULT false,
+    -- time step of the last accepted code, so codes can't be replayed
+    mfa_last_counter BIGINT,
+    -- identity at the single sign-on provider, set on the first SSO login
+    oidc_issuer VARCHAR(200),
+    oidc_subject VARCHAR(255),
+    -- admins who only see candidates redacted until they reach the reveal stage
+    blind_reviewer BOOLEAN NOT NULL DEFAULT false,
+    -- stable id shown instead of the candidate in blind hiring views
+    pseudonym VARCHAR(16) NOT NULL DEFAULT ('cand-' || substr(md5(random()::text), 1, 10)),
     created_at TIMESTAMP,
     updated_at TIMESTAMP
 );
@@ -25,21 +88,224 @@ CREATE TABLE profile (
     education VARCHAR[],
     experience VARCHAR[],
     name VARCHAR(50),
-    email VARCHAR(50),
-    phone VARCHAR(50),
+    email TEXT,
+    phone TEXT,
+    -- blind indexes of the encrypted email and phone, for exact matches
+    email_bidx VARCHAR(64),
+    phone_bidx VARCHAR(64),
+    -- the parsed resume without contact details, see server.ResumeText
+    resume_text TEXT,
+    manual_fields VARCHAR[] NOT NULL DEFAULT '{}',
+    search_vector TSVECTOR,
     created_at TIMESTAMP,
     updated_at TIMESTAMP
 );
 
+CREATE INDEX profile_search_vector_idx ON profile USING GIN (search_vector);
+CREATE INDEX profile_email_bidx_idx ON profile (email_bidx);
+CREATE INDEX profile_phone_bidx_idx ON profile (phone_bidx);
+
 CREATE TABLE jobs (
     id SERIAL PRIMARY KEY,
     title VARCHAR(50) NOT NULL,
-    description VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL,
     posted_on TIMESTAMP NOT NULL,
     total_applications INT NOT NULL,
     applicants INTEGER[],
     company_name VARCHAR(50) NOT NULL,
-    posted_by INT REFERENCES users(id)
+    posted_by INT REFERENCES users(id),
+    required_skills VARCHAR[] NOT NULL DEFAULT '{}',
+    preferred_skills VARCHAR[] NOT NULL DEFAULT '{}',
+    min_experience_years INT NOT NULL DEFAULT 0,
+    education_level education_level,
+    location VARCHAR(100),
+    remote_policy remote_policy,
+    employment_type employment_type,
+    salary_min INT,
+    salary_max INT,
+    salary_currency CHAR(3),
+    -- candidates are redacted for admins until they reach the reveal stage,
+    -- the server's default when NULL
+    blind_hiring BOOLEAN NOT NULL DEFAULT false,
+    blind_reveal_stage application_stage,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED
+);
+
+CREATE INDEX jobs_search_vector_idx ON jobs USING GIN (search_vector);
+
+-- jobs.applicants holds the active applicants of a job; applications keeps
+-- every application ever made, including withdrawn ones.
+CREATE TABLE applications (
+    id SERIAL PRIMARY KEY,
+    job_id INT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    applicant INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stage application_stage NOT NULL DEFAULT 'applied',
+    applied_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    withdrawn_at TIMESTAMP,
+    cover_letter TEXT,
+    UNIQUE (job_id, applicant)
+);
+
+CREATE TABLE application_events (
+    id SERIAL PRIMARY KEY,
+    application_id INT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    from_stage application_stage,
+    to_stage application_stage NOT NULL,
+    changed_by INT REFERENCES users(id),
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX application_events_application_idx ON application_events (application_id);
+
+CREATE TABLE screening_questions (
+    id SERIAL PRIMARY KEY,
+    job_id INT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    prompt VARCHAR(500) NOT NULL,
+    type question_type NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT false,
+    options VARCHAR[] NOT NULL DEFAULT '{}',
+    knockout_answers VARCHAR[] NOT NULL DEFAULT '{}',
+    min_value DOUBLE PRECISION,
+    max_value DOUBLE PRECISION
+);
+
+CREATE INDEX screening_questions_job_idx ON screening_questions (job_id, position);
+
+-- answers keep a copy of the prompt so they still read correctly after the
+-- job's questions are replaced
+CREATE TABLE application_answers (
+    application_id INT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    question_id INT REFERENCES screening_questions(id) ON DELETE SET NULL,
+    prompt VARCHAR(500) NOT NULL,
+    answer TEXT NOT NULL,
+    knocked_out BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX application_answers_application_idx ON application_answers (application_id);
+
+CREATE TABLE match_scores (
+    job_id INT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    applicant INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score INT NOT NULL,
+    details JSONB NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (job_id, applicant)
+);
+
+-- Single-use tokens mailed for email verification and password resets. The
+-- emailed link carries a signed JWT whose id is the primary key here.
+CREATE TYPE token_purpose AS ENUM (
+    'verify_email',
+    'reset_password'
+);
+
+CREATE TABLE user_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose token_purpose NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX user_tokens_user_idx ON user_tokens (user_id, purpose);
+
+CREATE TYPE login_outcome AS ENUM (
+    'succeeded',
+    'failed',
+    'blocked'
+);
+
+-- Every login attempt, kept for throttling by IP and as an audit trail.
+-- Blocked attempts were turned away by the throttle without checking the
+-- password.
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(200) NOT NULL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    ip VARCHAR(64) NOT NULL,
+    outcome login_outcome NOT NULL,
+    attempted_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX login_attempts_ip_idx ON login_attempts (ip, attempted_at);
+CREATE INDEX login_attempts_user_idx ON login_attempts (user_id, attempted_at);
+CREATE INDEX login_attempts_email_idx ON login_attempts (lower(btrim(email)), attempted_at);
+
+CREATE TABLE mfa_recovery_codes (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+CREATE UNIQUE INDEX users_oidc_idx ON users (oidc_issuer, oidc_subject);
+CREATE UNIQUE INDEX users_pseudonym_idx ON users (pseudonym);
+
+CREATE TABLE api_keys (
+    -- public part of the key, the secret is only stored hashed
+    id VARCHAR(12) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes VARCHAR[] NOT NULL,
+    created_by INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP
+);
+
+-- append-only, see reject_audit_log_change. Actors and targets are kept as
+-- plain values so entries outlive the rows they refer to.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL DEFAULT now(),
+    action VARCHAR(50) NOT NULL,
+    actor_id INT,
+    actor_email TEXT,
+    api_key_id VARCHAR(12),
+    target_type VARCHAR(30),
+    target_id VARCHAR(50),
+    ip VARCHAR(45) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    details JSONB
+);
+
+CREATE INDEX audit_log_occurred_at_idx ON audit_log (occurred_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, occurred_at);
+CREATE INDEX audit_log_target_idx ON audit_log (target_type, target_id, occurred_at);
+
+-- tombstones of erased applicants. The users row is gone, so user_id has no
+-- foreign key; the email is only kept as a sha256 hash.
+CREATE TABLE erasures (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    email_hash VARCHAR(64) NOT NULL,
+    erased_by INT NOT NULL,
+    self_service BOOLEAN NOT NULL,
+    reason VARCHAR(500),
+    erased_at TIMESTAMP NOT NULL,
+    removed JSONB NOT NULL
+);
+
+CREATE INDEX erasures_email_hash_idx ON erasures (email_hash);
+
+-- everything the retention rules purged, see internal/retention
+CREATE TABLE retention_purges (
+    id SERIAL PRIMARY KEY,
+    rule VARCHAR(100) NOT NULL,
+    action VARCHAR(30) NOT NULL,
+    user_id INT NOT NULL,
+    last_activity TIMESTAMP NOT NULL,
+    purged_at TIMESTAMP NOT NULL,
+    erasure_id INT REFERENCES erasures(id),
+    removed JSONB NOT NULL
 );
 
 CREATE OR REPLACE FUNCTION set_created_at()
@@ -50,9 +316,14 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+-- Rows rewritten by re-encryption keep their updated_at, which retention
+-- takes as the last activity.
 CREATE OR REPLACE FUNCTION update_updated_at()
 RETURNS TRIGGER AS $$
 BEGIN
+    IF current_setting('app.reencrypting', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
     NEW.updated_at := CURRENT_TIMESTAMP;
     RETURN NEW;
 END;
@@ -78,5 +349,47 @@ BEFORE UPDATE ON profile
 FOR EACH ROW
 EXECUTE FUNCTION update_updated_at();
 
+-- array_to_string is not immutable, so the profile search vector is kept up to
+-- date by a trigger instead of a generated column. The email and phone are
+-- left out since they may be encrypted.
+CREATE OR REPLACE FUNCTION update_profile_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', coalesce(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(array_to_string(NEW.skills, ' '), '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(array_to_string(NEW.experience, ' '), '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(array_to_string(NEW.education, ' '), '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(NEW.resume_text, '')), 'D');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_profile_search_vector
+BEFORE INSERT OR UPDATE ON profile
+FOR EACH ROW
+EXECUTE FUNCTION update_profile_search_vector();
+
+-- The one change allowed is erasing an applicant (app.erasing, see
+-- EraseApplicant) replacing their email in actor_email with its hash.
+CREATE OR REPLACE FUNCTION reject_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('app.erasing', true) = 'on'
+        AND NEW.actor_email LIKE 'erased:%'
+        AND (NEW.id, NEW.occurred_at, NEW.action, NEW.actor_id, NEW.api_key_id, NEW.target_type, NEW.target_id,
+            NEW.ip, NEW.request_id, NEW.details)
+        IS NOT DISTINCT FROM (OLD.id, OLD.occurred_at, OLD.action, OLD.actor_id, OLD.api_key_id, OLD.target_type,
+            OLD.target_id, OLD.ip, OLD.request_id, OLD.details) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION reject_audit_log_change();
 
 COMMIT;
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"resume-backend-parser/internal/apikey"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyGenerateAndParse(t *testing.T) {
+	key, id, hash, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, "rpk_"+id+"_") {
+		t.Errorf("key %q does not start with its id %q", key, id)
+	}
+	if strings.Contains(hash, strings.TrimPrefix(key, "rpk_"+id+"_")) {
+		t.Error("hash contains the secret")
+	}
+
+	parsedId, secret, ok := apikey.Parse(key)
+	if !ok || parsedId != id {
+		t.Fatalf("Parse() = %q, %v, expected id %q", parsedId, ok, id)
+	}
+	if !apikey.Matches(secret, hash) {
+		t.Error("Matches() rejected the secret of the key")
+	}
+	if apikey.Matches(secret+"x", hash) {
+		t.Error("Matches() accepted another secret")
+	}
+
+	other, _, _, _ := apikey.Generate()
+	if other == key {
+		t.Error("Generate() returned the same key twice")
+	}
+}
+
+func TestAPIKeyParseRejectsMalformedKeys(t *testing.T) {
+	for _, key := range []string{"", "Bearer abc", "rpk_", "rpk_0123456789ab", "rpk_0123456789ab_", "rpk_short_secret", "xpk_0123456789ab_secret"} {
+		if _, _, ok := apikey.Parse(key); ok {
+			t.Errorf("Parse(%q) accepted a malformed key", key)
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	for _, scope := range []string{"jobs:read", "jobs:write", "applications:read", "applications:write"} {
+		if !apikey.ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = false", scope)
+		}
+	}
+	for _, scope := range []string{"", "admin", "jobs:*", "users:write"} {
+		if apikey.ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = true", scope)
+		}
+	}
+}
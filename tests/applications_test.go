@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+)
+
+func TestApplicationStagesAndWithdrawal(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	job := createTestJob(t, s, admin, "Engineer")
+	if err := s.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyJob(job, applicant, models.ApplicationSubmission{}); !errors.Is(err, database.ErrAlreadyApplied) {
+		t.Errorf("applying twice: %v", err)
+	}
+	if err := s.UpdateApplicationStage(job, applicant, models.StageInterview, admin); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WithdrawApplication(job, applicant); err != nil {
+		t.Fatal(err)
+	}
+
+	applications, err := s.GetApplications(applicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applications) != 1 {
+		t.Fatalf("%d applications", len(applications))
+	}
+	application := applications[0]
+	if application.Job.Id != job || application.Stage != models.StageWithdrawn || application.WithdrawnAt == nil {
+		t.Errorf("application = %+v", application)
+	}
+	var stages []models.ApplicationStage
+	for _, event := range application.History {
+		stages = append(stages, event.ToStage)
+	}
+	if len(stages) != 3 || stages[0] != models.StageApplied || stages[1] != models.StageInterview ||
+		stages[2] != models.StageWithdrawn {
+		t.Errorf("history = %v", stages)
+	}
+	if jobDetails, err := s.GetJob(job); err != nil || len(jobDetails.Applicants) != 0 {
+		t.Errorf("the withdrawn applicant is still on the job: %v, %v", jobDetails.Applicants, err)
+	}
+
+	// a withdrawn application can't be withdrawn again or moved on by admins
+	if err = s.WithdrawApplication(job, applicant); !errors.Is(err, database.ErrApplicationNotFound) {
+		t.Errorf("withdrawing twice: %v", err)
+	}
+	if err = s.UpdateApplicationStage(job, applicant, models.StageOffer, admin); !errors.Is(err, database.ErrApplicationClosed) {
+		t.Errorf("moving a withdrawn application: %v", err)
+	}
+
+	// applying again reopens it
+	if err = s.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+		t.Fatal(err)
+	}
+	if applications, err = s.GetApplications(applicant); err != nil || applications[0].Stage != models.StageApplied ||
+		applications[0].WithdrawnAt != nil {
+		t.Errorf("reopened application = %+v, %v", applications, err)
+	}
+}
+
+func TestClosedApplicationsCantBeWithdrawn(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	for _, stage := range []models.ApplicationStage{models.StageHired, models.StageRejected} {
+		job := createTestJob(t, s, admin, string(stage))
+		if err := s.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdateApplicationStage(job, applicant, stage, admin); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.WithdrawApplication(job, applicant); !errors.Is(err, database.ErrApplicationClosed) {
+			t.Errorf("withdrawing a %s application: %v", stage, err)
+		}
+	}
+	if err := s.WithdrawApplication(createTestJob(t, s, admin, "Not applied"), applicant); !errors.Is(err,
+		database.ErrApplicationNotFound) {
+		t.Errorf("withdrawing without applying: %v", err)
+	}
+}
+
+func TestApplicantsDontSeeWhoMovedTheirApplication(t *testing.T) {
+	db := testDatabase(t, nil)
+	s, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	admin := createTestUser(t, db, "admin@example.com")
+	applicant := createTestUser(t, db, "jane@example.com")
+	job := createTestJob(t, db, admin, "Engineer")
+	if err := db.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateApplicationStage(job, applicant, models.StageInterview, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.CreateTokens("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/me/applications", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var apiResp struct {
+		Applications []struct {
+			History []map[string]interface{} `json:"history"`
+		} `json:"applications"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(apiResp.Applications) != 1 || len(apiResp.Applications[0].History) != 2 {
+		t.Fatalf("applications = %+v", apiResp.Applications)
+	}
+	for _, event := range apiResp.Applications[0].History {
+		if _, ok := event["changedBy"]; ok {
+			t.Errorf("the applicant sees who moved the application: %v", event)
+		}
+	}
+
+	// the admin views keep it
+	applications, err := db.GetApplications(applicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if by := applications[0].History[1].ChangedBy; by == nil || *by != admin {
+		t.Errorf("changed by = %v, expected %d", by, admin)
+	}
+}
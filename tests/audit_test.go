@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"resume-backend-parser/internal/models"
+)
+
+func TestAuditLogFiltersAndExport(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []models.AuditEntry{
+		{Action: models.AuditLoginSucceeded, ActorEmail: "admin@example.com", TargetType: "user",
+			IP: "192.0.2.1", RequestId: "req-1"},
+		{Action: models.AuditProfileViewed, ActorId: admin, TargetType: "user", IP: "192.0.2.1", RequestId: "req-2"},
+		{Action: models.AuditLoginFailed, ActorEmail: "nobody@example.com", IP: "2001:db8::1", RequestId: "req-3"},
+		{Action: models.AuditUserRoleChanged, ActorId: admin, TargetType: "user", IP: "192.0.2.2", RequestId: "req-4",
+			Details: map[string]string{"from": "Applicant", "to": "Admin"}},
+	}
+	// the login is the admin's own, the profile view and the role change are
+	// of the applicant
+	entries[1].TargetId = strconv.Itoa(applicant)
+	entries[3].TargetId = strconv.Itoa(applicant)
+	entries[0].TargetId = strconv.Itoa(admin)
+	for i, entry := range entries {
+		entry.OccurredAt = start.Add(time.Duration(i) * time.Hour)
+		if err := s.AppendAuditEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from, to := start.Add(time.Hour), start.Add(3*time.Hour)
+	tests := []struct {
+		name     string
+		filters  models.AuditFilters
+		expected []string
+	}{
+		{"all", models.AuditFilters{}, []string{"req-4", "req-3", "req-2", "req-1"}},
+		{"action", models.AuditFilters{Action: models.AuditLoginFailed}, []string{"req-3"}},
+		// the actor id of the login was looked up by email
+		{"actor id", models.AuditFilters{ActorId: admin}, []string{"req-4", "req-2", "req-1"}},
+		{"actor email ignores case", models.AuditFilters{ActorEmail: "Admin@Example.com"}, []string{"req-1"}},
+		{"user", models.AuditFilters{UserId: applicant}, []string{"req-4", "req-2"}},
+		{"target", models.AuditFilters{TargetType: "user", TargetId: strconv.Itoa(applicant)}, []string{"req-4", "req-2"}},
+		{"ip", models.AuditFilters{IP: "192.0.2.1"}, []string{"req-2", "req-1"}},
+		{"request id", models.AuditFilters{RequestId: "req-3"}, []string{"req-3"}},
+		{"time range", models.AuditFilters{From: &from, To: &to}, []string{"req-3", "req-2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list, err := s.ListAuditEntries(test.filters, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := requestIds(list); strings.Join(got, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("entries = %v, expected %v", got, test.expected)
+			}
+			total, err := s.CountAuditEntries(test.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != len(test.expected) {
+				t.Errorf("count = %d, expected %d", total, len(test.expected))
+			}
+		})
+	}
+
+	page, err := s.ListAuditEntries(models.AuditFilters{}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(requestIds(page), ","); got != "req-3,req-2" {
+		t.Errorf("second page = %s", got)
+	}
+
+	// the export is oldest first and keeps the details
+	var exported []models.AuditEntry
+	err = s.ExportAuditEntries(models.AuditFilters{ActorId: admin}, func(entry models.AuditEntry) error {
+		exported = append(exported, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(requestIds(exported), ","); got != "req-1,req-2,req-4" {
+		t.Fatalf("exported %s", got)
+	}
+	if last := exported[2]; last.Details["to"] != "Admin" || last.IP != "192.0.2.2" || !last.OccurredAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("exported entry = %+v", last)
+	}
+}
+
+func TestAuditLogStoresTheParsedClientIP(t *testing.T) {
+	s := testDatabase(t, nil)
+	_, addr, _ := newTestServer(t, testDatabaseArgs()...)
+
+	// forged headers longer than the ip column mustn't cost the audit entry
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/login",
+		strings.NewReader(`{"email": "nobody@example.com", "password": "wrong"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", strings.Repeat("x", 100)+", 203.0.113.9")
+	req.Header.Set("X-Real-IP", strings.Repeat("1", 100))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	entries, err := s.ListAuditEntries(models.AuditFilters{Action: models.AuditLoginFailed}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].IP != "127.0.0.1" {
+		t.Errorf("entries = %+v, expected one from 127.0.0.1", entries)
+	}
+}
+
+func requestIds(entries []models.AuditEntry) []string {
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.RequestId)
+	}
+	return ids
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"resume-backend-parser/internal/blind"
+	"resume-backend-parser/internal/models"
+	"strconv"
+	"testing"
+)
+
+func TestBlindRedact(t *testing.T) {
+	profile := models.Profile{
+		Applicant:         "12",
+		ResumeFileAddress: "jane_doe_cv.pdf",
+		Skills:            "go, sql",
+		Education:         "MIT, Stanford University",
+		Experience:        "Backend developer",
+		Name:              "Jane Doe",
+		Email:             "jane@example.com",
+		Phone:             "+1 555 0100",
+	}
+	redacted := blind.Redact(profile, "cand-0123456789")
+	if redacted.Applicant != "cand-0123456789" || redacted.Pseudonym != "cand-0123456789" || !redacted.Redacted {
+		t.Errorf("Redact() did not put the pseudonym in place: %+v", redacted)
+	}
+	if redacted.Name != "" || redacted.Email != "" || redacted.Phone != "" || redacted.Education != "" || redacted.ResumeFileAddress != "" {
+		t.Errorf("Redact() left identifying fields: %+v", redacted)
+	}
+	if redacted.Skills != profile.Skills || redacted.Experience != profile.Experience {
+		t.Errorf("Redact() removed skills or experience: %+v", redacted)
+	}
+}
+
+func TestBlindReached(t *testing.T) {
+	tests := []struct {
+		stages   []models.ApplicationStage
+		reveal   models.ApplicationStage
+		expected bool
+	}{
+		{[]models.ApplicationStage{models.StageApplied}, models.StageInterview, false},
+		{[]models.ApplicationStage{models.StageScreening, models.StageApplied}, models.StageInterview, false},
+		{[]models.ApplicationStage{models.StageInterview, models.StageApplied}, models.StageInterview, true},
+		{[]models.ApplicationStage{models.StageOffer}, models.StageInterview, true},
+		// once revealed, a rejection doesn't hide the candidate again
+		{[]models.ApplicationStage{models.StageRejected, models.StageInterview}, models.StageInterview, true},
+		{[]models.ApplicationStage{models.StageRejected, models.StageWithdrawn}, models.StageScreening, false},
+		{nil, models.StageScreening, false},
+	}
+	for _, test := range tests {
+		if actual := blind.Reached(test.stages, test.reveal); actual != test.expected {
+			t.Errorf("Reached(%v, %s) = %v, expected %v", test.stages, test.reveal, actual, test.expected)
+		}
+	}
+}
+
+func TestBlindStagesIncludeHistory(t *testing.T) {
+	application := models.Application{Stage: models.StageRejected, History: []models.ApplicationEvent{
+		{ToStage: models.StageApplied}, {ToStage: models.StageOffer}, {ToStage: models.StageRejected}}}
+	if !blind.Reached(blind.Stages(application), models.StageOffer) {
+		t.Error("an application rejected after an offer was not revealed at offer")
+	}
+}
+
+func TestBlindValidRevealStage(t *testing.T) {
+	for _, stage := range []models.ApplicationStage{models.StageScreening, models.StageInterview, models.StageOffer, models.StageHired} {
+		if !blind.ValidRevealStage(stage) {
+			t.Errorf("ValidRevealStage(%s) = false", stage)
+		}
+	}
+	for _, stage := range []models.ApplicationStage{"", models.StageApplied, models.StageRejected, models.StageWithdrawn, "unknown"} {
+		if blind.ValidRevealStage(stage) {
+			t.Errorf("ValidRevealStage(%q) = true", stage)
+		}
+	}
+	if !blind.IsPseudonym("cand-0123456789") || blind.IsPseudonym("12") {
+		t.Error("IsPseudonym() does not tell pseudonyms from ids")
+	}
+}
+
+func TestBlindRedactSubmission(t *testing.T) {
+	submission := models.ApplicationSubmission{Stage: models.StageApplied, CoverLetter: "I'm Jane Doe from Springfield",
+		Answers: []models.ScreeningAnswer{{QuestionId: 1, Answer: "5"}}}
+	redacted := blind.RedactSubmission(submission)
+	if redacted.CoverLetter != "" {
+		t.Errorf("RedactSubmission() kept the cover letter: %q", redacted.CoverLetter)
+	}
+	if redacted.Stage != submission.Stage || len(redacted.Answers) != 1 {
+		t.Errorf("RedactSubmission() removed more than the cover letter: %+v", redacted)
+	}
+}
+
+func TestApplicantListRedactsBlindCandidatesForEveryAdmin(t *testing.T) {
+	db := testDatabase(t, nil)
+	s, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	admin, err := db.CreateAdmin("Admin", "admin@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindJob := createTestJob(t, db, admin, "Blind")
+	openJob := createTestJob(t, db, admin, "Open")
+	if err = db.SetJobBlindHiring(blindJob, models.BlindHiring{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	jane := createTestUser(t, db, "jane@example.com")
+	john := createTestUser(t, db, "john@example.com")
+	for applicant, job := range map[int]int{jane: blindJob, john: openJob} {
+		if err = db.UpdateProfile(applicant, "cv.pdf"); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := s.CreateTokens("admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/admin/applicants", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var apiResp models.ApplicantsResponse
+	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(apiResp.Applicants) != 2 {
+		t.Fatalf("applicants = %+v", apiResp.Applicants)
+	}
+	for _, profile := range apiResp.Applicants {
+		switch {
+		case profile.Applicant == strconv.Itoa(john):
+			if profile.Redacted {
+				t.Errorf("the candidate of the open job is redacted: %+v", profile)
+			}
+		case !profile.Redacted || !blind.IsPseudonym(profile.Applicant):
+			t.Errorf("the candidate of the blind job isn't redacted: %+v", profile)
+		}
+	}
+}
+
+func TestCandidateSearchRefusesToFindBlindCandidatesByIdentity(t *testing.T) {
+	db := testDatabase(t, nil)
+	s, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	admin, err := db.CreateAdmin("Admin", "admin@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindJob := createTestJob(t, db, admin, "Blind")
+	openJob := createTestJob(t, db, admin, "Open")
+	if err = db.SetJobBlindHiring(blindJob, models.BlindHiring{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	var john int
+	for _, candidate := range []struct {
+		email string
+		job   int
+	}{{"jane@example.com", blindJob}, {"john@example.com", openJob}} {
+		applicant := createTestUser(t, db, candidate.email)
+		parsed := models.ProfileThirdParty{Name: "Candidate", Email: candidate.email, Skills: []string{"golang"}}
+		if err = db.UpdateProfileWithFields(applicant, parsed, "Candidate golang"); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.ApplyJob(candidate.job, applicant, models.ApplicationSubmission{}); err != nil {
+			t.Fatal(err)
+		}
+		if candidate.job == openJob {
+			john = applicant
+		}
+	}
+
+	token, err := s.CreateTokens("admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func(query string) (int, models.CandidateSearchResponse) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/admin/applicants/search?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var apiResp models.CandidateSearchResponse
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, apiResp
+	}
+
+	for _, query := range []string{"email=jane@example.com", "name=Candidate", "q=golang"} {
+		if status, apiResp := search(query); status != http.StatusForbidden {
+			t.Errorf("%s = %d %+v, expected the blind candidate not to be found", query, status, apiResp.Results)
+		}
+	}
+	// the candidate of the open job can be found by who they are
+	if status, apiResp := search("email=john@example.com"); status != http.StatusOK || len(apiResp.Results) != 1 ||
+		apiResp.Results[0].Profile.Redacted {
+		t.Errorf("searching the open job's candidate = %d %+v", status, apiResp.Results)
+	}
+	// and either by skills, the blind one redacted
+	status, apiResp := search("skills=golang")
+	if status != http.StatusOK || len(apiResp.Results) != 2 {
+		t.Fatalf("searching by skills = %d %+v", status, apiResp.Results)
+	}
+	for _, result := range apiResp.Results {
+		if result.Profile.Redacted != blind.IsPseudonym(result.Profile.Applicant) ||
+			result.Profile.Redacted == (result.Profile.Applicant == strconv.Itoa(john)) {
+			t.Errorf("result = %+v", result.Profile)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"resume-backend-parser/internal/server"
+)
+
+func realIP(extractor echo.IPExtractor, remoteAddr string, forwardedFor string) string {
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+	}
+	req.Header.Set(echo.HeaderXRealIP, "203.0.113.99")
+	return extractor(req)
+}
+
+func TestIPExtractor(t *testing.T) {
+	// without trusted proxies the headers are ignored, even from private peers
+	direct := server.IPExtractor(nil)
+	if ip := realIP(direct, "10.0.0.5:4711", "198.51.100.7"); ip != "10.0.0.5" {
+		t.Errorf("without trusted proxies: %q", ip)
+	}
+
+	behindProxy := server.IPExtractor([]string{"10.0.0.0/24", "192.0.2.1"})
+	for _, test := range []struct {
+		remoteAddr, forwardedFor, expected string
+	}{
+		{"10.0.0.5:4711", "198.51.100.7", "198.51.100.7"},
+		{"192.0.2.1:4711", "198.51.100.7", "198.51.100.7"},
+		// the client may prepend anything, only what the proxies added counts
+		{"10.0.0.5:4711", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"10.0.0.5:4711", "198.51.100.7, 10.0.0.6", "198.51.100.7"},
+		// clients that aren't proxies can't forge it
+		{"198.51.100.7:4711", "1.2.3.4", "198.51.100.7"},
+		{"10.0.1.5:4711", "1.2.3.4", "10.0.1.5"},
+		// nor make it longer than an IP
+		{"10.0.0.5:4711", strings.Repeat("1", 100), "10.0.0.5"},
+	} {
+		if ip := realIP(behindProxy, test.remoteAddr, test.forwardedFor); ip != test.expected {
+			t.Errorf("from %s with X-Forwarded-For %q: %q, expected %q", test.remoteAddr, test.forwardedFor, ip,
+				test.expected)
+		}
+	}
+}
+
+func TestTrustedProxiesConfig(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := loadConfig(t, "-trusted_proxies", "10.0.0.0/8, ::1")
+	if err != nil || len(cfg.TrustedProxies) != 2 {
+		t.Errorf("trusted proxies = %v, %v", cfg.TrustedProxies, err)
+	}
+	if _, err = loadConfig(t, "-trusted_proxies", "proxy.internal"); err == nil {
+		t.Error("Load accepted a proxy that is neither an IP nor a CIDR range")
+	}
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/logging"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+)
+
+// clearConfigEnv unsets the variables the tests set, in case the environment
+// running them has them. Empty variables count as unset.
+func clearConfigEnv(t *testing.T) {
+	for _, name := range []string{"CONFIG_FILE", "PORT", "APP_URL", "API_KEY", "PARSER_URL", "DB_HOST", "DB_PORT", "DB_PASSWORD",
+		"SMTP_PASSWORD", "OIDC_ISSUER", "OIDC_CLIENT_ID", "OIDC_SCOPES", "OIDC_DEFAULT_ROLE", "RETENTION_RULES",
+		"RETENTION_INTERVAL", "BLIND_HIRING_REVEAL_STAGE", "MFA_REQUIRED_FOR_ADMINS", "LOG_FORMAT", "LOG_LEVEL",
+		"TRACING_EXPORTER", "TRACING_SAMPLE_RATIO", "TRUSTED_PROXIES"} {
+		t.Setenv(name, "")
+	}
+}
+
+func loadConfig(t *testing.T, args ...string) (*config.Config, error) {
+	t.Helper()
+	return config.Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := loadConfig(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.AppURL != "http://localhost:8080" || cfg.Database.Port != 5432 || cfg.SMTP.Port != 25 {
+		t.Errorf("defaults: port %d, appURL %q, database port %d, SMTP port %d", cfg.Port, cfg.AppURL,
+			cfg.Database.Port, cfg.SMTP.Port)
+	}
+	if cfg.Retention.Interval != 24*time.Hour || cfg.Encryption.ReencryptInterval != time.Hour {
+		t.Errorf("default intervals: %v, %v", cfg.Retention.Interval, cfg.Encryption.ReencryptInterval)
+	}
+	if cfg.BlindHiring.RevealStage != models.StageInterview || cfg.OIDC.DefaultRole != models.Applicant {
+		t.Errorf("defaults: reveal stage %q, default role %q", cfg.BlindHiring.RevealStage, cfg.OIDC.DefaultRole)
+	}
+	if cfg.Keys.JWTPrivateKey != "keys/app.rsa" || cfg.Storage.ResumesDir != "resumes" {
+		t.Errorf("default paths: %q, %q", cfg.Keys.JWTPrivateKey, cfg.Storage.ResumesDir)
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+port: 9000
+app_url: https://jobs.example.com/
+database:
+  host: db.internal
+  port: 6543
+oidc:
+  issuer: https://idp.example.com
+  client_id: resume-parser
+  scopes: [openid, email]
+retention:
+  rules: delete_resume:rejected:6
+  interval: 12h
+`)
+	t.Setenv("DB_HOST", "db.env")
+	t.Setenv("RETENTION_INTERVAL", "6h")
+	cfg, err := loadConfig(t, "-config", path, "-retention.interval", "2h", "-mfa_required_for_admins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9000 || cfg.Database.Port != 6543 || cfg.AppURL != "https://jobs.example.com" {
+		t.Errorf("from the file: port %d, database port %d, appURL %q", cfg.Port, cfg.Database.Port, cfg.AppURL)
+	}
+	if cfg.Database.Host != "db.env" {
+		t.Errorf("the environment didn't override the file: %q", cfg.Database.Host)
+	}
+	if cfg.Retention.Interval != 2*time.Hour || !cfg.MFARequiredForAdmins {
+		t.Errorf("flags didn't override the environment: %v, %v", cfg.Retention.Interval, cfg.MFARequiredForAdmins)
+	}
+	expected := retention.Rules{{Action: retention.DeleteResume, Subject: retention.Rejected, Months: 6}}
+	if len(cfg.Retention.Rules) != 1 || cfg.Retention.Rules[0] != expected[0] {
+		t.Errorf("retention rules = %v", cfg.Retention.Rules)
+	}
+	if strings.Join(cfg.OIDC.Scopes, " ") != "openid email" {
+		t.Errorf("scopes = %v", cfg.OIDC.Scopes)
+	}
+	if cfg.OIDC.RedirectURL != "http://localhost:9000/auth/oidc/callback" {
+		t.Errorf("derived redirect URL = %q", cfg.OIDC.RedirectURL)
+	}
+}
+
+func TestConfigTOMLFile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.toml", `
+port = 9001
+
+[smtp]
+host = "mail.internal"
+password = "hunter2"
+
+[oidc]
+admin_groups = "recruiters, hiring-managers"
+`)
+	t.Setenv("CONFIG_FILE", path)
+	cfg, err := loadConfig(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9001 || cfg.SMTP.Host != "mail.internal" || cfg.SMTP.Password != "hunter2" {
+		t.Errorf("from the TOML file: port %d, SMTP host %q", cfg.Port, cfg.SMTP.Host)
+	}
+	if strings.Join(cfg.OIDC.AdminGroups, "|") != "recruiters|hiring-managers" {
+		t.Errorf("admin groups = %v", cfg.OIDC.AdminGroups)
+	}
+}
+
+func TestConfigRejectsInvalidSettings(t *testing.T) {
+	clearConfigEnv(t)
+	for _, args := range [][]string{
+		{"-port", "0"},
+		{"-port", "http"},
+		{"-retention.interval", "30s"},
+		{"-retention.rules", "erase:everyone:6"},
+		{"-blind_hiring.reveal_stage", "applied"},
+		{"-oidc.default_role", "Owner"},
+		{"-oidc.issuer", "https://idp.example.com"},
+		{"-app_url", "jobs.example.com"},
+		{"-mfa_required_for_admins=maybe"},
+		{"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+	} {
+		if _, err := loadConfig(t, args...); err == nil {
+			t.Errorf("Load(%v) accepted an invalid setting", args)
+		}
+	}
+
+	if _, err := loadConfig(t, "-config", writeConfigFile(t, "config.yaml", "databse:\n  host: x\n")); err == nil ||
+		!strings.Contains(err.Error(), "databse.host") {
+		t.Errorf("unknown key in the file: %v", err)
+	}
+	if _, err := loadConfig(t, "-config", writeConfigFile(t, "config.json", "{}")); err == nil {
+		t.Error("Load accepted a file that is neither YAML nor TOML")
+	}
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("DB_PASSWORD", "s3cret")
+	cfg, err := loadConfig(t, "-smtp.password", "hunter2", "-database.host", "db.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "s3cret") || strings.Contains(out, "hunter2") {
+		t.Errorf("Print() shows secrets:\n%s", out)
+	}
+	for _, line := range []string{
+		`database.password`, `"[redacted]"`, `# env DB_PASSWORD`,
+		`database.host`, `"db.internal"`, `# flag`,
+		`database.port`, `"5432"`, `# default`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Print() is missing %q:\n%s", line, out)
+		}
+	}
+	// unset secrets aren't redacted, so it shows they are missing
+	if !strings.Contains(out, `oidc.client_secret                   = ""`) {
+		t.Errorf("Print() redacted an unset secret:\n%s", out)
+	}
+}
+
+func TestConfigIsLoggedAsJSON(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := loadConfig(t, "-database.password", "s3cret", "-database.host", "db.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logging.New(&buf, jsonLogging(slog.LevelInfo)).Info("configuration", "config", cfg)
+	records := decodeLog(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("records = %v", records)
+	}
+	logged, ok := records[0]["config"].(map[string]interface{})
+	if !ok || logged["database.host"] != "db.internal" || logged["database.password"] != "[redacted]" {
+		t.Errorf("config = %v", records[0]["config"])
+	}
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Errorf("the log shows the secret: %s", buf.String())
+	}
+}
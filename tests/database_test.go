@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/models"
+)
+
+// testDatabase connects to the throwaway Postgres database of TEST_DB_HOST,
+// TEST_DB_PORT, TEST_DB_DATABASE, TEST_DB_USERNAME and TEST_DB_PASSWORD and
+// loads the schema into it, dropping everything that was there. Tests using
+// it are skipped without TEST_DB_HOST.
+func testDatabase(t *testing.T, cipher *encryption.Cipher) database.Service {
+	t.Helper()
+	cfg := testDatabaseConfig(t)
+	schema, err := os.ReadFile("../schema/databaseSchema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := testSQL(t)
+	if _, err = db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	s := database.New(cfg, cipher)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testDatabaseConfig(t *testing.T) config.Database {
+	t.Helper()
+	cfg := config.Database{Host: os.Getenv("TEST_DB_HOST"), Port: 5432, Name: os.Getenv("TEST_DB_DATABASE"),
+		Username: os.Getenv("TEST_DB_USERNAME"), Password: os.Getenv("TEST_DB_PASSWORD"), SSLMode: "disable"}
+	if cfg.Host == "" {
+		t.Skip("TEST_DB_HOST isn't set")
+	}
+	if port := os.Getenv("TEST_DB_PORT"); port != "" {
+		var err error
+		if cfg.Port, err = strconv.Atoi(port); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+// testSQL is a plain connection to the database of testDatabase, for what
+// the service won't do.
+func testSQL(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("postgres", testDatabaseConfig(t).ConnString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testDatabaseArgs are the flags pointing a test server at the database of
+// testDatabase.
+func testDatabaseArgs() []string {
+	args := []string{"-database.host", os.Getenv("TEST_DB_HOST"), "-database.name", os.Getenv("TEST_DB_DATABASE"),
+		"-database.username", os.Getenv("TEST_DB_USERNAME"), "-database.password", os.Getenv("TEST_DB_PASSWORD"), "-database.sslmode", "disable"}
+	if port := os.Getenv("TEST_DB_PORT"); port != "" {
+		args = append(args, "-database.port", port)
+	}
+	return args
+}
+
+func createTestUser(t *testing.T, s database.Service, email string) int {
+	t.Helper()
+	if err := s.CreateUser("Jane Doe", email, "hash", "1 Main St", "Engineer"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.GetUserId(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func createTestJob(t *testing.T, s database.Service, postedBy int, title string) int {
+	t.Helper()
+	id, err := s.CreateJob(models.Job{Title: title, Description: "Build things", CompanyName: "Acme",
+		TotalApplications: 10}, postedBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func jobIds(jobs []models.Job) map[int]bool {
+	ids := map[int]bool{}
+	for _, job := range jobs {
+		ids[job.Id] = true
+	}
+	return ids
+}
+
+func TestOpenJobsLeaveOutAppliedAndWithdrawnJobs(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	open := createTestJob(t, s, admin, "Open")
+	applied := createTestJob(t, s, admin, "Applied")
+	withdrawn := createTestJob(t, s, admin, "Withdrawn")
+	for _, job := range []int{applied, withdrawn} {
+		if err := s.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.WithdrawApplication(withdrawn, applicant); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := s.GetOpenJobs(applicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := jobIds(jobs); !ids[open] || ids[applied] || ids[withdrawn] {
+		t.Errorf("open jobs = %v, expected only %d", ids, open)
+	}
+}
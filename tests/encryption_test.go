@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"resume-backend-parser/internal/encryption"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+)
+
+func newTestCipher(t *testing.T) (*encryption.Cipher, string) {
+	t.Helper()
+	keyfile, err := encryption.NewKeyfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err = keyfile.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := encryption.FromKeyfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher, path
+}
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	encrypted, err := cipher.EncryptString("jane@example.com", "profile.email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encrypted, "jane") || !strings.HasPrefix(encrypted, cipher.CurrentPrefix()) {
+		t.Fatalf("EncryptString() = %q", encrypted)
+	}
+	again, _ := cipher.EncryptString("jane@example.com", "profile.email")
+	if again == encrypted {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+	decrypted, err := cipher.DecryptString(encrypted, "profile.email")
+	if err != nil || decrypted != "jane@example.com" {
+		t.Errorf("DecryptString() = %q, %v", decrypted, err)
+	}
+	if _, err = cipher.DecryptString(encrypted, "profile.phone"); !errors.Is(err, encryption.ErrCorrupt) {
+		t.Errorf("decrypting with other associated data: %v, expected ErrCorrupt", err)
+	}
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "BB"
+	}
+	if _, err = cipher.DecryptString(tampered, "profile.email"); !errors.Is(err, encryption.ErrCorrupt) {
+		t.Errorf("decrypting a tampered value: %v, expected ErrCorrupt", err)
+	}
+}
+
+func TestDecryptStringPassesPlaintextThrough(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	for _, c := range []*encryption.Cipher{cipher, nil} {
+		if v, err := c.DecryptString("+1 555 0100", "profile.phone"); err != nil || v != "+1 555 0100" {
+			t.Errorf("DecryptString(plaintext) = %q, %v", v, err)
+		}
+	}
+	if v, err := cipher.EncryptString("", "profile.phone"); err != nil || v != "" {
+		t.Errorf("EncryptString(\"\") = %q, %v", v, err)
+	}
+}
+
+func TestNilCipherStoresPlaintext(t *testing.T) {
+	var cipher *encryption.Cipher
+	if v, _ := cipher.EncryptString("12 Main St", "users.address"); v != "12 Main St" {
+		t.Errorf("EncryptString() = %q", v)
+	}
+	if data, _ := cipher.Encrypt([]byte("resume"), "resumes/1/cv.pdf"); string(data) != "resume" {
+		t.Errorf("Encrypt() = %q", data)
+	}
+	if cipher.BlindIndex("profile.email", "jane@example.com") != "" || cipher.Stale([]byte("resume")) {
+		t.Error("a nil cipher has no blind indexes or stale values")
+	}
+
+	other, _ := newTestCipher(t)
+	encrypted, _ := other.EncryptString("12 Main St", "users.address")
+	if _, err := cipher.DecryptString(encrypted, "users.address"); !errors.Is(err, encryption.ErrNoKeys) {
+		t.Errorf("decrypting without keys: %v, expected ErrNoKeys", err)
+	}
+}
+
+func TestEncryptBlob(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	resume := []byte("%PDF-1.4\nJane Doe\n\x00\x01binary")
+	encrypted, err := cipher.Encrypt(resume, "resumes/7/cv.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("Jane")) || !bytes.HasPrefix(encrypted, []byte(cipher.CurrentPrefix())) {
+		t.Fatalf("Encrypt() = %q", encrypted)
+	}
+	decrypted, err := cipher.Decrypt(encrypted, "resumes/7/cv.pdf")
+	if err != nil || !bytes.Equal(decrypted, resume) {
+		t.Errorf("Decrypt() = %q, %v", decrypted, err)
+	}
+	if _, err = cipher.Decrypt(encrypted, "resumes/8/cv.pdf"); !errors.Is(err, encryption.ErrCorrupt) {
+		t.Errorf("decrypting another applicant's file: %v, expected ErrCorrupt", err)
+	}
+	if plain, err := cipher.Decrypt(resume, "resumes/7/cv.pdf"); err != nil || !bytes.Equal(plain, resume) {
+		t.Errorf("Decrypt(plaintext) = %q, %v", plain, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	cipher, path := newTestCipher(t)
+	old, _ := cipher.EncryptString("jane@example.com", "profile.email")
+	oldIndex := cipher.BlindIndex("profile.email", "jane@example.com")
+	if cipher.Stale([]byte(old)) {
+		t.Error("a value under the current key is stale")
+	}
+	if !cipher.Stale([]byte("jane@example.com")) {
+		t.Error("plaintext isn't stale")
+	}
+
+	keyfile, err := encryption.LoadKeyfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := keyfile.CurrentKeyID()
+	id, err := keyfile.Rotate()
+	if err != nil || id == previous || keyfile.CurrentKeyID() != id {
+		t.Fatalf("Rotate() = %q, %v; previous key %q", id, err, previous)
+	}
+	if err = keyfile.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("keyfile mode = %v, %v", info.Mode().Perm(), err)
+	}
+	rotated, err := encryption.FromKeyfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.Stale([]byte(old)) {
+		t.Error("a value under the previous key isn't stale after rotating")
+	}
+	if v, err := rotated.DecryptString(old, "profile.email"); err != nil || v != "jane@example.com" {
+		t.Errorf("decrypting under the previous key = %q, %v", v, err)
+	}
+	if rotated.BlindIndex("profile.email", "jane@example.com") != oldIndex {
+		t.Error("rotating changed the blind index")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	index := cipher.BlindIndex("profile.email", "jane@example.com")
+	if len(index) != 64 || index != cipher.BlindIndex("profile.email", "jane@example.com") {
+		t.Errorf("BlindIndex() = %q, expected a deterministic hex sha256", index)
+	}
+	if index == cipher.BlindIndex("profile.phone", "jane@example.com") {
+		t.Error("the same value in different columns has the same blind index")
+	}
+	other, _ := newTestCipher(t)
+	if index == other.BlindIndex("profile.email", "jane@example.com") {
+		t.Error("blind indexes don't depend on the key")
+	}
+	if cipher.BlindIndex("profile.email", "") != "" {
+		t.Error("empty values have a blind index")
+	}
+}
+
+func TestLoadKeyfileRejectsInvalidKeys(t *testing.T) {
+	for _, content := range []string{
+		`{`,
+		`{"currentKey": "k1", "keys": {}, "indexKey": "` + strings.Repeat("A", 44) + `"}`,
+		`{"currentKey": "k1", "keys": {"k1": "c2hvcnQ="}, "indexKey": "` + strings.Repeat("A", 44) + `"}`,
+		`{"currentKey": "k:1", "keys": {"k:1": "` + strings.Repeat("A", 43) + `="}, "indexKey": "` + strings.Repeat("A", 44) + `"}`,
+		`{"currentKey": "k1", "keys": {"k1": "` + strings.Repeat("A", 43) + `="}, "indexKey": ""}`,
+	} {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := encryption.LoadKeyfile(path); err == nil {
+			t.Errorf("LoadKeyfile accepted %s", content)
+		}
+	}
+}
+
+func TestWriteDataExportDecryptsResumes(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	dir := filepath.Join(t.TempDir(), "7")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := cipher.Encrypt([]byte("resume contents"), "resumes/7/resume.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "resume.pdf"), encrypted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	export := models.DataExport{Account: models.User{Id: 7}}
+	if err = server.WriteDataExport(&buf, export, dir, cipher); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archive.File {
+		if f.Name != "resumes/resume.pdf" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		if string(content) != "resume contents" {
+			t.Errorf("exported resume = %q", content)
+		}
+		return
+	}
+	t.Error("the export has no resume")
+}
+
+func TestLegacyValuesStillDecrypt(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	encrypted, err := cipher.EncryptString("jane@example.com", "profile.email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encryption.Legacy(encrypted) {
+		t.Errorf("a new value is legacy: %s", encrypted)
+	}
+	// v1 only differs in the version
+	legacy := strings.Replace(encrypted, "enc:v2:", "enc:v1:", 1)
+	if !encryption.Legacy(legacy) || !cipher.Stale([]byte(legacy)) {
+		t.Errorf("a v1 value isn't legacy and stale: %s", legacy)
+	}
+	if decrypted, err := cipher.DecryptString(legacy, "profile.email"); err != nil || decrypted != "jane@example.com" {
+		t.Errorf("DecryptString(v1) = %q, %v", decrypted, err)
+	}
+}
+
+func TestEncryptedValuesAreBoundToTheirRow(t *testing.T) {
+	cipher, _ := newTestCipher(t)
+	s := testDatabase(t, cipher)
+	jane := createTestUser(t, s, "jane@example.com")
+	createTestUser(t, s, "john@example.com")
+	if user, err := s.GetUser("john@example.com"); err != nil || user.Address != "1 Main St" {
+		t.Fatalf("GetUser() = %+v, %v", user, err)
+	}
+
+	// the ciphertext of another account's address doesn't decrypt
+	_, err := testSQL(t).Exec("UPDATE users SET address = (SELECT address FROM users WHERE id = $1) WHERE email = $2",
+		jane, "john@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.GetUser("john@example.com"); !errors.Is(err, encryption.ErrCorrupt) {
+		t.Errorf("reading an address copied from another row: %v, expected ErrCorrupt", err)
+	}
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"resume-backend-parser/internal/health"
+)
+
+func healthCheck(name string, optional bool, f func(ctx context.Context) error) *health.Check {
+	return &health.Check{Name: name, Optional: optional, Timeout: time.Second, Func: f}
+}
+
+func up(ctx context.Context) error   { return nil }
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestHealthAggregatesComponents(t *testing.T) {
+	for _, test := range []struct {
+		checker  *health.Checker
+		expected health.Status
+	}{
+		{health.New(), health.Up},
+		{health.New(healthCheck("database", false, up), healthCheck("parser", true, up)), health.Up},
+		{health.New(healthCheck("database", false, up), healthCheck("parser", true, down)), health.Degraded},
+		{health.New(healthCheck("database", false, down), healthCheck("parser", true, down)), health.Down},
+		{health.New(healthCheck("database", false, down), healthCheck("parser", true, up)), health.Down},
+	} {
+		report := test.checker.Check(context.Background())
+		if report.Status != test.expected {
+			t.Errorf("status = %s, expected %s: %+v", report.Status, test.expected, report.Components)
+		}
+	}
+
+	report := health.New(healthCheck("database", false, down), healthCheck("parser", true, up)).
+		Check(context.Background())
+	database := report.Components["database"]
+	if database.Status != health.Down || database.Error != "connection refused" || database.Optional {
+		t.Errorf("database = %+v", database)
+	}
+	if parser := report.Components["parser"]; parser.Status != health.Up || parser.Error != "" || !parser.Optional {
+		t.Errorf("parser = %+v", parser)
+	}
+}
+
+func TestHealthCheckTimesOut(t *testing.T) {
+	check := healthCheck("parser", false, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	check.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	report := health.New(check).Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the check took %s despite its timeout", elapsed)
+	}
+	if result := report.Components["parser"]; result.Status != health.Down || result.Error != "timed out after 50ms" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestHealthCachesResults(t *testing.T) {
+	var calls atomic.Int32
+	check := healthCheck("database", false, func(ctx context.Context) error {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	check.CacheFor = 200 * time.Millisecond
+	checker := health.New(check)
+
+	first := checker.Check(context.Background()).Components["database"]
+	if first.Cached || first.LatencyMs < 20 {
+		t.Errorf("first result = %+v", first)
+	}
+	done := make(chan health.Result)
+	for i := 0; i < 5; i++ {
+		go func() {
+			done <- checker.Check(context.Background()).Components["database"]
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if result := <-done; !result.Cached || result.CheckedAt != first.CheckedAt {
+			t.Errorf("result wasn't from the cache: %+v", result)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("checked %d times within the cache duration", calls.Load())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if result := checker.Check(context.Background()).Components["database"]; result.Cached || calls.Load() != 2 {
+		t.Errorf("result after the cache duration = %+v, %d checks", result, calls.Load())
+	}
+}
+
+func TestHealthAddReplacesChecks(t *testing.T) {
+	checker := health.New(healthCheck("database", false, down))
+	checker.Add(healthCheck("database", false, up))
+	checker.Add(healthCheck("storage", false, up))
+	report := checker.Check(context.Background())
+	if report.Status != health.Up || len(report.Components) != 2 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+// closedPort is a port nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestReadyzReportsComponents(t *testing.T) {
+	s, addr, _ := newTestServer(t, "-database.host", "127.0.0.1", "-database.port", closedPort(t))
+	defer s.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + addr + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/livez = %d, expected %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp, err = http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report health.Report
+	if err = json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || report.Status != health.Down {
+		t.Errorf("/readyz = %d %s, expected %d down", resp.StatusCode, report.Status, http.StatusServiceUnavailable)
+	}
+	if report.Components["database"].Status != health.Down || report.Components["storage"].Status != health.Up {
+		t.Errorf("components = %+v", report.Components)
+	}
+	if parser := report.Components["parser"]; parser.Status != health.Down || parser.Error != "no API key is configured" {
+		t.Errorf("parser = %+v", parser)
+	}
+	if jobs := report.Components["jobs"]; jobs.Status != health.Up {
+		t.Errorf("jobs = %+v", jobs)
+	}
+}
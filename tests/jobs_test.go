@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"resume-backend-parser/internal/models"
+)
+
+func TestJobValidation(t *testing.T) {
+	db := testDatabase(t, nil)
+	s, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	admin, err := db.CreateAdmin("Admin", "admin@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobId := createTestJob(t, db, admin, "Existing")
+	token, err := s.CreateTokens("admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, path string, body map[string]interface{}) (int, string) {
+		t.Helper()
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(method, "http://"+addr+path, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var apiResp map[string]string
+		if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, apiResp["error"]
+	}
+	// job is a valid posting with the given fields changed
+	job := func(changes map[string]interface{}) map[string]interface{} {
+		body := map[string]interface{}{
+			"title": "Backend Engineer", "description": "Build things", "companyName": "Acme",
+			"totalApplications": "10", "requiredSkills": []string{"Go"}, "preferredSkills": []string{"Kubernetes"},
+			"minExperienceYears": 3, "educationLevel": "bachelor", "remotePolicy": "hybrid",
+			"employmentType": "full_time", "salary": map[string]interface{}{"min": 50000, "max": 70000, "currency": "eur"},
+		}
+		for key, value := range changes {
+			body[key] = value
+		}
+		return body
+	}
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		err     string
+	}{
+		{"valid", nil, ""},
+		{"no requirements", map[string]interface{}{"requiredSkills": nil, "preferredSkills": nil,
+			"minExperienceYears": 0, "educationLevel": "", "remotePolicy": "", "employmentType": "", "salary": nil}, ""},
+		// the description used to be capped at 200 characters
+		{"long description", map[string]interface{}{"description": strings.Repeat("a", 10000)}, ""},
+		{"too long description", map[string]interface{}{"description": strings.Repeat("a", 10001)}, "description"},
+		{"blank description", map[string]interface{}{"description": "  "}, "description"},
+		{"duplicate skills are merged", map[string]interface{}{"requiredSkills": []string{"Go", " go ", "", "SQL"}}, ""},
+		{"required and preferred skill", map[string]interface{}{"preferredSkills": []string{"GO"}},
+			"GO cannot be both a required and a preferred skill"},
+		{"too many skills", map[string]interface{}{"requiredSkills": numberedSkills(51)}, "requiredSkills"},
+		{"too long skill", map[string]interface{}{"preferredSkills": []string{strings.Repeat("a", 51)}}, "preferredSkills"},
+		{"negative experience", map[string]interface{}{"minExperienceYears": -1}, "minExperienceYears"},
+		{"too much experience", map[string]interface{}{"minExperienceYears": 51}, "minExperienceYears"},
+		{"unknown education", map[string]interface{}{"educationLevel": "phd"}, "educationLevel"},
+		{"unknown remote policy", map[string]interface{}{"remotePolicy": "anywhere"}, "remotePolicy"},
+		{"unknown employment type", map[string]interface{}{"employmentType": "freelance"}, "employmentType"},
+		{"negative salary", map[string]interface{}{"salary": map[string]interface{}{"min": -1, "max": 10, "currency": "EUR"}},
+			"salary.min"},
+		{"reversed salary", map[string]interface{}{"salary": map[string]interface{}{"min": 70000, "max": 50000, "currency": "EUR"}},
+			"salary.min"},
+		{"invalid currency", map[string]interface{}{"salary": map[string]interface{}{"min": 1, "max": 2, "currency": "euro"}},
+			"salary.currency"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, path := range []string{"POST /admin/job", "PUT /admin/job/" + strconv.Itoa(jobId)} {
+				method, path, _ := strings.Cut(path, " ")
+				status, message := send(method, path, job(test.changes))
+				switch {
+				case test.err == "" && status != http.StatusOK:
+					t.Errorf("%s %s = %d %q, expected it to be accepted", method, path, status, message)
+				case test.err != "" && (status != http.StatusBadRequest || !strings.Contains(message, test.err)):
+					t.Errorf("%s %s = %d %q, expected a 400 about %s", method, path, status, message, test.err)
+				}
+			}
+		})
+	}
+
+	// the last accepted update is normalized before it is stored
+	if status, message := send(http.MethodPut, "/admin/job/"+strconv.Itoa(jobId),
+		job(map[string]interface{}{"requiredSkills": []string{" Go", "go", "SQL "}})); status != http.StatusOK {
+		t.Fatalf("update = %d %q", status, message)
+	}
+	stored, err := db.GetJob(jobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(stored.RequiredSkills, ","); got != "Go,SQL" {
+		t.Errorf("required skills = %s, expected Go,SQL", got)
+	}
+	if stored.Salary == nil || stored.Salary.Currency != "EUR" || stored.EducationLevel != models.EducationBachelor {
+		t.Errorf("stored job = %+v", stored.JobRequirements)
+	}
+}
+
+func numberedSkills(n int) []string {
+	skills := make([]string, n)
+	for i := range skills {
+		skills[i] = "skill " + strconv.Itoa(i)
+	}
+	return skills
+}
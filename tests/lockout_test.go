@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"resume-backend-parser/internal/lockout"
+	"resume-backend-parser/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := lockout.DefaultPolicy
+	cases := map[int]time.Duration{0: 0, 3: 0, 4: time.Second, 5: 2 * time.Second, 7: 8 * time.Second, 20: 30 * time.Second}
+	for failures, expected := range cases {
+		if actual := p.Delay(failures, p.AccountFreeAttempts); actual != expected {
+			t.Errorf("Delay(%d) = %v, expected %v", failures, actual, expected)
+		}
+	}
+}
+
+func TestAccountWait(t *testing.T) {
+	p := lockout.DefaultPolicy
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if wait := p.AccountWait(2, now.Add(-time.Second), time.Time{}, now); wait != 0 {
+		t.Errorf("AccountWait() within the free attempts = %v", wait)
+	}
+	if wait := p.AccountWait(5, now.Add(-time.Second), time.Time{}, now); wait != time.Second {
+		t.Errorf("AccountWait() after 5 failures = %v, expected 1s", wait)
+	}
+	if wait := p.AccountWait(10, now.Add(-time.Minute), now.Add(14*time.Minute), now); wait != 14*time.Minute {
+		t.Errorf("AccountWait() of a locked account = %v, expected 14m", wait)
+	}
+}
+
+func TestIPWait(t *testing.T) {
+	p := lockout.DefaultPolicy
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if wait := p.IPWait(10, now, now); wait != 0 {
+		t.Errorf("IPWait() within the free attempts = %v", wait)
+	}
+	if wait := p.IPWait(p.IPMaxFailures, now.Add(-5*time.Minute), now); wait != 10*time.Minute {
+		t.Errorf("IPWait() of a blocked IP = %v, expected 10m", wait)
+	}
+}
+
+func TestLockoutDoesntTellWhichAccountsExist(t *testing.T) {
+	db := testDatabase(t, nil)
+	_, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	createTestUser(t, db, "jane@example.com")
+	login := func(email string) string {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+"/login", "application/json",
+			strings.NewReader(`{"email": "`+email+`", "password": "wrong password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strconv.Itoa(resp.StatusCode) + " " + resp.Header.Get("Retry-After") + " " + strings.TrimSpace(string(body))
+	}
+
+	// the free attempts, then the delay
+	var existing, unknown []string
+	for i := 0; i < 5; i++ {
+		existing = append(existing, login("jane@example.com"))
+	}
+	for i := 0; i < 5; i++ {
+		unknown = append(unknown, login("nobody@example.com"))
+	}
+	if strings.Join(existing, "\n") != strings.Join(unknown, "\n") {
+		t.Errorf("an existing email got\n%s\nand an unknown one\n%s", strings.Join(existing, "\n"), strings.Join(unknown, "\n"))
+	}
+	if !strings.HasPrefix(unknown[4], "429 1 ") {
+		t.Errorf("the fifth attempt got %s, expected to wait a second", unknown[4])
+	}
+
+	// the lockout, from another IP and under any case of the email
+	now := time.Now().UTC()
+	for _, email := range []string{"jane@example.com", "Nobody@Example.com"} {
+		for i := 0; i < 10; i++ {
+			err := db.RecordLoginAttempt(models.LoginAttempt{Email: email, IP: "192.0.2.1", Outcome: models.LoginFailed,
+				AttemptedAt: now})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, email := range []string{"jane@example.com", "nobody@example.com"} {
+		status, retryAfter, _ := strings.Cut(login(email), " ")
+		retryAfter, _, _ = strings.Cut(retryAfter, " ")
+		if wait, _ := strconv.Atoi(retryAfter); status != "429" || wait < 800 {
+			t.Errorf("%s got %s with Retry-After %s, expected to be locked out", email, status, retryAfter)
+		}
+	}
+}
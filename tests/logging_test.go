@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/logging"
+)
+
+func jsonLogging(level slog.Level) config.Logging {
+	return config.Logging{Format: config.JSONLogs, Level: level}
+}
+
+func decodeLog(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("not JSON: %q", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggingAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, jsonLogging(slog.LevelInfo))
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	if logging.RequestID(ctx) != "req-1" || logging.RequestID(context.Background()) != "" {
+		t.Errorf("RequestID() = %q", logging.RequestID(ctx))
+	}
+	logger.InfoContext(ctx, "in a request")
+	logger.With("job", "retention").Info("outside of requests")
+
+	records := decodeLog(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+	if records[0]["request_id"] != "req-1" {
+		t.Errorf("record = %v", records[0])
+	}
+	if _, ok := records[1]["request_id"]; ok || records[1]["job"] != "retention" {
+		t.Errorf("record = %v", records[1])
+	}
+}
+
+func TestLoggingRedactsPersonalData(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, jsonLogging(slog.LevelInfo))
+	logger.Info("sent the reset link to jane@example.com", "email", "jane@example.com", "phone", "+1 555 0100",
+		"user_id", 7, "error", errors.New(`user "jane.doe+jobs@example.co.uk" not found`),
+		slog.Group("request", "Password", "hunter2", "note", "ask jane@example.com"))
+	out := buf.String()
+	for _, leak := range []string{"jane@example.com", "jane.doe+jobs@example.co.uk", "555 0100", "hunter2"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("the log shows %q: %s", leak, out)
+		}
+	}
+	record := decodeLog(t, &buf)[0]
+	if record["email"] != "[redacted]" || record["user_id"] != float64(7) ||
+		record["error"] != `user "[redacted]" not found` || record["msg"] != "sent the reset link to [redacted]" {
+		t.Errorf("record = %v", record)
+	}
+	if group, _ := record["request"].(map[string]interface{}); group["Password"] != "[redacted]" ||
+		group["note"] != "ask [redacted]" {
+		t.Errorf("group = %v", record["request"])
+	}
+}
+
+func TestLoggingFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, config.Logging{Format: config.TextLogs, Level: slog.LevelWarn})
+	logger.Info("not logged")
+	logger.Warn("logged", "email", "jane@example.com")
+	out := buf.String()
+	if strings.Contains(out, "not logged") || !strings.Contains(out, `level=WARN msg=logged email=[redacted]`) {
+		t.Errorf("text log = %q", out)
+	}
+
+	clearConfigEnv(t)
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "debug")
+	cfg, err := loadConfig(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Logging.Format != config.JSONLogs || cfg.Logging.Level != slog.LevelDebug {
+		t.Errorf("logging = %+v", cfg.Logging)
+	}
+	if _, err = loadConfig(t, "-logging.format", "xml"); err == nil {
+		t.Error("Load accepted an unknown log format")
+	}
+}
+
+// syncBuffer is written to by the server's goroutines while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRequestIDs(t *testing.T) {
+	var out syncBuffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&out, jsonLogging(slog.LevelInfo)))
+	defer slog.SetDefault(previous)
+	s, addr, _ := newTestServer(t)
+	defer s.Shutdown(context.Background())
+
+	get := func(requestID string) string {
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/?email=jane@example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("X-Request-ID")
+	}
+	if id := get("client-id.42"); id != "client-id.42" {
+		t.Errorf("a valid request id was replaced by %q", id)
+	}
+	generated := get("")
+	forged := get(`x" msg="forged`)
+	for _, id := range []string{generated, forged} {
+		if len(id) != 32 {
+			t.Errorf("generated request id = %q", id)
+		}
+	}
+
+	log := out.String()
+	for _, id := range []string{"client-id.42", generated, forged} {
+		if !strings.Contains(log, `"request_id":"`+id+`"`) {
+			t.Errorf("no record with request id %q:\n%s", id, log)
+		}
+	}
+	if strings.Contains(log, "forged") || strings.Contains(log, "jane@example.com") {
+		t.Errorf("the access log shows the query string or a forged id:\n%s", log)
+	}
+}
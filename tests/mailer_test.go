@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"net"
+	"resume-backend-parser/internal/logging"
+	"resume-backend-parser/internal/mailer"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal MailHog-like stand-in that accepts a single
+// message and hands the envelope and data back over the channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				received <- lines
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	m := &mailer.SMTPMailer{Addr: addr, From: "noreply@example.com"}
+
+	err := m.Send(mailer.Message{To: "jane@example.com", Subject: "Verify your email address", Body: "Hello\n.\nBye"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	message := strings.Join(<-received, "\n")
+	for _, expected := range []string{
+		"MAIL FROM:<noreply@example.com>",
+		"RCPT TO:<jane@example.com>",
+		"Subject: Verify your email address",
+		"Content-Type: text/plain; charset=utf-8",
+		"Hello\n..\nBye",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("message does not contain %q:\n%s", expected, message)
+		}
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	m := &mailer.SMTPMailer{Addr: "127.0.0.1:1", From: "noreply@example.com"}
+	err := m.Send(mailer.Message{To: "jane@example.com\r\nBcc: all@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Error("Send() accepted a recipient with a line break")
+	}
+}
+
+func TestSMTPMailerTimesOut(t *testing.T) {
+	// accepts connections but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	m := &mailer.SMTPMailer{Addr: listener.Addr().String(), From: "noreply@example.com", Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	err = m.Send(mailer.Message{To: "jane@example.com", Subject: "Reset your password", Body: "..."})
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("Send() to a stuck server = %v after %v", err, time.Since(start))
+	}
+}
+
+func TestLogMailerRedactsTheRecipient(t *testing.T) {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&out, jsonLogging(slog.LevelInfo)))
+	defer slog.SetDefault(previous)
+
+	err := mailer.LogMailer{}.Send(mailer.Message{
+		To:      "jane@example.com",
+		Subject: "Reset your password",
+		Body:    "https://example.com/reset?token=secret-token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := decodeLog(t, &out)
+	if len(records) != 1 || records[0]["subject"] != "Reset your password" {
+		t.Fatalf("records = %v", records)
+	}
+	for _, leaked := range []string{"jane@example.com", "secret-token"} {
+		if strings.Contains(out.String(), leaked) {
+			t.Errorf("log holds %q: %s", leaked, out.String())
+		}
+	}
+}
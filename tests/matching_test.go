@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"reflect"
+	"resume-backend-parser/internal/matching"
+	"resume-backend-parser/internal/models"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	job := models.Job{
+		Id: 1,
+		JobRequirements: models.JobRequirements{
+			RequiredSkills:     []string{"Go", "PostgreSQL", "Docker", "gRPC"},
+			PreferredSkills:    []string{"Kubernetes"},
+			MinExperienceYears: 4,
+			EducationLevel:     models.EducationBachelor,
+		},
+	}
+	profile := models.MatchProfile{
+		Applicant:  7,
+		Skills:     []string{"golang", "Postgres", "k8s"},
+		Education:  []string{"B.Sc. Computer Science, TU Berlin"},
+		Experience: []string{"Backend Engineer"},
+		ResumeText: "Backend engineer with 2 years of experience shipping Docker based services.",
+	}
+
+	score := matching.Score(job, profile)
+
+	if !reflect.DeepEqual(score.MatchedSkills, []string{"Go", "PostgreSQL", "Docker"}) {
+		t.Errorf("Score() matched skills = %v", score.MatchedSkills)
+	}
+	if !reflect.DeepEqual(score.MissingSkills, []string{"gRPC"}) {
+		t.Errorf("Score() missing skills = %v", score.MissingSkills)
+	}
+	if !reflect.DeepEqual(score.MatchedPreferredSkills, []string{"Kubernetes"}) {
+		t.Errorf("Score() matched preferred skills = %v", score.MatchedPreferredSkills)
+	}
+	if score.ExperienceYears != 2 || score.EducationLevel != models.EducationBachelor {
+		t.Errorf("Score() experience = %d, education = %q", score.ExperienceYears, score.EducationLevel)
+	}
+	// 50*3/4 + 15 + 20*2/4 + 15 = 77.5 out of 100
+	if score.Score != 78 {
+		t.Errorf("Score() = %d, expected 78", score.Score)
+	}
+	if len(score.Explanation) != 4 {
+		t.Errorf("Score() explanation = %v", score.Explanation)
+	}
+}
+
+func TestScoreWithoutRequirements(t *testing.T) {
+	score := matching.Score(models.Job{}, models.MatchProfile{Skills: []string{"Go"}})
+	if score.Score != 0 || len(score.Explanation) != 1 {
+		t.Errorf("Score() = %d, explanation = %v", score.Score, score.Explanation)
+	}
+}
+
+func TestEstimateEducationLevel(t *testing.T) {
+	cases := map[string]models.EducationLevel{
+		"PhD in Physics":                  models.EducationDoctorate,
+		"MBA, INSEAD":                     models.EducationMaster,
+		"Bachelor of Arts":                models.EducationBachelor,
+		"Ms. Smith recommended me":        "",
+		"Springfield High School diploma": models.EducationHighSchool,
+	}
+	for input, expected := range cases {
+		if actual := matching.EstimateEducationLevel([]string{input}, ""); actual != expected {
+			t.Errorf("EstimateEducationLevel(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	jobs := []models.Job{
+		{Id: 1, Title: "Frontend Engineer", Description: "Build React interfaces in TypeScript",
+			JobRequirements: models.JobRequirements{RequiredSkills: []string{"React", "TypeScript"}}},
+		{Id: 2, Title: "Backend Engineer", Description: "Design Go microservices on PostgreSQL",
+			JobRequirements: models.JobRequirements{RequiredSkills: []string{"Go", "PostgreSQL"}, PreferredSkills: []string{"Kafka"}}},
+		{Id: 3, Title: "Accountant", Description: "Prepare quarterly statements"},
+	}
+	profile := models.MatchProfile{
+		Skills:     []string{"Golang", "Postgres"},
+		ResumeText: "Built microservices in Go backed by PostgreSQL",
+	}
+
+	recommendations := matching.Recommend(profile, jobs)
+
+	if len(recommendations) != 1 || recommendations[0].Job.Id != 2 {
+		t.Fatalf("Recommend() = %+v, expected only job 2", recommendations)
+	}
+	if len(recommendations[0].Reasons) != 2 {
+		t.Errorf("Recommend() reasons = %v", recommendations[0].Reasons)
+	}
+}
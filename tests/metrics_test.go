@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, addr string, token string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	s, addr, _ := newTestServer(t, "-database.host", "127.0.0.1", "-database.port", closedPort(t))
+	defer s.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + addr + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get("http://" + addr + "/no/such/route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// fails in UserExists, as the database is down
+	resp, err = http.Post("http://"+addr+"/signup", "application/json",
+		strings.NewReader(`{"email": "jane@example.com", "password": "hunter22"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	status, out := scrape(t, addr, "")
+	if status != http.StatusOK {
+		t.Fatalf("/metrics = %d", status)
+	}
+	for _, line := range []string{
+		`resume_parser_http_request_duration_seconds_count{method="GET",route="/livez",status="200"}`,
+		`resume_parser_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"}`,
+		`resume_parser_http_request_duration_seconds_count{method="POST",route="/signup",status="500"}`,
+		`resume_parser_db_query_duration_seconds_count{method="UserExists"}`,
+		`resume_parser_db_pool_max_open_connections`,
+		`resume_parser_db_pool_open_connections`,
+		`resume_parser_resume_uploads_total`,
+		`resume_parser_applications_total`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("/metrics is missing %q", line)
+		}
+	}
+	if strings.Contains(out, "jane@example.com") {
+		t.Error("/metrics shows personal data")
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	s, addr, _ := newTestServer(t, "-metrics.token", "scrape-me")
+	defer s.Shutdown(context.Background())
+
+	for token, expected := range map[string]int{
+		"":          http.StatusUnauthorized,
+		"wrong":     http.StatusUnauthorized,
+		"scrape-me": http.StatusOK,
+	} {
+		if status, _ := scrape(t, addr, token); status != expected {
+			t.Errorf("/metrics with token %q = %d, expected %d", token, status, expected)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/oidc"
+	"resume-backend-parser/internal/oidc/oidctest"
+	"resume-backend-parser/internal/server"
+	"strings"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost:8080/auth/oidc/callback"
+
+var recruiter = oidctest.User{
+	Subject:       "user-1",
+	Email:         "jane@example.com",
+	EmailVerified: true,
+	Name:          "Jane Doe",
+	Groups:        []string{"staff", "recruiters"},
+}
+
+func startProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	mock, err := oidctest.New("resume-parser", "secret", recruiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.Start()
+	t.Cleanup(mock.Close)
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       mock.Issuer,
+		ClientID:     "resume-parser",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock, provider
+}
+
+// authorize follows the redirect to the provider and returns the code and
+// state it sends back.
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != redirectURL {
+		t.Fatalf("redirected to %s", got)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	_, provider := startProvider(t)
+	ctx := context.Background()
+
+	state, _ := oidc.RandomString()
+	nonce, _ := oidc.RandomString()
+	verifier, _ := oidc.RandomString()
+	authURL := provider.AuthCodeURL(state, nonce, verifier)
+	query, _ := url.Parse(authURL)
+	if query.Query().Get("code_challenge") != oidc.CodeChallenge(verifier) || query.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("AuthCodeURL() lacks the PKCE challenge: %s", authURL)
+	}
+
+	code, returnedState := authorize(t, authURL)
+	if returnedState != state {
+		t.Errorf("state = %q, expected %q", returnedState, state)
+	}
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "jane@example.com" || !identity.EmailVerified || identity.Name != "Jane Doe" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[1] != "recruiters" {
+		t.Errorf("groups = %v", identity.Groups)
+	}
+
+	// codes are single-use
+	if _, err = provider.Exchange(ctx, code, verifier); err == nil {
+		t.Error("Exchange() accepted a used code")
+	}
+}
+
+func TestOIDCExchangeRequiresCodeVerifier(t *testing.T) {
+	_, provider := startProvider(t)
+	verifier, _ := oidc.RandomString()
+	code, _ := authorize(t, provider.AuthCodeURL("state", "nonce", verifier))
+
+	other, _ := oidc.RandomString()
+	if _, err := provider.Exchange(context.Background(), code, other); err == nil {
+		t.Error("Exchange() accepted the wrong code verifier")
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	mock, provider := startProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	valid, _ := mock.IDToken(recruiter, "nonce", now)
+	if _, err := provider.VerifyIDToken(ctx, valid, "nonce"); err != nil {
+		t.Errorf("VerifyIDToken() = %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, valid, "other"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken() with the wrong nonce = %v", err)
+	}
+
+	expired, _ := mock.IDToken(recruiter, "nonce", now.Add(-2*time.Hour))
+	if _, err := provider.VerifyIDToken(ctx, expired, "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken() of an expired token = %v", err)
+	}
+
+	// a token signed with another key is forged
+	foreign, err := oidctest.New("resume-parser", "secret", recruiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign.Issuer = mock.Issuer
+	forged, _ := foreign.IDToken(recruiter, "nonce", now)
+	if _, err = provider.VerifyIDToken(ctx, forged, "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken() of a forged token = %v", err)
+	}
+}
+
+func TestOIDCDiscoverChecksIssuer(t *testing.T) {
+	mock, _ := startProvider(t)
+	_, err := oidc.Discover(context.Background(), oidc.Config{Issuer: mock.Issuer + "/tenant"}, nil)
+	if err == nil {
+		t.Error("Discover() accepted a provider for another issuer")
+	}
+}
+
+func TestRoleForGroups(t *testing.T) {
+	adminGroups := []string{"recruiters", "hr"}
+	if role := server.RoleForGroups([]string{"staff", "hr"}, adminGroups, models.Applicant); role != models.Admin {
+		t.Errorf("RoleForGroups() of an hr member = %s", role)
+	}
+	if role := server.RoleForGroups([]string{"staff"}, adminGroups, models.Applicant); role != models.Applicant {
+		t.Errorf("RoleForGroups() of a staff member = %s", role)
+	}
+	if role := server.RoleForGroups(nil, nil, models.Admin); role != models.Admin {
+		t.Errorf("RoleForGroups() without groups = %s", role)
+	}
+}
+
+// ssoLogin runs a browser through the SSO login of the server at addr and
+// returns the fragment it ends up with.
+func ssoLogin(t *testing.T, addr string) url.Values {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	next := "http://" + addr + "/auth/oidc/login"
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("GET %s: status %d", next, resp.StatusCode)
+		}
+		// the provider redirects to the registered URL, which is this server
+		next = strings.Replace(resp.Header.Get("Location"), "localhost:8080", addr, 1)
+	}
+	location, err := url.Parse(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/sso/callback" {
+		t.Fatalf("ended up at %s", next)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fragment
+}
+
+func TestSSOLoginKeepsLockoutAndSecondFactor(t *testing.T) {
+	db := testDatabase(t, nil)
+	mock, _ := startProvider(t)
+	userId := createTestUser(t, db, recruiter.Email)
+	_, addr, _ := newTestServer(t, append(testDatabaseArgs(), "-oidc.issuer", mock.Issuer,
+		"-oidc.client_id", "resume-parser", "-oidc.client_secret", "secret", "-oidc.redirect_url", redirectURL)...)
+
+	if fragment := ssoLogin(t, addr); fragment.Get("token") == "" {
+		t.Fatalf("fragment = %v, expected a token", fragment)
+	}
+
+	if err := db.SetMFASecret(userId, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.EnableMFA(userId, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	fragment := ssoLogin(t, addr)
+	if fragment.Get("token") != "" || fragment.Get("mfaToken") == "" || fragment.Get("mfaRequired") != "true" {
+		t.Errorf("fragment = %v, expected an MFA token instead of a token", fragment)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < 10; i++ {
+		err := db.RecordLoginAttempt(models.LoginAttempt{Email: recruiter.Email, UserId: userId, IP: "192.0.2.1",
+			Outcome: models.LoginFailed, AttemptedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fragment = ssoLogin(t, addr)
+	if fragment.Get("token") != "" || fragment.Get("mfaToken") != "" || fragment.Get("error") == "" {
+		t.Errorf("fragment = %v, expected the locked account to be turned away", fragment)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+	"testing"
+	"time"
+)
+
+func readZip(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestWriteDataExport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cv.pdf"), []byte("%PDF resume"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	export := models.DataExport{
+		ExportedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Account:    models.User{Id: 7, Name: "Ada", Email: "ada@example.com"},
+		Profile:    &models.Profile{Applicant: "7", Skills: "go, sql"},
+		Applications: []models.ExportedApplication{{
+			Application: models.Application{Id: 3, Applicant: 7, Stage: models.StageApplied},
+			CoverLetter: "Hello",
+			Answers:     []models.ScreeningAnswer{{QuestionId: 1, Answer: "yes"}},
+		}},
+		AuditTrail: []models.AuditEntry{{Id: 1, Action: models.AuditLoginSucceeded, ActorId: 7}},
+	}
+
+	var buf bytes.Buffer
+	if err := server.WriteDataExport(&buf, export, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, buf.Bytes())
+	for _, name := range []string{"account.json", "profile.json", "applications.json", "audit_trail.json", "resumes/cv.pdf"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+	if string(files["resumes/cv.pdf"]) != "%PDF resume" {
+		t.Errorf("resumes/cv.pdf = %q", files["resumes/cv.pdf"])
+	}
+	var account models.User
+	if err := json.Unmarshal(files["account.json"], &account); err != nil || account.Email != "ada@example.com" {
+		t.Errorf("account.json = %s, %v", files["account.json"], err)
+	}
+	var applications []models.ExportedApplication
+	if err := json.Unmarshal(files["applications.json"], &applications); err != nil {
+		t.Fatal(err)
+	}
+	if len(applications) != 1 || applications[0].Id != 3 || applications[0].CoverLetter != "Hello" || len(applications[0].Answers) != 1 {
+		t.Errorf("applications.json = %s", files["applications.json"])
+	}
+}
+
+func TestWriteDataExportWithoutProfileOrFiles(t *testing.T) {
+	export := models.DataExport{Account: models.User{Id: 8}, Applications: []models.ExportedApplication{}, AuditTrail: []models.AuditEntry{}}
+	var buf bytes.Buffer
+	if err := server.WriteDataExport(&buf, export, filepath.Join(t.TempDir(), "missing"), nil); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, buf.Bytes())
+	if _, ok := files["profile.json"]; ok {
+		t.Error("archive has a profile.json without a profile")
+	}
+	if len(files) != 3 {
+		t.Errorf("archive has %d files, expected 3", len(files))
+	}
+}
+
+func TestEmailHash(t *testing.T) {
+	if database.EmailHash("Ada@Example.com ") != database.EmailHash("ada@example.com") {
+		t.Error("EmailHash() depends on case or surrounding space")
+	}
+	if database.EmailHash("ada@example.com") == database.EmailHash("bob@example.com") {
+		t.Error("EmailHash() is the same for different emails")
+	}
+	if len(database.EmailHash("ada@example.com")) != 64 {
+		t.Error("EmailHash() is not a hex sha256")
+	}
+}
+
+func TestEraseApplicantHidesTheEmailInTheAuditLog(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	now := time.Now().UTC()
+	for _, entry := range []models.AuditEntry{
+		{Action: models.AuditLoginSucceeded, ActorId: applicant, ActorEmail: "jane@example.com", RequestId: "req-1"},
+		// failed logins may have no actor id and another spelling
+		{Action: models.AuditLoginFailed, ActorEmail: "Jane@Example.com", RequestId: "req-2"},
+		{Action: models.AuditLoginSucceeded, ActorId: admin, ActorEmail: "admin@example.com", RequestId: "req-3"},
+	} {
+		entry.OccurredAt = now
+		if err := s.AppendAuditEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	erasure, err := s.EraseApplicant(applicant, models.Erasure{ErasedBy: admin, ErasedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erasure.Removed["audit_log"] != 2 {
+		t.Errorf("removed = %v, expected 2 audit log entries", erasure.Removed)
+	}
+	entries, err := s.ListAuditEntries(models.AuditFilters{}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actors := map[string]string{}
+	for _, entry := range entries {
+		actors[entry.RequestId] = entry.ActorEmail
+	}
+	erased := database.ErasedActor("jane@example.com")
+	if actors["req-1"] != erased || actors["req-2"] != erased || actors["req-3"] != "admin@example.com" {
+		t.Errorf("actors = %v, expected the applicant's email as %s", actors, erased)
+	}
+	if erased != "erased:"+erasure.EmailHash {
+		t.Errorf("erased actor %s doesn't match the tombstone hash %s", erased, erasure.EmailHash)
+	}
+}
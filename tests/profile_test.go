@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+)
+
+func TestManualProfileEditsSurviveReparsing(t *testing.T) {
+	s := testDatabase(t, nil)
+	applicant := createTestUser(t, s, "jane@example.com")
+	if err := s.UpdateProfile(applicant, "cv.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	name, skills := "Jane Q. Doe", []string{"go", "sql"}
+	if err := s.UpdateProfileManual(applicant, models.UpdateProfileRequest{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	// an edit without "reset" keeps the fields edited before
+	if err := s.UpdateProfileManual(applicant, models.UpdateProfileRequest{Skills: &skills}); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := s.GetApplicantProfile(applicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profile.ManualFields, []string{"name", "skills"}) {
+		t.Fatalf("manual fields = %v", profile.ManualFields)
+	}
+
+	parsed := models.ProfileThirdParty{Name: "JANE DOE", Skills: []string{"cobol"}, Phone: "+1 555 0100"}
+	if err = s.UpdateProfileWithFields(applicant, parsed, "JANE DOE cobol"); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err = s.GetApplicantProfile(applicant); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != name || profile.Skills != "go, sql" {
+		t.Errorf("re-parsing overwrote the corrections: name %q, skills %v", profile.Name, profile.Skills)
+	}
+
+	if err = s.UpdateProfileManual(applicant, models.UpdateProfileRequest{Reset: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err = s.GetApplicantProfile(applicant); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profile.ManualFields, []string{"skills"}) {
+		t.Errorf("manual fields after resetting the name = %v", profile.ManualFields)
+	}
+}
+
+func TestParserFailuresKeepTheParsedProfile(t *testing.T) {
+	db := testDatabase(t, nil)
+	var status int
+	var body string
+	parser := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer parser.Close()
+	s, _, _ := newTestServer(t, append(testDatabaseArgs(), "-parser_url", parser.URL)...)
+
+	applicant := createTestUser(t, db, "jane@example.com")
+	if err := db.UpdateProfile(applicant, "cv.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	parsed := models.ProfileThirdParty{Name: "Jane Doe", Skills: []string{"golang"}}
+	if err := db.UpdateProfileWithFields(applicant, parsed, "Jane Doe golang"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cv.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	parse := func(responseStatus int, responseBody string) models.Profile {
+		t.Helper()
+		status, body = responseStatus, responseBody
+		server.UploadResumeToThirdParty(context.Background(), applicant, path, s)
+		profile, err := db.GetApplicantProfile(applicant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return profile
+	}
+
+	for _, response := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusUnauthorized, `{"message": "Invalid authentication credentials"}`},
+		{http.StatusTooManyRequests, `{"message": "API rate limit exceeded"}`},
+		{http.StatusBadGateway, "Bad Gateway"},
+		{http.StatusOK, "not JSON"},
+		{http.StatusOK, `{"name": 5}`},
+	} {
+		if profile := parse(response.status, response.body); profile.Name != "Jane Doe" || profile.Skills != "golang" {
+			t.Errorf("after %d %q the profile is %+v", response.status, response.body, profile)
+		}
+	}
+	if profile := parse(http.StatusOK, `{"name": "Jane Q. Doe", "skills": ["golang", "sql"]}`); profile.Name != "Jane Q. Doe" {
+		t.Errorf("a parsed resume didn't update the profile: %+v", profile)
+	}
+}
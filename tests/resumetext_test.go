@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+)
+
+func TestResumeTextLeavesOutContactDetails(t *testing.T) {
+	parsed := map[string]interface{}{
+		"name":     "Jane Doe",
+		"email":    "jane@example.com",
+		"phone":    "+1 (555) 010-0199",
+		"address":  "1 Main St, Springfield",
+		"linkedin": "https://linkedin.com/in/janedoe",
+		"summary": "Backend developer, reach me at jane.doe@work.example or 555.010.0188, " +
+			"see www.janedoe.dev. Go and PostgreSQL since 2015.",
+		"experience": []interface{}{
+			map[string]interface{}{"role": "Engineer at Acme", "dates": "2019 - 2023",
+				"contact": map[string]interface{}{"phone": "0301234567"}},
+		},
+		"raw": "Call 0301234567",
+	}
+	profile := models.ProfileThirdParty{Name: "Jane Doe", Email: "jane@example.com", Phone: "0301234567"}
+	text := server.ResumeText(parsed, profile)
+
+	for _, contact := range []string{"jane@example.com", "jane.doe@work.example", "555", "0301234567", "Main St",
+		"linkedin", "janedoe.dev"} {
+		if strings.Contains(text, contact) {
+			t.Errorf("the resume text keeps %q:\n%s", contact, text)
+		}
+	}
+	for _, kept := range []string{"Jane Doe", "Backend developer", "Go and PostgreSQL since 2015", "Engineer at Acme",
+		"2019 - 2023"} {
+		if !strings.Contains(text, kept) {
+			t.Errorf("the resume text lost %q:\n%s", kept, text)
+		}
+	}
+}
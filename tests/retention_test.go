@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"errors"
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/retention"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := retention.ParseRules(" delete_resume:rejected:6, erase:inactive:24,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []retention.Rule{
+		{Action: retention.DeleteResume, Subject: retention.Rejected, Months: 6},
+		{Action: retention.Erase, Subject: retention.Inactive, Months: 24},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("ParseRules() = %v, expected %v", rules, expected)
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Errorf("rule %d = %v, expected %v", i, rules[i], expected[i])
+		}
+	}
+	if rules[0].String() != "delete_resume:rejected:6" {
+		t.Errorf("String() = %q", rules[0].String())
+	}
+
+	if rules, err := retention.ParseRules(""); err != nil || len(rules) != 0 {
+		t.Errorf("ParseRules(\"\") = %v, %v", rules, err)
+	}
+}
+
+func TestParseRetentionRulesRejectsInvalidRules(t *testing.T) {
+	for _, spec := range []string{"delete_resume:rejected", "purge:rejected:6", "erase:everyone:6",
+		"erase:inactive:0", "erase:inactive:-1", "erase:inactive:six", "erase:inactive:6:1"} {
+		if _, err := retention.ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) accepted an invalid rule", spec)
+		}
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	rule := retention.Rule{Action: retention.Erase, Subject: retention.Inactive, Months: 6}
+	now := time.Date(2024, 8, 15, 10, 0, 0, 0, time.UTC)
+	if cutoff := rule.Cutoff(now); !cutoff.Equal(time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Cutoff() = %v", cutoff)
+	}
+}
+
+func TestRejectedRetentionCountsLoginsAndIsCheckedWhenPurging(t *testing.T) {
+	s := testDatabase(t, nil)
+	admin := createTestUser(t, s, "admin@example.com")
+	applicant := createTestUser(t, s, "jane@example.com")
+	job := createTestJob(t, s, admin, "Engineer")
+	if err := s.ApplyJob(job, applicant, models.ApplicationSubmission{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateApplicationStage(job, applicant, models.StageRejected, admin); err != nil {
+		t.Fatal(err)
+	}
+	rule := retention.Rule{Action: retention.Erase, Subject: retention.Rejected, Months: 6}
+	// a year on, the rejection is long past
+	now := time.Now().UTC().AddDate(1, 0, 0)
+	matches, err := s.FindRetentionMatches(rule, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].UserId != applicant {
+		t.Fatalf("matches = %+v, expected the rejected applicant", matches)
+	}
+
+	// logging in after the match was found keeps the account
+	err = s.RecordLoginAttempt(models.LoginAttempt{Email: "jane@example.com", UserId: applicant, IP: "192.0.2.1",
+		Outcome: models.LoginSucceeded, AttemptedAt: now.AddDate(0, -1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := false
+	_, err = s.EraseApplicantByRule(applicant, models.Erasure{ErasedAt: now}, rule, now, func() error {
+		removed = true
+		return nil
+	})
+	if !errors.Is(err, database.ErrRetentionNoLongerApplies) || removed {
+		t.Errorf("erasing after a login: %v, files removed: %v", err, removed)
+	}
+	if matches, err = s.FindRetentionMatches(rule, now); err != nil || len(matches) != 0 {
+		t.Errorf("matches after a login = %+v, %v", matches, err)
+	}
+
+	later := now.AddDate(1, 0, 0)
+	erasure, err := s.EraseApplicantByRule(applicant, models.Erasure{ErasedAt: later}, rule, later, func() error {
+		removed = true
+		return nil
+	})
+	if err != nil || !removed || erasure.UserId != applicant {
+		t.Errorf("erasing once the rule applies again: %+v, %v, files removed: %v", erasure, err, removed)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/screening"
+	"testing"
+)
+
+func screeningQuestions() []models.ScreeningQuestion {
+	minYears := 2.0
+	return []models.ScreeningQuestion{
+		{Id: 1, Prompt: "Are you authorized to work in the EU?", Type: models.YesNo, Required: true, KnockoutAnswers: []string{"no"}},
+		{Id: 2, Prompt: "Years of Go experience", Type: models.Numeric, Required: true, Min: &minYears},
+		{Id: 3, Prompt: "Preferred office", Type: models.MultipleChoice, Options: []string{"Berlin", "Remote"}},
+		{Id: 4, Prompt: "Anything else?", Type: models.FreeText},
+	}
+}
+
+func TestValidateQuestions(t *testing.T) {
+	if err := screening.ValidateQuestions(screeningQuestions()); err != nil {
+		t.Errorf("ValidateQuestions() error = %v", err)
+	}
+	invalid := []models.ScreeningQuestion{
+		{Prompt: "Pick one", Type: models.MultipleChoice, Options: []string{"a", "b"}, KnockoutAnswers: []string{"c"}},
+	}
+	if err := screening.ValidateQuestions(invalid); err == nil {
+		t.Error("ValidateQuestions() accepted a knockout answer that is not an option")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	answers, knockedOut, err := screening.Evaluate(screeningQuestions(), []models.ScreeningAnswer{
+		{QuestionId: 1, Answer: "Yes"},
+		{QuestionId: 2, Answer: "3"},
+		{QuestionId: 3, Answer: "Remote"},
+	})
+	if err != nil || knockedOut || len(answers) != 3 || answers[0].Answer != "yes" {
+		t.Errorf("Evaluate() = %v, %v, %v", answers, knockedOut, err)
+	}
+
+	_, knockedOut, err = screening.Evaluate(screeningQuestions(), []models.ScreeningAnswer{
+		{QuestionId: 1, Answer: "yes"},
+		{QuestionId: 2, Answer: "1"},
+	})
+	if err != nil || !knockedOut {
+		t.Errorf("Evaluate() with too little experience = %v, %v", knockedOut, err)
+	}
+
+	_, _, err = screening.Evaluate(screeningQuestions(), []models.ScreeningAnswer{{QuestionId: 1, Answer: "yes"}})
+	if err == nil {
+		t.Error("Evaluate() accepted a missing required answer")
+	}
+
+	_, _, err = screening.Evaluate(screeningQuestions(), []models.ScreeningAnswer{
+		{QuestionId: 1, Answer: "yes"},
+		{QuestionId: 2, Answer: "5"},
+		{QuestionId: 3, Answer: "Paris"},
+	})
+	if err == nil {
+		t.Error("Evaluate() accepted an answer that is not an option")
+	}
+
+	// these compare false to the bounds, which would slip past the knockout
+	for _, answer := range []string{"NaN", "nan", "Inf", "+Inf", "-Infinity", "1e400"} {
+		_, _, err = screening.Evaluate(screeningQuestions(), []models.ScreeningAnswer{
+			{QuestionId: 1, Answer: "yes"},
+			{QuestionId: 2, Answer: answer},
+		})
+		if err == nil {
+			t.Errorf("Evaluate() accepted %q as a number", answer)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"resume-backend-parser/internal/database"
+	"resume-backend-parser/internal/models"
+	"resume-backend-parser/internal/server"
+	"testing"
+)
+
+func TestToTSQuery(t *testing.T) {
+	cases := map[string]string{
+		"golang developer":          "golang & developer",
+		`"machine learning" remote`: "(machine <-> learning) & remote",
+		"eng*":                      "eng:*",
+		"node.js":                   "(node <-> js)",
+		"c++ & | !":                 "c",
+		`"senior backend`:           "(senior <-> backend)",
+		"go OR rust":                "go | rust",
+		"python NOT django":         "python & !django",
+		`java -"spring boot"`:       "java & !(spring <-> boot)",
+		"kubernetes AND OR docker":  "kubernetes | docker",
+	}
+	for input, expected := range cases {
+		actual, err := database.ToTSQuery(input)
+		if err != nil {
+			t.Errorf("ToTSQuery(%q) error = %v", input, err)
+			continue
+		}
+		if actual != expected {
+			t.Errorf("ToTSQuery(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+
+	if _, err := database.ToTSQuery("  ***  "); err != database.ErrEmptySearchQuery {
+		t.Errorf("ToTSQuery() on empty input error = %v, expected %v", err, database.ErrEmptySearchQuery)
+	}
+}
+
+func TestSearchedFiltersLeaveOutTheValues(t *testing.T) {
+	filters := models.CandidateSearchFilters{Query: "golang", Skills: []string{"Go"}, Name: "Jane Doe",
+		Email: "jane@example.com", Phone: "+1 555 0100"}
+	if got := server.SearchedFilters(filters); got != "q,name,email,phone,skills" {
+		t.Errorf("SearchedFilters() = %q", got)
+	}
+	if got := server.SearchedFilters(models.CandidateSearchFilters{}); got != "" {
+		t.Errorf("SearchedFilters() without filters = %q", got)
+	}
+}
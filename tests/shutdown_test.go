@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"resume-backend-parser/internal/server"
+)
+
+// newTestServer sets up a server with fresh token keys and a database that
+// is never connected to, and serves it on a local port.
+func newTestServer(t *testing.T, args ...string) (*server.Server, string, <-chan error) {
+	t.Helper()
+	clearConfigEnv(t)
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, publicPath := filepath.Join(dir, "app.rsa"), filepath.Join(dir, "app.rsa.pub")
+	if err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(t, append([]string{"-keys.jwt_private_key", privatePath, "-keys.jwt_public_key", publicPath,
+		"-storage.resumes_dir", dir}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.NewServer(cfg)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+	return s, l.Addr().String(), served
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	s, addr, served := newTestServer(t, "-shutdown.delay", "300ms", "-shutdown.timeout", "5s")
+
+	// a request in flight, like an upload: its body is still on the way
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("POST /signup HTTP/1.1\r\nHost: localhost\r\nContent-Length: 8\r\n\r\nnot ")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(context.Background())
+	}()
+
+	// during the delay the listener is open, but reports shutting down
+	time.Sleep(50 * time.Millisecond)
+	// a spare connection of the keep-alive pool would hold up Shutdown
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for _, path := range []string{"/readyz", "/health"} {
+		resp, err := client.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s during shutdown = %d, expected %d", path, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+
+	// the request in flight still gets its response
+	time.Sleep(400 * time.Millisecond)
+	if _, err = conn.Write([]byte("json")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("the request in flight wasn't answered: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request in flight = %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	select {
+	case err = <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown() didn't return")
+	}
+	if err = <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Serve() = %v, expected http.ErrServerClosed", err)
+	}
+	if _, err = net.Dial("tcp", addr); err == nil {
+		t.Error("the listener is still open after Shutdown")
+	}
+}
+
+func TestShutdownGivesUpAfterTheTimeout(t *testing.T) {
+	s, addr, _ := newTestServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("POST /signup HTTP/1.1\r\nHost: localhost\r\nContent-Length: 8\r\n\r\nnot ")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err = s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, expected the deadline to be exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown() took %s", elapsed)
+	}
+}
+
+func TestShutdownWaitsForEmailsBeingSent(t *testing.T) {
+	db := testDatabase(t, nil)
+	createTestUser(t, db, "jane@example.com")
+	// accepts connections but never greets, so sending takes the whole timeout
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	timeout := 500 * time.Millisecond
+	s, addr, _ := newTestServer(t, append(testDatabaseArgs(), "-smtp.host", "127.0.0.1", "-smtp.port", port,
+		"-smtp.timeout", timeout.String())...)
+
+	start := time.Now()
+	resp, err := http.Post("http://"+addr+"/password/forgot", "application/json",
+		strings.NewReader(`{"email": "jane@example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("forgot password = %d", resp.StatusCode)
+	}
+	if err = s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("Shutdown() returned after %v, before the email timed out", elapsed)
+	}
+}
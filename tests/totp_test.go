@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"net/url"
+	"resume-backend-parser/internal/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base32 of the RFC 6238 SHA1 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the RFC test vectors have 8 digits, authenticator apps use the last 6
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range cases {
+		code, err := totp.Code(rfcSecret, time.Unix(unix, 0))
+		if err != nil || code != expected {
+			t.Errorf("Code(%d) = %q, %v, expected %q", unix, code, err, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := totp.Code(rfcSecret, now.Add(-totp.Period))
+
+	counter, ok := totp.Validate(rfcSecret, code, now, 0)
+	if !ok || counter != totp.Counter(now)-1 {
+		t.Fatalf("Validate() of the previous code = %d, %v", counter, ok)
+	}
+	if _, ok = totp.Validate(rfcSecret, code, now, counter); ok {
+		t.Error("Validate() accepted a replayed code")
+	}
+	if _, ok = totp.Validate(rfcSecret, code, now.Add(3*totp.Period), 0); ok {
+		t.Error("Validate() accepted an expired code")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(totp.ProvisioningURI(rfcSecret, "ResumeParser", "jane@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/ResumeParser:jane@example.com" {
+		t.Errorf("ProvisioningURI() = %s", uri)
+	}
+	if uri.Query().Get("secret") != rfcSecret || uri.Query().Get("issuer") != "ResumeParser" {
+		t.Errorf("ProvisioningURI() query = %v", uri.Query())
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes(10)
+	if err != nil || len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes() = %v, %v", codes, err)
+	}
+	if len(codes[0]) != 11 || codes[0][5] != '-' || codes[0] == codes[1] {
+		t.Errorf("GenerateRecoveryCodes() = %v", codes)
+	}
+	loose := " " + strings.ToUpper(strings.Replace(codes[0], "-", "", 1)) + " "
+	if totp.HashRecoveryCode(loose) != totp.HashRecoveryCode(codes[0]) {
+		t.Error("HashRecoveryCode() depends on case and dashes")
+	}
+}
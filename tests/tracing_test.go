@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"resume-backend-parser/internal/config"
+	"resume-backend-parser/internal/logging"
+	"resume-backend-parser/internal/tracing"
+)
+
+// recordSpans installs a tracer provider keeping the spans in memory until
+// the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), config.Tracing{Exporter: config.NoTraces}, nil); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %q", name)
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) string {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracingRequests(t *testing.T) {
+	recorder := recordSpans(t)
+	var out syncBuffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&out, jsonLogging(slog.LevelInfo)))
+	defer slog.SetDefault(previous)
+	s, addr, _ := newTestServer(t, "-database.host", "127.0.0.1", "-database.port", closedPort(t))
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/signup",
+		strings.NewReader(`{"email": "jane@example.com", "password": "hunter22"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// lets the request finish its span
+	s.Shutdown(context.Background())
+
+	spans := recorder.Ended()
+	request := findSpan(t, spans, "POST /signup")
+	if request.SpanKind() != trace.SpanKindServer || request.SpanContext().TraceID().String() != traceID ||
+		request.Parent().SpanID().String() != parentID {
+		t.Errorf("request span: kind %v, trace %s, parent %s", request.SpanKind(), request.SpanContext().TraceID(),
+			request.Parent().SpanID())
+	}
+	if spanAttribute(request, "http.route") != "/signup" || spanAttribute(request, "http.response.status_code") != "500" ||
+		request.Status().Code != codes.Error {
+		t.Errorf("request span: attributes %v, status %v", request.Attributes(), request.Status())
+	}
+
+	query := findSpan(t, spans, "database.UserExists")
+	if query.Parent().SpanID() != request.SpanContext().SpanID() || query.SpanKind() != trace.SpanKindClient {
+		t.Errorf("query span: parent %s, kind %v", query.Parent().SpanID(), query.SpanKind())
+	}
+	if spanAttribute(query, "db.system") != "postgresql" ||
+		!strings.HasPrefix(spanAttribute(query, "db.statement"), "SELECT id FROM users WHERE email = $1") {
+		t.Errorf("query span attributes = %v", query.Attributes())
+	}
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			if strings.Contains(attr.Value.Emit(), "jane@example.com") {
+				t.Errorf("span %q shows personal data: %v", span.Name(), attr)
+			}
+		}
+	}
+
+	if log := out.String(); !strings.Contains(log, `"trace_id":"`+traceID+`"`) {
+		t.Errorf("the log doesn't show the trace:\n%s", log)
+	}
+}
+
+func TestTracingStdoutExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+	var buf bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(),
+		config.Tracing{Exporter: config.StdoutTraces, SampleRatio: 1, ServiceName: "resume-test"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tracing.Start(context.Background(), "resumes.write")
+	tracing.End(span, errors.New(`no profile for "jane@example.com"`))
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"Name":"resumes.write"`) || !strings.Contains(out, "resume-test") {
+		t.Errorf("exported:\n%s", out)
+	}
+	if strings.Contains(out, "jane@example.com") || !strings.Contains(out, `no profile for \"[redacted]\"`) {
+		t.Errorf("the span error isn't redacted:\n%s", out)
+	}
+}
+
+func TestTracingConfig(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := loadConfig(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tracing.Exporter != config.NoTraces || cfg.Tracing.SampleRatio != 1 {
+		t.Errorf("tracing defaults = %+v", cfg.Tracing)
+	}
+	t.Setenv("TRACING_EXPORTER", "OTLP")
+	t.Setenv("TRACING_SAMPLE_RATIO", "0.25")
+	if cfg, err = loadConfig(t); err != nil || cfg.Tracing.Exporter != config.OTLPTraces || cfg.Tracing.SampleRatio != 0.25 {
+		t.Errorf("tracing = %+v, %v", cfg.Tracing, err)
+	}
+	for _, args := range [][]string{{"-tracing.exporter", "jaeger"}, {"-tracing.sample_ratio", "2"},
+		{"-tracing.sample_ratio", "all"}} {
+		if _, err = loadConfig(t, args...); err == nil {
+			t.Errorf("Load(%v) accepted an invalid setting", args)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"net/http"
+	"resume-backend-parser/internal/server"
+	"strings"
+	"testing"
+)
+
+func TestValidateNewUser(t *testing.T) {
+	name, email, err := server.ValidateNewUser("  Jane Doe ", "Jane Doe <jane@example.com>")
+	if err != nil || name != "Jane Doe" || email != "jane@example.com" {
+		t.Errorf("ValidateNewUser() = %q, %q, %v", name, email, err)
+	}
+	if _, _, err = server.ValidateNewUser("Jane", "not an email"); err == nil {
+		t.Error("ValidateNewUser() accepted an invalid email")
+	}
+	if _, _, err = server.ValidateNewUser(" ", "jane@example.com"); err == nil {
+		t.Error("ValidateNewUser() accepted an empty name")
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	a, err := server.GeneratePassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := server.GeneratePassword()
+	if a == b || server.ValidatePassword(a) != nil {
+		t.Errorf("GeneratePassword() = %q, %q", a, b)
+	}
+	if server.ValidatePassword("short") == nil {
+		t.Error("ValidatePassword() accepted a short password")
+	}
+	if server.ValidatePassword(strings.Repeat("a", 73)) == nil {
+		t.Error("ValidatePassword() accepted a password bcrypt can't hash")
+	}
+}
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := server.PassToHash("hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(hash, "hunter22") || server.LegacyPassword(hash) {
+		t.Fatalf("PassToHash() = %q", hash)
+	}
+	if !server.CheckPassword(hash, "hunter22") || server.CheckPassword(hash, "hunter23") {
+		t.Error("CheckPassword() doesn't check against the hash")
+	}
+	// the hash itself is no password
+	if server.CheckPassword(hash, hash) {
+		t.Error("CheckPassword() accepted the hash")
+	}
+	// passwords stored before they were hashed
+	if !server.LegacyPassword("hunter22") || !server.CheckPassword("hunter22", "hunter22") ||
+		server.CheckPassword("hunter22", "hunter23") {
+		t.Error("CheckPassword() doesn't check legacy passwords")
+	}
+}
+
+func TestPasswordsAreStoredHashed(t *testing.T) {
+	db := testDatabase(t, nil)
+	_, addr, _ := newTestServer(t, testDatabaseArgs()...)
+	post := func(path string, body string) int {
+		t.Helper()
+		resp, err := http.Post("http://"+addr+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post("/signup", `{"name": "Jane Doe", "email": "jane@example.com", "password": "hunter22"}`); status != http.StatusOK {
+		t.Fatalf("signup = %d", status)
+	}
+	_, hash, err := db.GetPasswordHash("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.LegacyPassword(hash) || !server.CheckPassword(hash, "hunter22") {
+		t.Errorf("stored password = %q", hash)
+	}
+	if status := post("/login", `{"email": "jane@example.com", "password": "hunter22"}`); status != http.StatusOK {
+		t.Errorf("login = %d", status)
+	}
+	if status := post("/signup", `{"name": "John Doe", "email": "john@example.com", "password": "`+
+		strings.Repeat("a", 73)+`"}`); status != http.StatusBadRequest {
+		t.Errorf("signup with a too long password = %d", status)
+	}
+
+	// a password stored in plaintext still logs in, and is hashed then
+	createTestUser(t, db, "legacy@example.com")
+	if status := post("/login", `{"email": "legacy@example.com", "password": "wrong password"}`); status != http.StatusBadRequest {
+		t.Errorf("login with a wrong password = %d", status)
+	}
+	if status := post("/login", `{"email": "legacy@example.com", "password": "hash"}`); status != http.StatusOK {
+		t.Errorf("login with the legacy password = %d", status)
+	}
+	if _, hash, err = db.GetPasswordHash("legacy@example.com"); err != nil || server.LegacyPassword(hash) {
+		t.Errorf("the legacy password wasn't hashed: %q, %v", hash, err)
+	}
+	if passwords, err := db.GetLegacyPasswords(); err != nil || len(passwords) != 0 {
+		t.Errorf("legacy passwords = %v, %v", passwords, err)
+	}
+}